@@ -0,0 +1,150 @@
+package scylla_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	scylla "github.com/scylladb/scylla-go-driver"
+	"github.com/scylladb/scylla-go-driver/scyllatest"
+	"github.com/scylladb/scylla-go-driver/transport"
+)
+
+func TestAdmissionLimits(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name    string
+		limits  transport.AdmissionLimits
+		minTime time.Duration
+		err     error
+	}{
+		{
+			name:   "global fail fast",
+			limits: transport.AdmissionLimits{Global: transport.Limit{Rate: 1}, FailFast: true},
+			err:    scylla.ErrOverloaded,
+		},
+		{
+			name:   "node fail fast",
+			limits: transport.AdmissionLimits{Node: transport.Limit{Rate: 1}, FailFast: true},
+			err:    scylla.ErrOverloaded,
+		},
+		{
+			name:    "wait",
+			limits:  transport.AdmissionLimits{Global: transport.Limit{Rate: 20}},
+			minTime: 90 * time.Millisecond,
+		},
+		{
+			name:   "wait until context is done",
+			limits: transport.AdmissionLimits{Global: transport.Limit{Rate: 0.1}},
+			err:    context.DeadlineExceeded,
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			srv := scyllatest.NewServer(scyllatest.DefaultConfig(1))
+			defer srv.Close()
+
+			cfg := testSessionConfig(srv)
+			cfg.Limits = tc.limits
+			session, err := scylla.NewSession(context.Background(), cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer session.Close()
+
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+			q := session.Query("SELECT v FROM ks.t")
+			start := time.Now()
+			for i := 0; i < 3 && err == nil; i++ {
+				_, err = q.Exec(ctx)
+			}
+			if !errors.Is(err, tc.err) {
+				t.Fatalf("expected %v, got %v", tc.err, err)
+			}
+			if elapsed := time.Since(start); elapsed < tc.minTime {
+				t.Fatalf("queries took %v, expected at least %v", elapsed, tc.minTime)
+			}
+		})
+	}
+}
+
+func TestAdmissionNodeLimitNextNode(t *testing.T) {
+	t.Parallel()
+	scfg := scyllatest.DefaultConfig(2)
+	scfg.Nodes[1].Datacenter = "remote"
+	srv := scyllatest.NewServer(scfg)
+	defer srv.Close()
+
+	cfg := testSessionConfig(srv)
+	// Local node is always the first node of the plan.
+	cfg.HostSelectionPolicy = transport.NewTokenAwarePolicy(scfg.Nodes[0].Datacenter)
+	cfg.Limits = transport.AdmissionLimits{Node: transport.Limit{Rate: 0.1}, FailFast: true}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	session, err := scylla.NewSession(ctx, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer session.Close()
+
+	q := session.Query("SELECT v FROM ks.t")
+	for i := 0; i < 2; i++ {
+		if _, err := q.Exec(ctx); err != nil {
+			t.Fatalf("query %d: %v", i, err)
+		}
+	}
+	if _, err := q.Exec(ctx); !errors.Is(err, scylla.ErrOverloaded) {
+		t.Fatalf("expected %v, got %v", scylla.ErrOverloaded, err)
+	}
+}
+
+func TestAdmissionAsyncExec(t *testing.T) {
+	t.Parallel()
+	srv := scyllatest.NewServer(scyllatest.DefaultConfig(1))
+	defer srv.Close()
+	// Response is delayed, so that the second request is sent while the first one is in flight.
+	srv.On("SELECT v FROM ks.t", scyllatest.Result{Delay: 100 * time.Millisecond})
+
+	cfg := testSessionConfig(srv)
+	cfg.Limits = transport.AdmissionLimits{Global: transport.Limit{MaxInFlight: 1}, FailFast: true}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	session, err := scylla.NewSession(ctx, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer session.Close()
+
+	q := session.Query("SELECT v FROM ks.t")
+	q.AsyncExec(ctx)
+	q.AsyncExec(ctx)
+	if _, err := q.Fetch(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := q.Fetch(); !errors.Is(err, scylla.ErrOverloaded) {
+		t.Fatalf("expected %v, got %v", scylla.ErrOverloaded, err)
+	}
+
+	// Request is released when the response arrives, even if it's never fetched.
+	q.AsyncExec(ctx)
+	other := session.Query("SELECT v FROM ks.other")
+	for {
+		_, err := other.Exec(ctx)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, scylla.ErrOverloaded) || ctx.Err() != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if _, err := q.Fetch(); err != nil {
+		t.Fatal(err)
+	}
+}
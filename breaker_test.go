@@ -0,0 +1,133 @@
+package scylla_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	scylla "github.com/scylladb/scylla-go-driver"
+	"github.com/scylladb/scylla-go-driver/frame"
+	. "github.com/scylladb/scylla-go-driver/frame/response"
+	"github.com/scylladb/scylla-go-driver/scyllatest"
+	"github.com/scylladb/scylla-go-driver/transport"
+	"go.uber.org/atomic"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	t.Parallel()
+	scfg := scyllatest.DefaultConfig(2)
+	srv := scyllatest.NewServer(scfg)
+	defer srv.Close()
+
+	const query = "SELECT v FROM ks.t"
+	overloaded := scfg.Nodes[0].Addr
+	var attempts atomic.Int32
+	srv.Handle(func(r scyllatest.Request) *scyllatest.Result {
+		if r.Query != query || r.Node != overloaded {
+			return nil
+		}
+		attempts.Inc()
+		return &scyllatest.Result{Err: ScyllaError{Code: frame.ErrCodeOverloaded, Message: "overloaded"}}
+	})
+
+	cfg := testSessionConfig(srv)
+	cfg.CircuitBreaker = transport.CircuitBreakerConfig{
+		Window:      time.Minute,
+		MinRequests: 2,
+		ErrorRate:   0.5,
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	session, err := scylla.NewSession(ctx, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer session.Close()
+
+	q := session.Query(query)
+	q.SetIdempotent(true)
+	for i := 0; i < 10; i++ {
+		if _, err := q.Exec(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if v := attempts.Load(); v != 2 {
+		t.Fatalf("expected 2 attempts on overloaded node, got %d", v)
+	}
+	for _, n := range session.Nodes() {
+		expected := transport.CircuitClosed
+		if n.Addr == overloaded {
+			expected = transport.CircuitOpen
+		}
+		if n.Circuit != expected {
+			t.Fatalf("node %s circuit %v, expected %v", n.Addr, n.Circuit, expected)
+		}
+	}
+}
+
+func TestCircuitBreakerPowerOfTwoChoices(t *testing.T) {
+	t.Parallel()
+	scfg := scyllatest.DefaultConfig(2)
+	srv := scyllatest.NewServer(scfg)
+	defer srv.Close()
+
+	const query = "SELECT v FROM ks.t"
+	overloaded := scfg.Nodes[0].Addr
+	var healthy atomic.Bool
+	srv.Handle(func(r scyllatest.Request) *scyllatest.Result {
+		if r.Query != query || r.Node != overloaded || healthy.Load() {
+			return nil
+		}
+		return &scyllatest.Result{Err: ScyllaError{Code: frame.ErrCodeOverloaded, Message: "overloaded"}}
+	})
+
+	cfg := testSessionConfig(srv)
+	policy := transport.NewTokenAwarePolicy("datacenter1")
+	policy.SetPowerOfTwoChoices(true)
+	cfg.HostSelectionPolicy = policy
+	cfg.CircuitBreaker = transport.CircuitBreakerConfig{
+		Window:       time.Minute,
+		MinRequests:  2,
+		ErrorRate:    0.5,
+		OpenDuration: 100 * time.Millisecond,
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	session, err := scylla.NewSession(ctx, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer session.Close()
+
+	circuit := func() transport.CircuitState {
+		for _, n := range session.Nodes() {
+			if n.Addr == overloaded {
+				return n.Circuit
+			}
+		}
+		t.Fatalf("node %s not found", overloaded)
+		return 0
+	}
+
+	q := session.Query(query)
+	q.SetIdempotent(true)
+	for circuit() != transport.CircuitOpen {
+		if _, err := q.Exec(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Sampling node load must not take the probe, so that one of the following queries is sent to the node.
+	healthy.Store(true)
+	time.Sleep(cfg.CircuitBreaker.OpenDuration)
+	for i := 0; i < 10; i++ {
+		if _, err := q.Exec(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if s := circuit(); s != transport.CircuitClosed {
+		t.Fatalf("circuit %v, expected %v", s, transport.CircuitClosed)
+	}
+}
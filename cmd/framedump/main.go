@@ -0,0 +1,126 @@
+// Framedump prints frames recorded with transport.FileRecorder.
+//
+// Usage:
+//
+//	framedump [-conn id] [-hex] recording
+package main
+
+import (
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"time"
+
+	"github.com/scylladb/scylla-go-driver/frame"
+	"github.com/scylladb/scylla-go-driver/frame/request"
+	"github.com/scylladb/scylla-go-driver/frame/response"
+	"github.com/scylladb/scylla-go-driver/transport"
+)
+
+var opCodeNames = map[frame.OpCode]string{
+	frame.OpError:         "ERROR",
+	frame.OpStartup:       "STARTUP",
+	frame.OpReady:         "READY",
+	frame.OpAuthenticate:  "AUTHENTICATE",
+	frame.OpOptions:       "OPTIONS",
+	frame.OpSupported:     "SUPPORTED",
+	frame.OpQuery:         "QUERY",
+	frame.OpResult:        "RESULT",
+	frame.OpPrepare:       "PREPARE",
+	frame.OpExecute:       "EXECUTE",
+	frame.OpRegister:      "REGISTER",
+	frame.OpEvent:         "EVENT",
+	frame.OpBatch:         "BATCH",
+	frame.OpAuthChallenge: "AUTH_CHALLENGE",
+	frame.OpAuthResponse:  "AUTH_RESPONSE",
+	frame.OpAuthSuccess:   "AUTH_SUCCESS",
+}
+
+func opCodeName(op frame.OpCode) string {
+	if v, ok := opCodeNames[op]; ok {
+		return v
+	}
+	return fmt.Sprintf("0x%02x", op)
+}
+
+func main() {
+	conn := flag.Uint64("conn", 0, "Print only frames of connection with given ID")
+	dumpHex := flag.Bool("hex", false, "Print hex dump of frame bodies")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] recording\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	f, err := os.Open(flag.Arg(0))
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer f.Close()
+
+	r, err := transport.NewRecordReader(f)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var start time.Time
+	for {
+		rec, err := r.Next()
+		if errors.Is(err, io.EOF) {
+			return
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		if start.IsZero() {
+			start = rec.Time
+		}
+		if *conn != 0 && rec.ConnID != *conn {
+			continue
+		}
+		dump(os.Stdout, rec, rec.Time.Sub(start), *dumpHex)
+	}
+}
+
+func dump(w io.Writer, rec transport.FrameRecord, elapsed time.Duration, dumpHex bool) {
+	h := rec.Header
+	fmt.Fprintf(w, "+%s conn=%d %s %s %s stream=%d flags=0x%02x length=%d\n",
+		elapsed, rec.ConnID, rec.Addr, rec.Direction, opCodeName(h.OpCode), h.StreamID, h.Flags, h.Length)
+
+	if h.OpCode == frame.OpAuthResponse {
+		fmt.Fprintln(w, "\tcredentials not recorded")
+		return
+	}
+
+	var b frame.Buffer
+	b.Write(rec.Body)
+	var v any
+	if rec.Direction == transport.FrameSent {
+		if h.Flags&frame.CustomPayload != 0 {
+			fmt.Fprintf(w, "\tcustom payload: %v\n", b.ReadBytesMap())
+		}
+		v = request.ParseRequest(h.OpCode, &b)
+	} else {
+		if h.Flags&(frame.Tracing|frame.Warning|frame.CustomPayload) != 0 {
+			fmt.Fprintf(w, "\t%+v\n", frame.ParseMsgOptionalFields(&b, h.Flags))
+		}
+		v = response.ParseResponse(h.OpCode, &b)
+	}
+	if err := b.Error(); err != nil {
+		fmt.Fprintf(w, "\tparse error: %s\n", err)
+	} else if v != nil {
+		fmt.Fprintf(w, "\t%+v\n", v)
+	}
+
+	if dumpHex {
+		fmt.Fprint(w, hex.Dump(rec.Body))
+	}
+}
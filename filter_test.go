@@ -0,0 +1,68 @@
+package scylla_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	scylla "github.com/scylladb/scylla-go-driver"
+	"github.com/scylladb/scylla-go-driver/scyllatest"
+	"github.com/scylladb/scylla-go-driver/transport"
+)
+
+func upNodes(s *scylla.Session) map[string]bool {
+	m := make(map[string]bool)
+	for _, n := range s.Nodes() {
+		m[n.Addr] = n.Up
+	}
+	return m
+}
+
+func TestHostFilter(t *testing.T) {
+	t.Parallel()
+	srv := scyllatest.NewServer(scyllatest.DefaultConfig(3))
+	defer srv.Close()
+	hosts := srv.Hosts()
+
+	cfg := testSessionConfig(srv)
+	cfg.HostFilter = transport.DenyHosts(hosts[1])
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	session, err := scylla.NewSession(ctx, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer session.Close()
+
+	// Filtered node is tracked in topology, but it's not connected.
+	if up := upNodes(session); len(up) != 3 || !up[hosts[0]] || up[hosts[1]] || !up[hosts[2]] {
+		t.Fatalf("unexpected nodes %v", up)
+	}
+
+	const query = "INSERT INTO ks.t (pk) VALUES (1)"
+	for i := 0; i < 10; i++ {
+		q := session.Query(query)
+		if _, err := q.Exec(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, r := range srv.Requests() {
+		if r.Node == hosts[1] {
+			t.Fatalf("request sent to filtered node %+v", r)
+		}
+	}
+
+	// Replacing filter opens pool to the previously filtered node and closes the newly filtered one.
+	session.SetHostFilter(transport.DenyHosts(hosts[0]))
+	for {
+		if up := upNodes(session); !up[hosts[0]] && up[hosts[1]] && up[hosts[2]] {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			t.Fatalf("filter not applied, nodes %v", upNodes(session))
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
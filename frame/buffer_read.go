@@ -217,6 +217,35 @@ func (b *Buffer) ReadBytesMap() BytesMap {
 	return m
 }
 
+func (b *Buffer) ReadQueryOptions() QueryOptions {
+	q := QueryOptions{
+		Flags: b.ReadQueryFlags(),
+	}
+	if Values&q.Flags != 0 {
+		n := int(b.ReadShort())
+		q.Values = make([]Value, 0, n)
+		for i := 0; i < n && b.Error() == nil; i++ {
+			if WithNamesForValues&q.Flags != 0 {
+				q.Names = append(q.Names, b.ReadString())
+			}
+			q.Values = append(q.Values, b.ReadValue())
+		}
+	}
+	if PageSize&q.Flags != 0 {
+		q.PageSize = b.ReadInt()
+	}
+	if WithPagingState&q.Flags != 0 {
+		q.PagingState = b.ReadBytes()
+	}
+	if WithSerialConsistency&q.Flags != 0 {
+		q.SerialConsistency = b.ReadConsistency()
+	}
+	if WithDefaultTimestamp&q.Flags != 0 {
+		q.Timestamp = b.ReadLong()
+	}
+	return q
+}
+
 func (b *Buffer) ReadStartupOptions() StartupOptions {
 	return b.ReadStringMap()
 }
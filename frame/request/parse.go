@@ -0,0 +1,110 @@
+package request
+
+import (
+	"strings"
+
+	"github.com/scylladb/scylla-go-driver/frame"
+)
+
+// Parsers are the inverse of WriteTo, they are used for inspecting recorded frames.
+
+func ParseQuery(b *frame.Buffer) *Query {
+	return &Query{
+		Query:       b.ReadLongString(),
+		Consistency: b.ReadConsistency(),
+		Options:     b.ReadQueryOptions(),
+	}
+}
+
+func ParseExecute(b *frame.Buffer) *Execute {
+	return &Execute{
+		ID:          b.ReadShortBytes(),
+		Consistency: b.ReadConsistency(),
+		Options:     b.ReadQueryOptions(),
+	}
+}
+
+func ParsePrepare(b *frame.Buffer) *Prepare {
+	return &Prepare{
+		Query: b.ReadLongString(),
+	}
+}
+
+func ParseStartup(b *frame.Buffer) *Startup {
+	return &Startup{
+		Options: b.ReadStartupOptions(),
+	}
+}
+
+func ParseRegister(b *frame.Buffer) *Register {
+	return &Register{
+		EventTypes: b.ReadStringList(),
+	}
+}
+
+func ParseOptions(_ *frame.Buffer) *Options {
+	return &Options{}
+}
+
+func ParseAuthResponse(b *frame.Buffer) *AuthResponse {
+	// Token has format "\x00username\x00password".
+	v := strings.SplitN(strings.TrimPrefix(b.ReadLongString(), "\x00"), "\x00", 2)
+	a := &AuthResponse{Username: v[0]}
+	if len(v) > 1 {
+		a.Password = v[1]
+	}
+	return a
+}
+
+func ParseBatch(b *frame.Buffer) *Batch {
+	q := &Batch{
+		Type: b.ReadByte(),
+	}
+	n := int(b.ReadShort())
+	q.Queries = make([]BatchQuery, n)
+	for i := 0; i < n && b.Error() == nil; i++ {
+		q.Queries[i].Kind = b.ReadByte()
+		if q.Queries[i].Kind == 0 {
+			q.Queries[i].Query = b.ReadLongString()
+		} else {
+			q.Queries[i].Prepared = b.ReadShortBytes()
+		}
+		vn := int(b.ReadShort())
+		for j := 0; j < vn && b.Error() == nil; j++ {
+			q.Queries[i].Values = append(q.Queries[i].Values, b.ReadValue())
+		}
+	}
+	q.Consistency = b.ReadConsistency()
+	q.Flags = b.ReadQueryFlags()
+	if q.Flags&frame.WithSerialConsistency != 0 {
+		q.SerialConsistency = b.ReadConsistency()
+	}
+	if q.Flags&frame.WithDefaultTimestamp != 0 {
+		q.Timestamp = b.ReadLong()
+	}
+	return q
+}
+
+// ParseRequest parses request body with given opcode, it returns nil for unknown opcodes.
+func ParseRequest(op frame.OpCode, b *frame.Buffer) frame.Request {
+	switch op {
+	case frame.OpStartup:
+		return ParseStartup(b)
+	case frame.OpAuthResponse:
+		return ParseAuthResponse(b)
+	case frame.OpOptions:
+		return ParseOptions(b)
+	case frame.OpQuery:
+		return ParseQuery(b)
+	case frame.OpPrepare:
+		return ParsePrepare(b)
+	case frame.OpExecute:
+		return ParseExecute(b)
+	case frame.OpBatch:
+		return ParseBatch(b)
+	case frame.OpRegister:
+		return ParseRegister(b)
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,91 @@
+package request
+
+import (
+	"testing"
+
+	"github.com/scylladb/scylla-go-driver/frame"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseRequest(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		name    string
+		content frame.Request
+	}{
+		{
+			name:    "options",
+			content: &Options{},
+		},
+		{
+			name:    "startup",
+			content: &Startup{Options: frame.StartupOptions{"CQL_VERSION": "3.0.0"}},
+		},
+		{
+			name:    "auth response",
+			content: &AuthResponse{Username: "cassandra", Password: "pass\x00word"},
+		},
+		{
+			name: "query",
+			content: &Query{
+				Query:       "SELECT * FROM ks.t WHERE pk = ?",
+				Consistency: frame.QUORUM,
+				Options: frame.QueryOptions{
+					Values:      []frame.Value{{N: 1, Bytes: []byte{0x01}}, {N: -1}},
+					PageSize:    5000,
+					PagingState: []byte{0x02, 0x03},
+					Timestamp:   42,
+				},
+			},
+		},
+		{
+			name: "execute",
+			content: &Execute{
+				ID:          []byte{0xaa, 0xbb},
+				Consistency: frame.ONE,
+				Options: frame.QueryOptions{
+					Values:            []frame.Value{{N: 2, Bytes: []byte{0x01, 0x02}}},
+					SerialConsistency: frame.LOCALSERIAL,
+				},
+			},
+		},
+		{
+			name:    "prepare",
+			content: &Prepare{Query: "INSERT INTO ks.t (pk) VALUES (?)"},
+		},
+		{
+			name:    "register",
+			content: &Register{EventTypes: []frame.EventType{frame.TopologyChange, frame.StatusChange}},
+		},
+		{
+			name: "batch",
+			content: &Batch{
+				Type:        frame.UnloggedBatchFlag,
+				Flags:       frame.WithDefaultTimestamp,
+				Consistency: frame.ALL,
+				Timestamp:   7,
+				Queries: []BatchQuery{
+					{Kind: 0, Query: "INSERT INTO ks.t (pk) VALUES (1)"},
+					{Kind: 1, Prepared: []byte{0x01}, Values: []frame.Value{{N: 1, Bytes: []byte{0x05}}}},
+				},
+			},
+		},
+	}
+
+	for i := 0; i < len(testCases); i++ {
+		tc := testCases[i]
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			var buf frame.Buffer
+			tc.content.WriteTo(&buf)
+			out := ParseRequest(tc.content.OpCode(), &buf)
+			if err := buf.Error(); err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tc.content, out); diff != "" {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
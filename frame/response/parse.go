@@ -0,0 +1,29 @@
+package response
+
+import (
+	"github.com/scylladb/scylla-go-driver/frame"
+)
+
+// ParseResponse parses response body with given opcode, it returns nil for unknown opcodes.
+func ParseResponse(op frame.OpCode, b *frame.Buffer) frame.Response {
+	switch op {
+	case frame.OpError:
+		return ParseError(b)
+	case frame.OpReady:
+		return ParseReady(b)
+	case frame.OpResult:
+		return ParseResult(b)
+	case frame.OpSupported:
+		return ParseSupported(b)
+	case frame.OpEvent:
+		return ParseEvent(b)
+	case frame.OpAuthenticate:
+		return ParseAuthenticate(b)
+	case frame.OpAuthSuccess:
+		return ParseAuthSuccess(b)
+	case frame.OpAuthChallenge:
+		return ParseAuthChallenge(b)
+	default:
+		return nil
+	}
+}
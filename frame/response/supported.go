@@ -39,6 +39,10 @@ const (
 	ScyllaShardingIgnoreMSB = "SCYLLA_SHARDING_IGNORE_MSB"
 	ScyllaShardAwarePort    = "SCYLLA_SHARD_AWARE_PORT"
 	ScyllaShardAwarePortSSL = "SCYLLA_SHARD_AWARE_PORT_SSL"
+
+	// ScyllaTabletsRoutingV1 is the protocol extension making Scylla send tablet routing information
+	// of misrouted requests, see https://github.com/scylladb/scylladb/blob/master/docs/dev/protocol-extensions.md#negotiate-sending-tablets-info-to-the-drivers
+	ScyllaTabletsRoutingV1 = "TABLETS_ROUTING_V1"
 )
 
 func (s *Supported) ScyllaSupported() *ScyllaSupported {
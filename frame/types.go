@@ -288,6 +288,8 @@ var possibleOptions = StringMultiMap{
 	},
 	"NO_COMPACT":        {},
 	"THROW_ON_OVERLOAD": {},
+	// Scylla protocol extensions.
+	"TABLETS_ROUTING_V1": {""},
 }
 
 // QueryOptions represent optional Values defined by flags.
@@ -0,0 +1,156 @@
+package scylla
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/scylladb/scylla-go-driver/frame"
+	"github.com/scylladb/scylla-go-driver/transport"
+)
+
+// nonDeterministicFuncs are functions returning a different value on every call,
+// statements writing their results are not idempotent.
+var nonDeterministicFuncs = map[string]struct{}{
+	"now":              {},
+	"uuid":             {},
+	"currenttimeuuid":  {},
+	"currenttimestamp": {},
+	"currentdate":      {},
+	"currenttime":      {},
+}
+
+// inferIdempotent reports if executing stmt more than once has the same effect as executing it once.
+// SELECTs are idempotent, INSERTs and UPDATEs are idempotent unless they use lightweight transactions
+// or non-deterministic functions such as now(), or update columns based on their current value,
+// such as counters and list appends. Other statements are not considered idempotent.
+func inferIdempotent(stmt transport.Statement) bool {
+	tokens := cqlTokens(stmt.Content)
+	if len(tokens) == 0 {
+		return false
+	}
+	switch tokens[0] {
+	case "select":
+		return true
+	case "insert", "update":
+	default:
+		return false
+	}
+
+	if m := stmt.BindMetadata; m != nil {
+		for _, c := range m.Columns {
+			if c.Type.ID == frame.CounterID {
+				return false
+			}
+		}
+	}
+	for i, t := range tokens {
+		if t == "if" {
+			return false
+		}
+		if _, ok := nonDeterministicFuncs[t]; ok && i+1 < len(tokens) && tokens[i+1] == "(" {
+			return false
+		}
+	}
+	if tokens[0] == "update" {
+		for _, a := range assignments(tokens) {
+			if len(a) < 2 || a[1] != "=" {
+				continue
+			}
+			for _, t := range a[2:] {
+				if t == a[0] {
+					return false
+				}
+			}
+		}
+	}
+	return true
+}
+
+// assignments returns assignments of the SET clause of UPDATE statement tokens.
+func assignments(tokens []string) [][]string {
+	start := -1
+	for i, t := range tokens {
+		if t == "set" {
+			start = i + 1
+			break
+		}
+	}
+	if start < 0 {
+		return nil
+	}
+
+	var res [][]string
+	depth, cur := 0, start
+	for i := start; i < len(tokens); i++ {
+		switch t := tokens[i]; t {
+		case "(", "[", "{":
+			depth++
+		case ")", "]", "}":
+			depth--
+		case ",", "where":
+			if depth == 0 {
+				res = append(res, tokens[cur:i])
+				cur = i + 1
+			}
+			if t == "where" {
+				return res
+			}
+		}
+	}
+	return append(res, tokens[cur:])
+}
+
+// cqlTokens splits CQL statement into lower case identifiers and keywords, quoted identifiers
+// and single character symbols. String literals and comments are skipped, numbers are kept.
+func cqlTokens(s string) []string {
+	var res []string
+	for i := 0; i < len(s); {
+		c := rune(s[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '\'':
+			// String literal, quotes are escaped by doubling them.
+			i++
+			for i < len(s) {
+				if s[i] == '\'' {
+					if i+1 < len(s) && s[i+1] == '\'' {
+						i += 2
+						continue
+					}
+					break
+				}
+				i++
+			}
+			i++
+		case c == '"':
+			j := strings.IndexByte(s[i+1:], '"')
+			if j < 0 {
+				j = len(s) - i - 1
+			}
+			res = append(res, s[i+1:i+1+j])
+			i += j + 2
+		case c == '-' && i+1 < len(s) && s[i+1] == '-', c == '/' && i+1 < len(s) && s[i+1] == '/':
+			for i < len(s) && s[i] != '\n' {
+				i++
+			}
+		case c == '/' && i+1 < len(s) && s[i+1] == '*':
+			if j := strings.Index(s[i+2:], "*/"); j >= 0 {
+				i += j + 4
+			} else {
+				i = len(s)
+			}
+		case c == '_' || unicode.IsLetter(c) || unicode.IsDigit(c):
+			j := i
+			for j < len(s) && (s[j] == '_' || unicode.IsLetter(rune(s[j])) || unicode.IsDigit(rune(s[j]))) {
+				j++
+			}
+			res = append(res, strings.ToLower(s[i:j]))
+			i = j
+		default:
+			res = append(res, s[i:i+1])
+			i++
+		}
+	}
+	return res
+}
@@ -0,0 +1,145 @@
+package scylla_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	scylla "github.com/scylladb/scylla-go-driver"
+	"github.com/scylladb/scylla-go-driver/frame"
+	. "github.com/scylladb/scylla-go-driver/frame/response"
+	"github.com/scylladb/scylla-go-driver/scyllatest"
+	"github.com/scylladb/scylla-go-driver/transport"
+	"go.uber.org/atomic"
+)
+
+func TestInferIdempotence(t *testing.T) {
+	t.Parallel()
+	srv := scyllatest.NewServer(scyllatest.DefaultConfig(1))
+	defer srv.Close()
+
+	const counterUpdate = "UPDATE ks.t SET c = ? WHERE pk = ?"
+	srv.SetPreparedMetadata(counterUpdate, scyllatest.PreparedMetadata{
+		BindColumns: []frame.ColumnSpec{scyllatest.Column("c", frame.CounterID), scyllatest.Column("pk", frame.BigIntID)},
+	})
+
+	cfg := testSessionConfig(srv)
+	cfg.InferIdempotence = true
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	session, err := scylla.NewSession(ctx, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer session.Close()
+
+	testCases := []struct {
+		name       string
+		query      string
+		prepare    bool
+		idempotent bool
+	}{
+		{name: "select", query: "SELECT v FROM ks.t WHERE pk = 1", idempotent: true},
+		{name: "lower case select", query: "  select * from ks.t", idempotent: true},
+		{name: "insert", query: "INSERT INTO ks.t (pk, v) VALUES (1, 'now()')", idempotent: true},
+		{name: "update", query: "UPDATE ks.t SET v = 1, l = [1, 2] WHERE pk = 1", idempotent: true},
+		{name: "prepared update", query: "UPDATE ks.t SET v = ? WHERE pk = ?", prepare: true, idempotent: true},
+		{name: "insert now", query: "INSERT INTO ks.t (pk, v) VALUES (1, NOW())"},
+		{name: "insert uuid", query: "INSERT INTO ks.t (pk, v) VALUES (uuid(), 1)"},
+		{name: "insert if not exists", query: "INSERT INTO ks.t (pk, v) VALUES (1, 1) IF NOT EXISTS"},
+		{name: "conditional update", query: "UPDATE ks.t SET v = 2 WHERE pk = 1 IF v = 1"},
+		{name: "counter", query: "UPDATE ks.t SET c = c + 1 WHERE pk = 1"},
+		{name: "list append", query: "UPDATE ks.t SET v = 1, l = l + [1] WHERE pk = 1"},
+		{name: "list prepend", query: `UPDATE ks.t SET "L" = [1] + "L" WHERE pk = 1`},
+		{name: "prepared counter", query: counterUpdate, prepare: true},
+		{name: "delete", query: "DELETE FROM ks.t WHERE pk = 1"},
+		{name: "batch", query: "BEGIN BATCH INSERT INTO ks.t (pk) VALUES (1) APPLY BATCH"},
+		{name: "ddl", query: "CREATE TABLE ks.t (pk int PRIMARY KEY)"},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.name, func(t *testing.T) {
+			q := session.Query(tc.query)
+			if tc.prepare {
+				var err error
+				if q, err = session.Prepare(ctx, tc.query); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if v := q.Idempotent(); v != tc.idempotent {
+				t.Fatalf("Idempotent() = %v, expected %v", v, tc.idempotent)
+			}
+		})
+	}
+}
+
+func TestQueryRetryPolicy(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name     string
+		policy   transport.RetryPolicy
+		attempts int
+		err      bool
+	}{
+		{
+			name:     "session policy",
+			attempts: 2,
+		},
+		{
+			name:     "query policy",
+			policy:   transport.NewFallthroughRetryPolicy(),
+			attempts: 1,
+			err:      true,
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			srv := scyllatest.NewServer(scyllatest.DefaultConfig(2))
+			defer srv.Close()
+
+			const query = "SELECT v FROM ks.t"
+			var attempts atomic.Int32
+			srv.Handle(func(r scyllatest.Request) *scyllatest.Result {
+				if r.Query != query {
+					return nil
+				}
+				if attempts.Inc() == 1 {
+					return &scyllatest.Result{Err: ScyllaError{Code: frame.ErrCodeOverloaded, Message: "overloaded"}}
+				}
+				return &scyllatest.Result{}
+			})
+
+			cfg := testSessionConfig(srv)
+			cfg.InferIdempotence = true
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+
+			session, err := scylla.NewSession(ctx, cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer session.Close()
+
+			q := session.Query(query)
+			q.SetRetryPolicy(tc.policy)
+			_, err = q.Exec(ctx)
+			var coded CodedError
+			if tc.err != (errors.As(err, &coded) && coded.ErrorCode() == frame.ErrCodeOverloaded) {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if !tc.err && err != nil {
+				t.Fatal(err)
+			}
+			if v := int(attempts.Load()); v != tc.attempts {
+				t.Fatalf("expected %d attempts, got %d", tc.attempts, v)
+			}
+		})
+	}
+}
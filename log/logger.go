@@ -1,69 +1,169 @@
+// Package log defines Logger used by the driver and helpers for structured attributes
+// of log messages. NewSlogLogger adapts log/slog loggers, it's available only when
+// building with Go 1.21 or newer.
 package log
 
 import (
+	"fmt"
 	"log"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 )
 
+// Level is the importance of a log message, values match log/slog levels.
+type Level int
+
+const (
+	LevelDebug Level = -4
+	LevelInfo  Level = 0
+	LevelWarn  Level = 4
+	LevelError Level = 8
+)
+
+func (l Level) String() string {
+	switch l {
+	case LevelDebug:
+		return "DEBUG"
+	case LevelInfo:
+		return "INFO"
+	case LevelWarn:
+		return "WARNING"
+	case LevelError:
+		return "ERROR"
+	default:
+		return "LEVEL(" + strconv.Itoa(int(l)) + ")"
+	}
+}
+
+// Attr is a key-value pair attached to a log message.
+type Attr struct {
+	Key   string
+	Value any
+}
+
+func String(key, v string) Attr                 { return Attr{Key: key, Value: v} }
+func Int(key string, v int) Attr                { return Attr{Key: key, Value: v} }
+func Duration(key string, v time.Duration) Attr { return Attr{Key: key, Value: v} }
+func Any(key string, v any) Attr                { return Attr{Key: key, Value: v} }
+
+// Err returns attribute with the "err" key.
+func Err(err error) Attr { return Attr{Key: "err", Value: err} }
+
+// Common attribute keys.
+const (
+	NodeKey     = "node"
+	ShardKey    = "shard"
+	StreamKey   = "stream"
+	KeyspaceKey = "keyspace"
+)
+
+func Node(addr string) Attr   { return String(NodeKey, addr) }
+func Shard(shard int) Attr    { return Int(ShardKey, shard) }
+func Stream(id int) Attr      { return Int(StreamKey, id) }
+func Keyspace(ks string) Attr { return String(KeyspaceKey, ks) }
+
+// Logger is a leveled structured logger.
 type Logger interface {
-	Info(v ...any)
-	Infof(format string, v ...any)
-	Infoln(v ...any)
+	Debug(msg string, attrs ...Attr)
+	Info(msg string, attrs ...Attr)
+	Warn(msg string, attrs ...Attr)
+	Error(msg string, attrs ...Attr)
+}
 
-	Warn(v ...any)
-	Warnf(format string, v ...any)
-	Warnln(v ...any)
+// format formats message in the logfmt style, values with spaces or quotes are quoted.
+func format(msg string, attrs []Attr) string {
+	if len(attrs) == 0 {
+		return msg
+	}
+	var b strings.Builder
+	b.WriteString(msg)
+	for _, a := range attrs {
+		b.WriteByte(' ')
+		b.WriteString(a.Key)
+		b.WriteByte('=')
+		v := fmt.Sprint(a.Value)
+		if v == "" || strings.ContainsAny(v, " \"=\t\n") {
+			v = strconv.Quote(v)
+		}
+		b.WriteString(v)
+	}
+	return b.String()
 }
 
-// DefaultLogger only logs warnings and critical errors.
+// DefaultLogger only logs warnings and errors.
 type DefaultLogger struct {
 	warn *log.Logger
+	err  *log.Logger
 }
 
 func NewDefaultLogger() *DefaultLogger {
 	res := &DefaultLogger{
 		warn: log.New(os.Stderr, "WARNING ", log.LstdFlags),
+		err:  log.New(os.Stderr, "ERROR ", log.LstdFlags),
 	}
 	return res
 }
 
-func (logger *DefaultLogger) Info(v ...any)                 {}
-func (logger *DefaultLogger) Infof(format string, v ...any) {}
-func (logger *DefaultLogger) Infoln(v ...any)               {}
-
-func (logger *DefaultLogger) Warn(v ...any)                 { logger.warn.Print(v...) }
-func (logger *DefaultLogger) Warnf(format string, v ...any) { logger.warn.Printf(format, v...) }
-func (logger *DefaultLogger) Warnln(v ...any)               { logger.warn.Println(v...) }
+func (logger *DefaultLogger) Debug(msg string, attrs ...Attr) {}
+func (logger *DefaultLogger) Info(msg string, attrs ...Attr)  {}
+func (logger *DefaultLogger) Warn(msg string, attrs ...Attr)  { logger.warn.Print(format(msg, attrs)) }
+func (logger *DefaultLogger) Error(msg string, attrs ...Attr) { logger.err.Print(format(msg, attrs)) }
 
-// DebugLogger logs both warnings and information about important events in driver's runtime.
+// DebugLogger logs all messages including information about important events in driver's runtime.
 type DebugLogger struct {
-	info *log.Logger
-	warn *log.Logger
+	debug *log.Logger
+	info  *log.Logger
+	warn  *log.Logger
+	err   *log.Logger
 }
 
 func NewDebugLogger() *DebugLogger {
 	res := &DebugLogger{
-		info: log.New(os.Stderr, "INFO ", log.LstdFlags),
-		warn: log.New(os.Stderr, "WARNING ", log.LstdFlags),
+		debug: log.New(os.Stderr, "DEBUG ", log.LstdFlags),
+		info:  log.New(os.Stderr, "INFO ", log.LstdFlags),
+		warn:  log.New(os.Stderr, "WARNING ", log.LstdFlags),
+		err:   log.New(os.Stderr, "ERROR ", log.LstdFlags),
 	}
 	return res
 }
 
-func (logger *DebugLogger) Info(v ...any)                 { logger.info.Print(v...) }
-func (logger *DebugLogger) Infof(format string, v ...any) { logger.info.Printf(format, v...) }
-func (logger *DebugLogger) Infoln(v ...any)               { logger.info.Println(v...) }
-
-func (logger *DebugLogger) Warn(v ...any)                 { logger.warn.Print(v...) }
-func (logger *DebugLogger) Warnf(format string, v ...any) { logger.warn.Printf(format, v...) }
-func (logger *DebugLogger) Warnln(v ...any)               { logger.warn.Println(v...) }
+func (logger *DebugLogger) Debug(msg string, attrs ...Attr) { logger.debug.Print(format(msg, attrs)) }
+func (logger *DebugLogger) Info(msg string, attrs ...Attr)  { logger.info.Print(format(msg, attrs)) }
+func (logger *DebugLogger) Warn(msg string, attrs ...Attr)  { logger.warn.Print(format(msg, attrs)) }
+func (logger *DebugLogger) Error(msg string, attrs ...Attr) { logger.err.Print(format(msg, attrs)) }
 
 // NopLogger doesn't log anything.
 type NopLogger struct{}
 
-func (NopLogger) Info(v ...any)                 {}
-func (NopLogger) Infof(format string, v ...any) {}
-func (NopLogger) Infoln(v ...any)               {}
+func (NopLogger) Debug(msg string, attrs ...Attr) {}
+func (NopLogger) Info(msg string, attrs ...Attr)  {}
+func (NopLogger) Warn(msg string, attrs ...Attr)  {}
+func (NopLogger) Error(msg string, attrs ...Attr) {}
+
+// PrintfLogger is the printf style logger interface used by previous versions of the driver.
+type PrintfLogger interface {
+	Info(v ...any)
+	Infof(format string, v ...any)
+	Infoln(v ...any)
+
+	Warn(v ...any)
+	Warnf(format string, v ...any)
+	Warnln(v ...any)
+}
+
+// FromPrintf adapts PrintfLogger to Logger, attributes are appended to the message,
+// debug messages are logged as info and errors as warnings.
+func FromPrintf(l PrintfLogger) Logger {
+	return printfAdapter{l: l}
+}
+
+type printfAdapter struct {
+	l PrintfLogger
+}
 
-func (NopLogger) Warn(v ...any)                 {}
-func (NopLogger) Warnf(format string, v ...any) {}
-func (NopLogger) Warnln(v ...any)               {}
+func (a printfAdapter) Debug(msg string, attrs ...Attr) { a.l.Info(format(msg, attrs)) }
+func (a printfAdapter) Info(msg string, attrs ...Attr)  { a.l.Info(format(msg, attrs)) }
+func (a printfAdapter) Warn(msg string, attrs ...Attr)  { a.l.Warn(format(msg, attrs)) }
+func (a printfAdapter) Error(msg string, attrs ...Attr) { a.l.Warn(format(msg, attrs)) }
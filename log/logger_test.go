@@ -0,0 +1,45 @@
+package log
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestFormat(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		name     string
+		msg      string
+		attrs    []Attr
+		expected string
+	}{
+		{
+			name:     "no attrs",
+			msg:      "pool: closed",
+			expected: "pool: closed",
+		},
+		{
+			name:     "plain values",
+			msg:      "conn: dial",
+			attrs:    []Attr{Node("192.168.100.100:9042"), Shard(3), Duration("took", time.Second)},
+			expected: "conn: dial node=192.168.100.100:9042 shard=3 took=1s",
+		},
+		{
+			name:     "quoted values",
+			msg:      "conn: error",
+			attrs:    []Attr{Err(errors.New("connection reset")), String("empty", "")},
+			expected: `conn: error err="connection reset" empty=""`,
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			if v := format(tc.msg, tc.attrs); v != tc.expected {
+				t.Fatalf("format() = %q, expected %q", v, tc.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,34 @@
+//go:build go1.21
+
+package log
+
+import (
+	"context"
+	"log/slog"
+)
+
+// NewSlogLogger returns Logger writing to l, attributes are passed as slog attributes.
+func NewSlogLogger(l *slog.Logger) Logger {
+	return slogAdapter{l: l}
+}
+
+type slogAdapter struct {
+	l *slog.Logger
+}
+
+func (a slogAdapter) log(level Level, msg string, attrs []Attr) {
+	ctx := context.Background()
+	if !a.l.Enabled(ctx, slog.Level(level)) {
+		return
+	}
+	sattrs := make([]slog.Attr, len(attrs))
+	for i, v := range attrs {
+		sattrs[i] = slog.Any(v.Key, v.Value)
+	}
+	a.l.LogAttrs(ctx, slog.Level(level), msg, sattrs...)
+}
+
+func (a slogAdapter) Debug(msg string, attrs ...Attr) { a.log(LevelDebug, msg, attrs) }
+func (a slogAdapter) Info(msg string, attrs ...Attr)  { a.log(LevelInfo, msg, attrs) }
+func (a slogAdapter) Warn(msg string, attrs ...Attr)  { a.log(LevelWarn, msg, attrs) }
+func (a slogAdapter) Error(msg string, attrs ...Attr) { a.log(LevelError, msg, attrs) }
@@ -0,0 +1,77 @@
+//go:build go1.21
+
+package log
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+// recordHandler is slog.Handler that records messages of level at least min.
+type recordHandler struct {
+	min     slog.Level
+	records []slog.Record
+}
+
+func (h *recordHandler) Enabled(_ context.Context, l slog.Level) bool { return l >= h.min }
+func (h *recordHandler) WithAttrs([]slog.Attr) slog.Handler           { return h }
+func (h *recordHandler) WithGroup(string) slog.Handler                { return h }
+
+func (h *recordHandler) Handle(_ context.Context, r slog.Record) error {
+	h.records = append(h.records, r)
+	return nil
+}
+
+func TestSlogLogger(t *testing.T) {
+	t.Parallel()
+	h := &recordHandler{min: slog.LevelInfo}
+	l := NewSlogLogger(slog.New(h))
+
+	l.Debug("conn: dial", Node("192.168.100.100:9042"))
+	l.Info("conn: dial", Node("192.168.100.100:9042"), Shard(3), Duration("took", time.Second))
+	l.Warn("conn: error", Err(errors.New("connection reset")))
+	l.Error("pool: closed")
+
+	expected := []struct {
+		level slog.Level
+		msg   string
+		attrs map[string]string
+	}{
+		{
+			level: slog.LevelInfo,
+			msg:   "conn: dial",
+			attrs: map[string]string{NodeKey: "192.168.100.100:9042", ShardKey: "3", "took": "1s"},
+		},
+		{
+			level: slog.LevelWarn,
+			msg:   "conn: error",
+			attrs: map[string]string{"err": "connection reset"},
+		},
+		{
+			level: slog.LevelError,
+			msg:   "pool: closed",
+			attrs: map[string]string{},
+		},
+	}
+	if len(h.records) != len(expected) {
+		t.Fatalf("expected %d records, got %d", len(expected), len(h.records))
+	}
+	for i, r := range h.records {
+		e := expected[i]
+		if r.Level != e.level || r.Message != e.msg {
+			t.Fatalf("record %d = %s %q, expected %s %q", i, r.Level, r.Message, e.level, e.msg)
+		}
+		attrs := make(map[string]string)
+		r.Attrs(func(a slog.Attr) bool {
+			attrs[a.Key] = fmt.Sprint(a.Value.Any())
+			return true
+		})
+		if fmt.Sprint(attrs) != fmt.Sprint(e.attrs) {
+			t.Fatalf("record %d attrs = %v, expected %v", i, attrs, e.attrs)
+		}
+	}
+}
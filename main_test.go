@@ -0,0 +1,21 @@
+package scylla_test
+
+import (
+	"testing"
+
+	scylla "github.com/scylladb/scylla-go-driver"
+	"github.com/scylladb/scylla-go-driver/scyllatest"
+
+	"go.uber.org/goleak"
+)
+
+func TestMain(m *testing.M) {
+	goleak.VerifyTestMain(m)
+}
+
+// testSessionConfig returns default session config connecting to all nodes of srv.
+func testSessionConfig(srv *scyllatest.Server) scylla.SessionConfig {
+	cfg := scylla.DefaultSessionConfig("", srv.Hosts()...)
+	cfg.ConnConfig = srv.ConnConfig()
+	return cfg
+}
@@ -0,0 +1,78 @@
+package scylla_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	scylla "github.com/scylladb/scylla-go-driver"
+	"github.com/scylladb/scylla-go-driver/frame"
+	. "github.com/scylladb/scylla-go-driver/frame/response"
+	"github.com/scylladb/scylla-go-driver/scyllatest"
+	"github.com/scylladb/scylla-go-driver/transport"
+)
+
+func TestSessionMetrics(t *testing.T) {
+	t.Parallel()
+	srv := scyllatest.NewServer(scyllatest.DefaultConfig(2))
+	defer srv.Close()
+
+	const query = "INSERT INTO ks.t (pk) VALUES (1)"
+	srv.On(query, scyllatest.Result{Err: ScyllaError{Code: frame.ErrCodeOverloaded, Message: "overloaded"}}, scyllatest.Result{})
+
+	m := transport.NewMemoryMetrics(nil)
+	cfg := testSessionConfig(srv)
+	cfg.Metrics = m
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	session, err := scylla.NewSession(ctx, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer session.Close()
+
+	q := session.Query(query)
+	q.SetIdempotent(true)
+	if _, err := q.Exec(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// Pools are filled in background.
+	var s transport.MetricsSnapshot
+	for {
+		s = m.Snapshot()
+		full := len(s.Pools) == 2
+		for _, p := range s.Pools {
+			full = full && p.Size == p.Capacity
+		}
+		if full {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			t.Fatalf("pools not filled: %+v", s.Pools)
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+
+	if s.Retries != 1 {
+		t.Fatalf("expected 1 retry, got %d", s.Retries)
+	}
+	if s.Errors[frame.ErrCodeOverloaded] != 1 {
+		t.Fatalf("expected 1 overloaded error, got %v", s.Errors)
+	}
+	var requests uint64
+	for _, v := range s.Shards {
+		requests += v.Requests
+	}
+	if requests == 0 {
+		t.Fatal("no requests recorded")
+	}
+	if s.BytesSent == 0 || s.BytesReceived == 0 || s.CompressionRatio() != 1 {
+		t.Fatalf("unexpected bytes sent %d received %d", s.BytesSent, s.BytesReceived)
+	}
+	if s.TopologyRefreshes == 0 || s.TopologyRefreshErrors != 0 {
+		t.Fatalf("unexpected topology refreshes %d errors %d", s.TopologyRefreshes, s.TopologyRefreshErrors)
+	}
+}
@@ -0,0 +1,224 @@
+package scylla
+
+import (
+	"context"
+	"time"
+
+	"github.com/scylladb/scylla-go-driver/frame"
+	"github.com/scylladb/scylla-go-driver/transport"
+)
+
+// QueryObserver is notified about execution of queries, it can be used for auditing,
+// tracing or collecting per query statistics. Pages of Iter are reported as separate queries.
+//
+// Methods are called synchronously from the goroutine executing the query,
+// they must be safe for concurrent use and should not block.
+type QueryObserver interface {
+	// OnQueryStart is called before the first attempt, returned context is used to execute the query
+	// and is passed to other methods, it may carry values such as tracing spans.
+	OnQueryStart(ctx context.Context, ev QueryStartEvent) context.Context
+	// OnAttempt is called before the statement is sent to a node, after interceptors modified it.
+	// Returned context is used to execute the attempt.
+	OnAttempt(ctx context.Context, ev AttemptEvent) context.Context
+	// OnAttemptEnd is called when attempt completes, ctx is the one returned by OnAttempt.
+	OnAttemptEnd(ctx context.Context, ev AttemptEndEvent)
+	// OnRetryDecision is called when attempt failed and retry policy decided what to do next.
+	OnRetryDecision(ctx context.Context, ev RetryDecisionEvent)
+	// OnQueryEnd is called when the query completes, ctx is the one returned by OnQueryStart.
+	OnQueryEnd(ctx context.Context, ev QueryEndEvent)
+}
+
+type QueryStartEvent struct {
+	Stmt transport.Statement
+	// Keyspace is the keyspace of prepared statement, or the session keyspace for other statements.
+	Keyspace string
+	// Paged is true for pages of Iter.
+	Paged bool
+}
+
+type AttemptEvent struct {
+	transport.ConnEvent
+
+	// Stmt is the statement sent, it's modified by interceptors.
+	Stmt transport.Statement
+	// Attempt is the attempt number starting with 0.
+	Attempt int
+	Node    *transport.Node
+	Conn    *transport.Conn
+}
+
+type AttemptEndEvent struct {
+	AttemptEvent
+
+	Latency time.Duration
+	Err     error
+}
+
+type RetryDecisionEvent struct {
+	AttemptEvent
+
+	Err      error
+	Decision transport.RetryDecision
+	// Delay is the time waited before the retry.
+	Delay time.Duration
+}
+
+type QueryEndEvent struct {
+	Stmt transport.Statement
+	// Attempts is the number of attempts made.
+	Attempts int
+	Latency  time.Duration
+	Rows     int
+	Err      error
+}
+
+// Interceptor is called before every attempt to execute a statement, it can modify the statement
+// e.g. to set custom payload or override consistency. Changes are not visible to subsequent attempts,
+// every attempt starts with the statement of the query. Returning an error fails the query without
+// sending the statement.
+type Interceptor func(ctx context.Context, ev AttemptEvent, stmt *transport.Statement) error
+
+// queryRun tracks a single execution of a query for QueryObserver, interceptors and slow query log.
+type queryRun struct {
+	cfg          *SessionConfig
+	obs          QueryObserver
+	interceptors []Interceptor
+	latency      transport.LatencyObserver
+
+	stmt         transport.Statement
+	paged        bool
+	start        time.Time
+	attempts     int
+	attemptStart time.Time
+	// last is the last attempt, it's used to log and re-run slow queries.
+	last AttemptEvent
+	// downgraded is set if retry policy lowered consistency of stmt.
+	downgraded bool
+
+	// exec, pagingState, cluster and tracer are used to re-run slow queries with tracing.
+	exec        queryExecFunc
+	pagingState frame.Bytes
+	cluster     *transport.Cluster
+	tracer      *tracer
+}
+
+type queryExecFunc func(context.Context, *transport.Conn, transport.Statement, frame.Bytes) (transport.QueryResult, error)
+
+func newQueryRun(ctx context.Context, cfg *SessionConfig, stmt transport.Statement, paged bool) (context.Context, queryRun) {
+	r := queryRun{
+		cfg:          cfg,
+		obs:          cfg.QueryObserver,
+		interceptors: cfg.Interceptors,
+		stmt:         stmt,
+		paged:        paged,
+		start:        time.Now(),
+	}
+	r.latency, _ = cfg.HostSelectionPolicy.(transport.LatencyObserver)
+	if r.obs != nil {
+		ctx = r.obs.OnQueryStart(ctx, QueryStartEvent{Stmt: stmt, Keyspace: stmtKeyspace(cfg, stmt), Paged: paged})
+	}
+	return ctx, r
+}
+
+func stmtKeyspace(cfg *SessionConfig, stmt transport.Statement) string {
+	if stmt.Keyspace != "" {
+		return stmt.Keyspace
+	}
+	if m := stmt.Metadata; m != nil {
+		if m.GlobalKeyspace != "" {
+			return m.GlobalKeyspace
+		}
+		if len(m.Columns) > 0 {
+			return m.Columns[0].Keyspace
+		}
+	}
+	return cfg.Keyspace
+}
+
+// attempt applies interceptors to a copy of stmt, takes node circuit breaker probe and notifies observer.
+func (r *queryRun) attempt(ctx context.Context, n *transport.Node, conn *transport.Conn) (context.Context, AttemptEvent, transport.Statement, error) {
+	ev := AttemptEvent{
+		ConnEvent: conn.Event(),
+		Stmt:      r.stmt,
+		Attempt:   r.attempts,
+		Node:      n,
+		Conn:      conn,
+	}
+
+	if len(r.interceptors) > 0 {
+		stmt := r.stmt.Clone()
+		for _, f := range r.interceptors {
+			if err := f(ctx, ev, &stmt); err != nil {
+				return ctx, ev, stmt, err
+			}
+		}
+		ev.Stmt = stmt
+	}
+	// Circuit breaker probes are taken only by attempts that are sent, not when the plan is iterated.
+	if err := n.AdmitAttempt(); err != nil {
+		return ctx, ev, ev.Stmt, err
+	}
+	r.attempts++
+	r.last = ev
+	r.attemptStart = time.Now()
+	if r.obs != nil {
+		ctx = r.obs.OnAttempt(ctx, ev)
+	}
+	return ctx, ev, ev.Stmt, nil
+}
+
+func (r *queryRun) attemptEnd(ctx context.Context, ev AttemptEvent, err error) {
+	latency := time.Since(r.attemptStart)
+	if ev.Node != nil {
+		ev.Node.ObserveAttempt(latency, err)
+		if r.latency != nil {
+			r.latency.ObserveLatency(ev.Node, latency, err)
+		}
+	}
+	if r.obs != nil {
+		r.obs.OnAttemptEnd(ctx, AttemptEndEvent{AttemptEvent: ev, Latency: latency, Err: err})
+	}
+}
+
+func (r *queryRun) retryDecision(ctx context.Context, ev AttemptEvent, err error, d transport.RetryDecision, delay time.Duration) {
+	if r.obs != nil {
+		r.obs.OnRetryDecision(ctx, RetryDecisionEvent{AttemptEvent: ev, Err: err, Decision: d, Delay: delay})
+	}
+}
+
+// downgrade lowers consistency of the following attempts if retry decider rd decided so.
+func (r *queryRun) downgrade(rd transport.RetryDecider, d transport.RetryDecision) {
+	if d == transport.DontRetry {
+		return
+	}
+	if v, ok := rd.(transport.ConsistencyDowngrader); ok {
+		if c, ok := v.RetryConsistency(); ok {
+			r.stmt.Consistency = c
+			r.downgraded = true
+		}
+	}
+}
+
+// setDowngraded reports downgraded consistency in the result of the successful attempt.
+func (r *queryRun) setDowngraded(res *transport.QueryResult) {
+	if r.downgraded {
+		res.Downgraded = true
+		res.Consistency = r.last.Stmt.Consistency
+	}
+}
+
+func (r *queryRun) end(ctx context.Context, res transport.QueryResult, err error) {
+	latency := time.Since(r.start)
+	if r.obs != nil {
+		r.obs.OnQueryEnd(ctx, QueryEndEvent{
+			Stmt:     r.stmt,
+			Attempts: r.attempts,
+			Latency:  latency,
+			Rows:     len(res.Rows),
+			Err:      err,
+		})
+	}
+	if r.isSlow(latency) {
+		r.logSlowQuery(ctx, latency, err)
+	}
+}
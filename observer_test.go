@@ -0,0 +1,185 @@
+package scylla_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	scylla "github.com/scylladb/scylla-go-driver"
+	"github.com/scylladb/scylla-go-driver/frame"
+	. "github.com/scylladb/scylla-go-driver/frame/response"
+	"github.com/scylladb/scylla-go-driver/scyllatest"
+	"github.com/scylladb/scylla-go-driver/transport"
+)
+
+type ctxKey struct{}
+
+// recordingObserver records events as strings, it checks that contexts are passed along.
+type recordingObserver struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (o *recordingObserver) add(format string, args ...interface{}) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.events = append(o.events, fmt.Sprintf(format, args...))
+}
+
+func (o *recordingObserver) OnQueryStart(ctx context.Context, ev scylla.QueryStartEvent) context.Context {
+	o.add("start %s paged=%v", ev.Stmt.Content, ev.Paged)
+	return context.WithValue(ctx, ctxKey{}, "query")
+}
+
+func (o *recordingObserver) OnAttempt(ctx context.Context, ev scylla.AttemptEvent) context.Context {
+	o.add("attempt %d node=%v consistency=%d ctx=%v", ev.Attempt, ev.Node != nil, ev.Stmt.Consistency, ctx.Value(ctxKey{}))
+	return context.WithValue(ctx, ctxKey{}, "attempt")
+}
+
+func (o *recordingObserver) OnAttemptEnd(ctx context.Context, ev scylla.AttemptEndEvent) {
+	o.add("attempt end %d err=%v ctx=%v", ev.Attempt, ev.Err != nil, ctx.Value(ctxKey{}))
+}
+
+func (o *recordingObserver) OnRetryDecision(ctx context.Context, ev scylla.RetryDecisionEvent) {
+	o.add("retry decision %d %d ctx=%v", ev.Attempt, ev.Decision, ctx.Value(ctxKey{}))
+}
+
+func (o *recordingObserver) OnQueryEnd(ctx context.Context, ev scylla.QueryEndEvent) {
+	o.add("end attempts=%d rows=%d err=%v ctx=%v", ev.Attempts, ev.Rows, ev.Err != nil, ctx.Value(ctxKey{}))
+}
+
+func (o *recordingObserver) Events() []string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return append([]string(nil), o.events...)
+}
+
+func TestQueryObserverAndInterceptor(t *testing.T) {
+	t.Parallel()
+	srv := scyllatest.NewServer(scyllatest.DefaultConfig(2))
+	defer srv.Close()
+
+	const query = "SELECT v FROM ks.t"
+	var (
+		mu   sync.Mutex
+		reqs []scyllatest.Request
+	)
+	srv.On(query,
+		scyllatest.Result{Err: ScyllaError{Code: frame.ErrCodeOverloaded, Message: "overloaded"}},
+		scyllatest.Result{
+			Columns: []frame.ColumnSpec{scyllatest.Column("v", frame.VarcharID)},
+			Rows:    []frame.Row{{{Value: []byte("a")}}, {{Value: []byte("b")}}},
+		},
+	)
+
+	// Handlers added later are consulted first.
+	srv.Handle(func(r scyllatest.Request) *scyllatest.Result {
+		if r.Query == query {
+			mu.Lock()
+			reqs = append(reqs, r)
+			mu.Unlock()
+		}
+		return nil
+	})
+
+	obs := &recordingObserver{}
+	cfg := testSessionConfig(srv)
+	cfg.DefaultConsistency = frame.QUORUM
+	cfg.QueryObserver = obs
+	cfg.Interceptors = []scylla.Interceptor{
+		func(ctx context.Context, ev scylla.AttemptEvent, stmt *transport.Statement) error {
+			if stmt.CustomPayload == nil {
+				stmt.CustomPayload = make(frame.BytesMap)
+			}
+			stmt.CustomPayload["tenant"] = []byte("t1")
+			return nil
+		},
+		func(ctx context.Context, ev scylla.AttemptEvent, stmt *transport.Statement) error {
+			if ev.Attempt > 0 {
+				stmt.Consistency = frame.ONE
+			}
+			return nil
+		},
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	session, err := scylla.NewSession(ctx, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer session.Close()
+
+	q := session.Query(query)
+	q.SetIdempotent(true)
+	res, err := q.Exec(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Rows) != 2 {
+		t.Fatalf("expected 2 rows, got %+v", res.Rows)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(reqs) != 2 {
+		t.Fatalf("expected 2 requests, got %+v", reqs)
+	}
+	for i, c := range []frame.Consistency{frame.QUORUM, frame.ONE} {
+		if reqs[i].Consistency != c || string(reqs[i].CustomPayload["tenant"]) != "t1" {
+			t.Fatalf("request %d: unexpected %+v", i, reqs[i])
+		}
+	}
+
+	expected := []string{
+		"start " + query + " paged=false",
+		fmt.Sprintf("attempt 0 node=true consistency=%d ctx=query", frame.QUORUM),
+		"attempt end 0 err=true ctx=attempt",
+		fmt.Sprintf("retry decision 0 %d ctx=query", transport.RetryNextNode),
+		fmt.Sprintf("attempt 1 node=true consistency=%d ctx=query", frame.ONE),
+		"attempt end 1 err=false ctx=attempt",
+		"end attempts=2 rows=2 err=false ctx=query",
+	}
+	events := obs.Events()
+	if len(events) != len(expected) {
+		t.Fatalf("expected events %q, got %q", expected, events)
+	}
+	for i := range expected {
+		if events[i] != expected[i] {
+			t.Fatalf("expected events %q, got %q", expected, events)
+		}
+	}
+}
+
+func TestInterceptorError(t *testing.T) {
+	t.Parallel()
+	srv := scyllatest.NewServer(scyllatest.DefaultConfig(1))
+	defer srv.Close()
+
+	errRejected := errors.New("rejected")
+	cfg := testSessionConfig(srv)
+	cfg.Interceptors = []scylla.Interceptor{
+		func(context.Context, scylla.AttemptEvent, *transport.Statement) error {
+			return errRejected
+		},
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	session, err := scylla.NewSession(ctx, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer session.Close()
+
+	q := session.Query("INSERT INTO ks.t (pk) VALUES (1)")
+	if _, err := q.Exec(ctx); !errors.Is(err, errRejected) {
+		t.Fatalf("expected rejected error, got %v", err)
+	}
+	if reqs := srv.Requests(); len(reqs) != 0 {
+		t.Fatalf("expected no requests, got %+v", reqs)
+	}
+}
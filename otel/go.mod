@@ -0,0 +1,22 @@
+module github.com/scylladb/scylla-go-driver/otel
+
+go 1.18
+
+require (
+	github.com/scylladb/scylla-go-driver v0.0.0
+	go.opentelemetry.io/otel v1.11.2
+	go.opentelemetry.io/otel/sdk v1.11.2
+	go.opentelemetry.io/otel/trace v1.11.2
+	go.uber.org/goleak v1.1.12
+)
+
+require (
+	github.com/go-logr/logr v1.2.3 // indirect
+	github.com/go-logr/stdr v1.2.2 // indirect
+	github.com/klauspost/compress v1.15.1 // indirect
+	github.com/pierrec/lz4/v4 v4.1.14 // indirect
+	go.uber.org/atomic v1.9.0 // indirect
+	golang.org/x/sys v0.0.0-20220919091848-fb04ddd9f9c8 // indirect
+)
+
+replace github.com/scylladb/scylla-go-driver => ../
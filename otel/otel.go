@@ -0,0 +1,266 @@
+// Package otel traces queries with OpenTelemetry.
+//
+// Tracer creates a span per Query.Exec and per Iter page, with a child span per attempt
+// to execute the statement on a node. Batches are not traced as the driver does not support them yet.
+//
+//	t := otel.NewTracer(otel.WithPropagation())
+//	cfg := scylla.DefaultSessionConfig("ks", hosts...)
+//	t.Configure(&cfg)
+//
+// The package is a separate module, so that the driver doesn't depend on OpenTelemetry.
+package otel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+
+	scylla "github.com/scylladb/scylla-go-driver"
+	"github.com/scylladb/scylla-go-driver/frame"
+	. "github.com/scylladb/scylla-go-driver/frame/response"
+	"github.com/scylladb/scylla-go-driver/transport"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/scylladb/scylla-go-driver/otel"
+
+// Span names.
+const (
+	QuerySpanName   = "scylla.query"
+	PageSpanName    = "scylla.iter_page"
+	AttemptSpanName = "scylla.attempt"
+	RetryEventName  = "scylla.retry"
+)
+
+// Span attributes, db.* attributes follow OpenTelemetry semantic conventions for Cassandra.
+const (
+	DBSystemKey        = attribute.Key("db.system")
+	DBStatementKey     = attribute.Key("db.statement")
+	KeyspaceKey        = attribute.Key("db.cassandra.keyspace")
+	ConsistencyKey     = attribute.Key("db.cassandra.consistency_level")
+	PageSizeKey        = attribute.Key("db.cassandra.page_size")
+	IdempotenceKey     = attribute.Key("db.cassandra.idempotence")
+	CoordinatorIDKey   = attribute.Key("db.cassandra.coordinator.id")
+	CoordinatorDCKey   = attribute.Key("db.cassandra.coordinator.dc")
+	CoordinatorAddrKey = attribute.Key("net.peer.name")
+	ShardKey           = attribute.Key("db.scylla.shard")
+	AttemptKey         = attribute.Key("db.scylla.attempt")
+	RetryCountKey      = attribute.Key("db.scylla.retry_count")
+	RetryDecisionKey   = attribute.Key("db.scylla.retry_decision")
+	RowsKey            = attribute.Key("db.scylla.rows")
+	ErrorCodeKey       = attribute.Key("db.scylla.error_code")
+)
+
+// dbSystem is the db.system value, semantic conventions have no value for Scylla.
+const dbSystem = "cassandra"
+
+type Option func(t *Tracer)
+
+// WithTracerProvider sets provider used to create tracer, by default the global provider is used.
+func WithTracerProvider(tp trace.TracerProvider) Option {
+	return func(t *Tracer) {
+		t.tracer = tp.Tracer(instrumentationName)
+	}
+}
+
+// WithPropagation enables propagation of trace context to Scylla in the custom payload
+// using the global propagator.
+func WithPropagation() Option {
+	return func(t *Tracer) {
+		t.propagator = otel.GetTextMapPropagator()
+	}
+}
+
+// WithPropagator enables propagation of trace context to Scylla in the custom payload using p.
+func WithPropagator(p propagation.TextMapPropagator) Option {
+	return func(t *Tracer) {
+		t.propagator = p
+	}
+}
+
+// Tracer is a scylla.QueryObserver creating OpenTelemetry spans.
+type Tracer struct {
+	tracer     trace.Tracer
+	propagator propagation.TextMapPropagator
+}
+
+var _ scylla.QueryObserver = (*Tracer)(nil)
+
+func NewTracer(opts ...Option) *Tracer {
+	t := &Tracer{}
+	for _, opt := range opts {
+		opt(t)
+	}
+	if t.tracer == nil {
+		t.tracer = otel.GetTracerProvider().Tracer(instrumentationName)
+	}
+	return t
+}
+
+// Configure sets t as the session query observer and, if propagation is enabled,
+// adds interceptor injecting trace context into custom payload of statements.
+func (t *Tracer) Configure(cfg *scylla.SessionConfig) {
+	cfg.QueryObserver = t
+	if t.propagator != nil {
+		cfg.Interceptors = append(cfg.Interceptors, t.Inject)
+	}
+}
+
+// Inject is a scylla.Interceptor adding trace context of the query span to statement custom payload.
+func (t *Tracer) Inject(ctx context.Context, _ scylla.AttemptEvent, stmt *transport.Statement) error {
+	if t.propagator == nil {
+		return nil
+	}
+	if stmt.CustomPayload == nil {
+		stmt.CustomPayload = make(frame.BytesMap)
+	}
+	t.propagator.Inject(ctx, payloadCarrier(stmt.CustomPayload))
+	return nil
+}
+
+func (t *Tracer) OnQueryStart(ctx context.Context, ev scylla.QueryStartEvent) context.Context {
+	name := QuerySpanName
+	if ev.Paged {
+		name = PageSpanName
+	}
+	attrs := []attribute.KeyValue{
+		DBSystemKey.String(dbSystem),
+		DBStatementKey.String(ev.Stmt.Content),
+		ConsistencyKey.String(consistencyName(ev.Stmt.Consistency)),
+		IdempotenceKey.Bool(ev.Stmt.Idempotent),
+	}
+	if ev.Keyspace != "" {
+		attrs = append(attrs, KeyspaceKey.String(ev.Keyspace))
+	}
+	if ev.Stmt.PageSize > 0 {
+		attrs = append(attrs, PageSizeKey.Int(int(ev.Stmt.PageSize)))
+	}
+	ctx, _ = t.tracer.Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attrs...))
+	return ctx
+}
+
+func (t *Tracer) OnAttempt(ctx context.Context, ev scylla.AttemptEvent) context.Context {
+	attrs := []attribute.KeyValue{
+		AttemptKey.Int(ev.Attempt),
+		ConsistencyKey.String(consistencyName(ev.Stmt.Consistency)),
+		CoordinatorAddrKey.String(host(ev.Addr)),
+	}
+	if ev.Shard != transport.UnknownShard {
+		attrs = append(attrs, ShardKey.Int(int(ev.Shard)))
+	}
+	if ev.Node != nil {
+		info := ev.Node.Info()
+		attrs = append(attrs,
+			CoordinatorIDKey.String(uuidString(info.HostID)),
+			CoordinatorDCKey.String(info.Datacenter),
+		)
+	}
+	ctx, _ = t.tracer.Start(ctx, AttemptSpanName, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attrs...))
+	return ctx
+}
+
+func (t *Tracer) OnAttemptEnd(ctx context.Context, ev scylla.AttemptEndEvent) {
+	span := trace.SpanFromContext(ctx)
+	setError(span, ev.Err)
+	span.End()
+}
+
+func (t *Tracer) OnRetryDecision(ctx context.Context, ev scylla.RetryDecisionEvent) {
+	trace.SpanFromContext(ctx).AddEvent(RetryEventName, trace.WithAttributes(
+		AttemptKey.Int(ev.Attempt),
+		RetryDecisionKey.String(decisionName(ev.Decision)),
+	))
+}
+
+func (t *Tracer) OnQueryEnd(ctx context.Context, ev scylla.QueryEndEvent) {
+	span := trace.SpanFromContext(ctx)
+	retries := ev.Attempts - 1
+	if retries < 0 {
+		retries = 0
+	}
+	span.SetAttributes(RetryCountKey.Int(retries), RowsKey.Int(ev.Rows))
+	setError(span, ev.Err)
+	span.End()
+}
+
+func setError(span trace.Span, err error) {
+	if err == nil {
+		return
+	}
+	var coded CodedError
+	if errors.As(err, &coded) {
+		span.SetAttributes(ErrorCodeKey.Int(int(coded.ErrorCode())))
+	}
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
+
+// payloadCarrier adapts custom payload to propagation.TextMapCarrier.
+type payloadCarrier frame.BytesMap
+
+func (c payloadCarrier) Get(key string) string {
+	return string(c[key])
+}
+
+func (c payloadCarrier) Set(key, value string) {
+	c[key] = frame.Bytes(value)
+}
+
+func (c payloadCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for k := range c {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+var consistencyNames = map[frame.Consistency]string{
+	frame.ANY:         "any",
+	frame.ONE:         "one",
+	frame.TWO:         "two",
+	frame.THREE:       "three",
+	frame.QUORUM:      "quorum",
+	frame.ALL:         "all",
+	frame.LOCALQUORUM: "local_quorum",
+	frame.EACHQUORUM:  "each_quorum",
+	frame.SERIAL:      "serial",
+	frame.LOCALSERIAL: "local_serial",
+	frame.LOCALONE:    "local_one",
+}
+
+func consistencyName(c frame.Consistency) string {
+	if v, ok := consistencyNames[c]; ok {
+		return v
+	}
+	return fmt.Sprintf("0x%04x", c)
+}
+
+func decisionName(d transport.RetryDecision) string {
+	switch d {
+	case transport.RetrySameNode:
+		return "retry_same_node"
+	case transport.RetryNextNode:
+		return "retry_next_node"
+	case transport.DontRetry:
+		return "dont_retry"
+	default:
+		return fmt.Sprintf("%d", d)
+	}
+}
+
+func host(addr string) string {
+	if h, _, err := net.SplitHostPort(addr); err == nil {
+		return h
+	}
+	return addr
+}
+
+func uuidString(u frame.UUID) string {
+	return fmt.Sprintf("%x-%x-%x-%x-%x", u[0:4], u[4:6], u[6:8], u[8:10], u[10:16])
+}
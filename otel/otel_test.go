@@ -0,0 +1,147 @@
+package otel_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	scylla "github.com/scylladb/scylla-go-driver"
+	"github.com/scylladb/scylla-go-driver/frame"
+	. "github.com/scylladb/scylla-go-driver/frame/response"
+	scyllaotel "github.com/scylladb/scylla-go-driver/otel"
+	"github.com/scylladb/scylla-go-driver/scyllatest"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.uber.org/goleak"
+)
+
+func TestMain(m *testing.M) {
+	goleak.VerifyTestMain(m)
+}
+
+func attrs(s sdktrace.ReadOnlySpan) map[attribute.Key]attribute.Value {
+	m := make(map[attribute.Key]attribute.Value)
+	for _, kv := range s.Attributes() {
+		m[kv.Key] = kv.Value
+	}
+	return m
+}
+
+func TestTracer(t *testing.T) {
+	t.Parallel()
+	srv := scyllatest.NewServer(scyllatest.DefaultConfig(2))
+	defer srv.Close()
+
+	const query = "SELECT v FROM ks.t"
+	var (
+		mu       sync.Mutex
+		payloads []frame.BytesMap
+	)
+	srv.On(query,
+		scyllatest.Result{Err: ScyllaError{Code: frame.ErrCodeOverloaded, Message: "overloaded"}},
+		scyllatest.Result{
+			Columns: []frame.ColumnSpec{scyllatest.Column("v", frame.VarcharID)},
+			Rows:    []frame.Row{{{Value: []byte("a")}}},
+		},
+	)
+	srv.Handle(func(r scyllatest.Request) *scyllatest.Result {
+		if r.Query == query {
+			mu.Lock()
+			payloads = append(payloads, r.CustomPayload)
+			mu.Unlock()
+		}
+		return nil
+	})
+
+	rec := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec))
+	tracer := scyllaotel.NewTracer(scyllaotel.WithTracerProvider(tp), scyllaotel.WithPropagator(propagation.TraceContext{}))
+
+	cfg := scylla.DefaultSessionConfig("ks", srv.Hosts()...)
+	cfg.ConnConfig = srv.ConnConfig()
+	cfg.Keyspace = "ks"
+	cfg.DefaultConsistency = frame.QUORUM
+	tracer.Configure(&cfg)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	session, err := scylla.NewSession(ctx, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer session.Close()
+
+	q := session.Query(query)
+	q.SetIdempotent(true)
+	if _, err := q.Exec(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	spans := rec.Ended()
+	if len(spans) != 3 {
+		t.Fatalf("expected 3 spans, got %d", len(spans))
+	}
+	// Attempts end before the query.
+	a0, a1, qs := spans[0], spans[1], spans[2]
+	if qs.Name() != scyllaotel.QuerySpanName || a0.Name() != scyllaotel.AttemptSpanName || a1.Name() != scyllaotel.AttemptSpanName {
+		t.Fatalf("unexpected span names %s %s %s", qs.Name(), a0.Name(), a1.Name())
+	}
+	for _, a := range []sdktrace.ReadOnlySpan{a0, a1} {
+		if a.Parent().SpanID() != qs.SpanContext().SpanID() {
+			t.Fatal("attempt span is not a child of query span")
+		}
+	}
+
+	qa := attrs(qs)
+	for k, v := range map[attribute.Key]attribute.Value{
+		scyllaotel.DBSystemKey:    attribute.StringValue("cassandra"),
+		scyllaotel.DBStatementKey: attribute.StringValue(query),
+		scyllaotel.KeyspaceKey:    attribute.StringValue("ks"),
+		scyllaotel.ConsistencyKey: attribute.StringValue("quorum"),
+		scyllaotel.RetryCountKey:  attribute.IntValue(1),
+		scyllaotel.RowsKey:        attribute.IntValue(1),
+	} {
+		if qa[k] != v {
+			t.Errorf("query span %s = %v, expected %v", k, qa[k].Emit(), v.Emit())
+		}
+	}
+	if qs.Status().Code == codes.Error {
+		t.Errorf("query span has error status")
+	}
+	if ev := qs.Events(); len(ev) != 1 || ev[0].Name != scyllaotel.RetryEventName {
+		t.Errorf("expected retry event, got %+v", ev)
+	}
+
+	aa := attrs(a0)
+	if aa[scyllaotel.ErrorCodeKey] != attribute.IntValue(int(frame.ErrCodeOverloaded)) || a0.Status().Code != codes.Error {
+		t.Errorf("first attempt should fail with overloaded, got %+v", aa)
+	}
+	if _, ok := aa[scyllaotel.CoordinatorAddrKey]; !ok {
+		t.Errorf("missing coordinator address")
+	}
+	if _, ok := aa[scyllaotel.ShardKey]; !ok {
+		t.Errorf("missing shard")
+	}
+	if a1.Status().Code == codes.Error {
+		t.Errorf("second attempt should succeed")
+	}
+
+	// Trace context of the query span is sent in custom payload.
+	mu.Lock()
+	defer mu.Unlock()
+	if len(payloads) != 2 {
+		t.Fatalf("expected 2 requests, got %d", len(payloads))
+	}
+	sc := qs.SpanContext()
+	expected := "00-" + sc.TraceID().String() + "-" + sc.SpanID().String() + "-01"
+	for _, p := range payloads {
+		if v := string(p["traceparent"]); v != expected {
+			t.Fatalf("traceparent %q, expected %q", v, expected)
+		}
+	}
+}
@@ -2,19 +2,22 @@ package scylla
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"time"
 
 	"github.com/scylladb/scylla-go-driver/frame"
 	"github.com/scylladb/scylla-go-driver/transport"
 )
 
 type Query struct {
-	session   *Session
-	stmt      transport.Statement
-	buf       frame.Buffer
-	exec      func(context.Context, *transport.Conn, transport.Statement, frame.Bytes) (transport.QueryResult, error)
-	asyncExec func(context.Context, *transport.Conn, transport.Statement, frame.Bytes, transport.ResponseHandler)
-	res       []transport.ResponseHandler
+	session     *Session
+	stmt        transport.Statement
+	retryPolicy transport.RetryPolicy
+	buf         frame.Buffer
+	exec        func(context.Context, *transport.Conn, transport.Statement, frame.Bytes) (transport.QueryResult, error)
+	asyncExec   func(context.Context, *transport.Conn, transport.Statement, frame.Bytes, transport.ResponseHandler)
+	res         []transport.ResponseHandler
 }
 
 func (q *Query) Exec(ctx context.Context) (Result, error) {
@@ -23,6 +26,18 @@ func (q *Query) Exec(ctx context.Context) (Result, error) {
 		return Result{}, err
 	}
 
+	ctx, run := newQueryRun(ctx, &q.session.cfg, q.stmt, false)
+	run.exec, run.cluster, run.tracer = q.exec, q.session.cluster, q.session.tracer
+	res, err := q.execWithRetries(ctx, info, &run)
+	run.end(ctx, res, err)
+	if err != nil {
+		return Result{}, err
+	}
+	q.session.addTablet(q.stmt, res)
+	return Result(res), q.session.handleAutoAwaitSchemaAgreement(ctx, q.stmt.Content, &res)
+}
+
+func (q *Query) execWithRetries(ctx context.Context, info transport.QueryInfo, run *queryRun) (transport.QueryResult, error) {
 	// Most queries don't need retries, rd will be allocated on first failure.
 	var rd transport.RetryDecider
 	var lastErr error
@@ -37,29 +52,58 @@ func (q *Query) Exec(ctx context.Context) (Result, error) {
 				break sameNodeRetries
 			}
 
-			res, err := q.exec(ctx, conn, q.stmt, nil)
+			if err := q.session.cluster.Admit(ctx, n); err != nil {
+				if skipNode(err) {
+					lastErr = err
+					break sameNodeRetries
+				}
+				return transport.QueryResult{}, err
+			}
+			actx, ev, stmt, err := run.attempt(ctx, n, conn)
+			if err != nil {
+				q.session.cluster.Release(n)
+				if skipNode(err) {
+					lastErr = err
+					break sameNodeRetries
+				}
+				return transport.QueryResult{}, err
+			}
+			res, err := q.exec(actx, conn, stmt, nil)
+			q.session.cluster.Release(n)
+			run.attemptEnd(actx, ev, err)
 			if err != nil {
 				ri := transport.RetryInfo{
 					Error:       err,
-					Idempotent:  q.stmt.Idempotent,
-					Consistency: q.stmt.Consistency,
+					Idempotent:  stmt.Idempotent,
+					Consistency: stmt.Consistency,
 				}
 
 				if rd == nil {
-					rd = q.session.cfg.RetryPolicy.NewRetryDecider()
+					rd = q.RetryPolicy().NewRetryDecider()
+				}
+				d := rd.Decide(ri)
+				delay := retryDelay(rd, d)
+				run.downgrade(rd, d)
+				run.retryDecision(ctx, ev, err, d, delay)
+				if d != transport.DontRetry && q.session.cfg.Metrics != nil {
+					q.session.cfg.Metrics.OnRetry(transport.RetryEvent{ConnEvent: ev.ConnEvent, Err: err, Decision: d})
+				}
+				if err := sleep(ctx, delay); err != nil {
+					return transport.QueryResult{}, err
 				}
-				switch rd.Decide(ri) {
+				switch d {
 				case transport.RetrySameNode:
 					continue sameNodeRetries
 				case transport.RetryNextNode:
 					lastErr = err
 					break sameNodeRetries
 				case transport.DontRetry:
-					return Result{}, err
+					return transport.QueryResult{}, err
 				}
 			}
 
-			return Result(res), q.session.handleAutoAwaitSchemaAgreement(ctx, q.stmt.Content, &res)
+			run.setDowngraded(&res)
+			return res, nil
 		}
 
 		i++
@@ -67,37 +111,87 @@ func (q *Query) Exec(ctx context.Context) (Result, error) {
 	}
 
 	if lastErr == nil {
-		return Result{}, ErrNoConnection
+		return transport.QueryResult{}, ErrNoConnection
+	}
+	return transport.QueryResult{}, lastErr
+}
+
+// skipNode reports if attempt rejected with err before being sent to node can be sent to the next node of the plan.
+func skipNode(err error) bool {
+	return errors.Is(err, transport.ErrNodeOverloaded) || errors.Is(err, transport.ErrCircuitOpen)
+}
+
+// retryDelay returns delay before retry decided by rd.
+func retryDelay(rd transport.RetryDecider, d transport.RetryDecision) time.Duration {
+	if d == transport.DontRetry {
+		return 0
+	}
+	if v, ok := rd.(transport.RetryDelayer); ok {
+		return v.RetryDelay()
+	}
+	return 0
+}
+
+// sleep waits for d, it returns context error if ctx is done first.
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
 	}
-	return Result{}, lastErr
 }
 
-func (q *Query) pickConn(qi transport.QueryInfo) (*transport.Conn, error) {
+func (q *Query) pickConn(qi transport.QueryInfo) (*transport.Node, *transport.Conn, error) {
 	n := q.session.cfg.HostSelectionPolicy.Node(qi, 0)
+	if n == nil {
+		return nil, nil, ErrNoConnection
+	}
 
 	conn, err := n.Conn(qi)
 	if err != nil {
-		return nil, ErrNoConnection
+		return nil, nil, ErrNoConnection
 	}
 
-	return conn, nil
+	return n, conn, nil
 }
 
+// AsyncExec sends the query without waiting for response, results are read with Fetch.
+// Request counts towards admission limits until the response is received.
 func (q *Query) AsyncExec(ctx context.Context) {
 	stmt := q.stmt.Clone()
 	info, err := q.info()
 	if err != nil {
 		q.res = append(q.res, transport.MakeResponseHandlerWithError(err))
+		return
 	}
 
-	conn, err := q.pickConn(info)
+	n, conn, err := q.pickConn(info)
 	if err != nil {
 		q.res = append(q.res, transport.MakeResponseHandlerWithError(err))
 		return
 	}
+	if err := q.session.cluster.Admit(ctx, n); err != nil {
+		q.res = append(q.res, transport.MakeResponseHandlerWithError(err))
+		return
+	}
 
+	// Node is released as soon as the response arrives, so that responses which are never fetched
+	// don't hold admission limits.
+	cluster := q.session.cluster
 	h := transport.MakeResponseHandler()
-	q.res = append(q.res, h)
+	res := transport.MakeResponseHandler()
+	go func() {
+		resp := <-h
+		cluster.Release(n)
+		res <- resp
+	}()
+	q.res = append(q.res, res)
 	q.asyncExec(ctx, conn, stmt, nil, h)
 }
 
@@ -144,8 +238,8 @@ func (q *Query) token() (transport.Token, bool) {
 func (q *Query) info() (transport.QueryInfo, error) {
 	token, tokenAware := q.token()
 	if tokenAware {
-		// TODO: Will the driver support using different keyspaces than default?
-		info, err := q.session.cluster.NewTokenAwareQueryInfo(token, "")
+		// Prepared statements are routed by their keyspace, empty keyspace means the session keyspace.
+		info, err := q.session.cluster.NewTabletAwareQueryInfo(token, q.stmt.Keyspace, q.stmt.Table)
 		return info, err
 	}
 
@@ -195,6 +289,18 @@ func (q *Query) Idempotent() bool {
 	return q.stmt.Idempotent
 }
 
+// SetRetryPolicy sets retry policy of the query, nil means the session RetryPolicy.
+func (q *Query) SetRetryPolicy(p transport.RetryPolicy) {
+	q.retryPolicy = p
+}
+
+func (q *Query) RetryPolicy() transport.RetryPolicy {
+	if q.retryPolicy == nil {
+		return q.session.cfg.RetryPolicy
+	}
+	return q.retryPolicy
+}
+
 type Result transport.QueryResult
 
 // Iter returns an iterator that can be used to read paged queries row by row.
@@ -214,10 +320,14 @@ func (q *Query) Iter(ctx context.Context) Iter {
 	worker := iterWorker{
 		stmt: q.stmt.Clone(),
 
-		rd:        q.session.cfg.RetryPolicy.NewRetryDecider(),
+		rd:        q.RetryPolicy().NewRetryDecider(),
+		cfg:       &q.session.cfg,
+		cluster:   q.session.cluster,
+		tracer:    q.session.tracer,
 		queryInfo: info,
 		pickNode:  q.session.cfg.HostSelectionPolicy.Node,
 		queryExec: q.exec,
+		addTablet: q.session.addTablet,
 
 		requestCh: it.requestCh,
 		nextCh:    it.nextCh,
@@ -290,14 +400,19 @@ type iterWorker struct {
 	stmt        transport.Statement
 	pagingState []byte
 	queryExec   func(context.Context, *transport.Conn, transport.Statement, frame.Bytes) (transport.QueryResult, error)
+	addTablet   func(transport.Statement, transport.QueryResult)
 
+	cluster   *transport.Cluster
+	tracer    *tracer
 	queryInfo transport.QueryInfo
 	pickNode  func(transport.QueryInfo, int) *transport.Node
 	nodeIdx   int
+	node      *transport.Node
 	conn      *transport.Conn
 	connErr   error
 
-	rd transport.RetryDecider
+	rd  transport.RetryDecider
+	cfg *SessionConfig
 
 	requestCh chan struct{}
 	nextCh    chan transport.QueryResult
@@ -310,6 +425,7 @@ func (w *iterWorker) loop(ctx context.Context) {
 		w.errCh <- fmt.Errorf("can't pick a node to execute request")
 		return
 	}
+	w.node = n
 	w.conn, w.connErr = n.Conn(w.queryInfo)
 
 	for {
@@ -334,6 +450,17 @@ func (w *iterWorker) loop(ctx context.Context) {
 }
 
 func (w *iterWorker) exec(ctx context.Context) (transport.QueryResult, error) {
+	ctx, run := newQueryRun(ctx, w.cfg, w.stmt, true)
+	run.exec, run.pagingState, run.cluster, run.tracer = w.queryExec, w.pagingState, w.cluster, w.tracer
+	res, err := w.execWithRetries(ctx, &run)
+	run.end(ctx, res, err)
+	if err == nil {
+		w.addTablet(w.stmt, res)
+	}
+	return res, err
+}
+
+func (w *iterWorker) execWithRetries(ctx context.Context, run *queryRun) (transport.QueryResult, error) {
 	w.rd.Reset()
 	var lastErr error
 	for {
@@ -343,15 +470,43 @@ func (w *iterWorker) exec(ctx context.Context) (transport.QueryResult, error) {
 				lastErr = w.connErr
 				break
 			}
-			res, err := w.queryExec(ctx, w.conn, w.stmt, w.pagingState)
+			if err := w.cluster.Admit(ctx, w.node); err != nil {
+				if skipNode(err) {
+					lastErr = err
+					break sameNodeRetries
+				}
+				return transport.QueryResult{}, err
+			}
+			actx, ev, stmt, err := run.attempt(ctx, w.node, w.conn)
+			if err != nil {
+				w.cluster.Release(w.node)
+				if skipNode(err) {
+					lastErr = err
+					break sameNodeRetries
+				}
+				return transport.QueryResult{}, err
+			}
+			res, err := w.queryExec(actx, w.conn, stmt, w.pagingState)
+			w.cluster.Release(w.node)
+			run.attemptEnd(actx, ev, err)
 			if err != nil {
 				ri := transport.RetryInfo{
 					Error:       err,
-					Idempotent:  w.stmt.Idempotent,
-					Consistency: w.stmt.Consistency,
+					Idempotent:  stmt.Idempotent,
+					Consistency: stmt.Consistency,
 				}
 
-				switch w.rd.Decide(ri) {
+				d := w.rd.Decide(ri)
+				delay := retryDelay(w.rd, d)
+				run.downgrade(w.rd, d)
+				run.retryDecision(ctx, ev, err, d, delay)
+				if d != transport.DontRetry && w.cfg.Metrics != nil {
+					w.cfg.Metrics.OnRetry(transport.RetryEvent{ConnEvent: ev.ConnEvent, Err: err, Decision: d})
+				}
+				if err := sleep(ctx, delay); err != nil {
+					return transport.QueryResult{}, err
+				}
+				switch d {
 				case transport.RetrySameNode:
 					continue sameNodeRetries
 				case transport.RetryNextNode:
@@ -362,6 +517,7 @@ func (w *iterWorker) exec(ctx context.Context) (transport.QueryResult, error) {
 				}
 			}
 
+			run.setDowngraded(&res)
 			return res, nil
 		}
 
@@ -374,6 +530,7 @@ func (w *iterWorker) exec(ctx context.Context) (transport.QueryResult, error) {
 			return transport.QueryResult{}, lastErr
 		}
 
+		w.node = n
 		w.conn, w.connErr = n.Conn(w.queryInfo)
 	}
 }
@@ -0,0 +1,150 @@
+package scylla_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	scylla "github.com/scylladb/scylla-go-driver"
+	"github.com/scylladb/scylla-go-driver/frame"
+	. "github.com/scylladb/scylla-go-driver/frame/response"
+	"github.com/scylladb/scylla-go-driver/scyllatest"
+	"github.com/scylladb/scylla-go-driver/transport"
+	"go.uber.org/atomic"
+)
+
+func TestExponentialBackoffRetry(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name     string
+		policy   transport.RetryPolicy
+		timeout  time.Duration
+		attempts int
+		minTime  time.Duration
+		err      error
+	}{
+		{
+			name:     "retries are delayed",
+			policy:   transport.NewExponentialBackoffRetryPolicy(3, 20*time.Millisecond, time.Second, 0),
+			timeout:  10 * time.Second,
+			attempts: 3,
+			minTime:  60 * time.Millisecond,
+		},
+		{
+			name:     "context done during delay",
+			policy:   transport.NewExponentialBackoffRetryPolicy(3, time.Minute, time.Minute, 0),
+			timeout:  100 * time.Millisecond,
+			attempts: 1,
+			err:      context.DeadlineExceeded,
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			srv := scyllatest.NewServer(scyllatest.DefaultConfig(3))
+			defer srv.Close()
+
+			const query = "INSERT INTO ks.t (pk) VALUES (1)"
+			var attempts atomic.Int32
+			srv.Handle(func(r scyllatest.Request) *scyllatest.Result {
+				if r.Query != query {
+					return nil
+				}
+				attempts.Inc()
+				return &scyllatest.Result{Err: ScyllaError{Code: frame.ErrCodeOverloaded, Message: "overloaded"}}
+			})
+
+			cfg := testSessionConfig(srv)
+			cfg.RetryPolicy = tc.policy
+			session, err := scylla.NewSession(context.Background(), cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer session.Close()
+
+			ctx, cancel := context.WithTimeout(context.Background(), tc.timeout)
+			defer cancel()
+			q := session.Query(query)
+			q.SetIdempotent(true)
+			start := time.Now()
+			_, err = q.Exec(ctx)
+			elapsed := time.Since(start)
+
+			var coded CodedError
+			switch {
+			case tc.err != nil && !errors.Is(err, tc.err):
+				t.Fatalf("expected %v, got %v", tc.err, err)
+			case tc.err == nil && !(errors.As(err, &coded) && coded.ErrorCode() == frame.ErrCodeOverloaded):
+				t.Fatalf("expected overloaded error, got %v", err)
+			}
+			if elapsed < tc.minTime || elapsed > tc.timeout+time.Second {
+				t.Fatalf("query took %v", elapsed)
+			}
+			if v := int(attempts.Load()); v != tc.attempts {
+				t.Fatalf("expected %d attempts, got %d", tc.attempts, v)
+			}
+		})
+	}
+}
+
+func TestDowngradingConsistencyRetry(t *testing.T) {
+	t.Parallel()
+	srv := scyllatest.NewServer(scyllatest.DefaultConfig(2))
+	defer srv.Close()
+
+	const query = "SELECT v FROM ks.t"
+	var consistencies []frame.Consistency
+	srv.Handle(func(r scyllatest.Request) *scyllatest.Result {
+		if r.Query != query {
+			return nil
+		}
+		// Handlers are called with server lock held.
+		consistencies = append(consistencies, r.Consistency)
+		if len(consistencies) == 1 {
+			return &scyllatest.Result{Err: UnavailableError{
+				ScyllaError: ScyllaError{Code: frame.ErrCodeUnavailable, Message: "unavailable"},
+				Consistency: r.Consistency,
+				Required:    2,
+				Alive:       1,
+			}}
+		}
+		return &scyllatest.Result{}
+	})
+
+	cfg := testSessionConfig(srv)
+	cfg.DefaultConsistency = frame.QUORUM
+	cfg.RetryPolicy = transport.NewDowngradingConsistencyRetryPolicy()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	session, err := scylla.NewSession(ctx, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer session.Close()
+
+	q := session.Query(query)
+	res, err := q.Exec(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.Downgraded || res.Consistency != frame.ONE {
+		t.Fatalf("expected downgrade to ONE, got %v %v", res.Downgraded, res.Consistency)
+	}
+	if len(consistencies) != 2 || consistencies[0] != frame.QUORUM || consistencies[1] != frame.ONE {
+		t.Fatalf("unexpected consistencies %v", consistencies)
+	}
+
+	// Downgrade doesn't affect subsequent executions.
+	res, err = q.Exec(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Downgraded || consistencies[2] != frame.QUORUM {
+		t.Fatalf("unexpected downgrade %v %v", res.Downgraded, consistencies)
+	}
+}
@@ -0,0 +1,218 @@
+package scyllatest
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/scylladb/scylla-go-driver/frame"
+	. "github.com/scylladb/scylla-go-driver/frame/response"
+)
+
+const responseVersion = frame.CQLv4 | 0x80
+
+// Result kinds, see https://github.com/apache/cassandra/blob/adcff3f630c0d07d1ba33bf23fcb11a6db1b9af1/doc/native_protocol_v4.spec#L546
+const (
+	voidKind         frame.Int = 1
+	rowsKind         frame.Int = 2
+	setKeyspaceKind  frame.Int = 3
+	preparedKind     frame.Int = 4
+	schemaChangeKind frame.Int = 5
+)
+
+func writeOption(b *frame.Buffer, o frame.Option) {
+	b.WriteShort(frame.Short(o.ID))
+	switch o.ID {
+	case frame.CustomID:
+		b.WriteString(o.Custom.Name)
+	case frame.ListID:
+		writeOption(b, o.List.Element)
+	case frame.SetID:
+		writeOption(b, o.Set.Element)
+	case frame.MapID:
+		writeOption(b, o.Map.Key)
+		writeOption(b, o.Map.Value)
+	case frame.TupleID:
+		b.WriteShort(frame.Short(len(o.Tuple.ValueTypes)))
+		for _, v := range o.Tuple.ValueTypes {
+			writeOption(b, v)
+		}
+	}
+}
+
+func writeColumns(b *frame.Buffer, cols []frame.ColumnSpec) {
+	for _, c := range cols {
+		b.WriteString(c.Keyspace)
+		b.WriteString(c.Table)
+		b.WriteString(c.Name)
+		writeOption(b, c.Type)
+	}
+}
+
+func writeResultMetadata(b *frame.Buffer, cols []frame.ColumnSpec, pagingState frame.Bytes) {
+	var flags frame.ResultFlags
+	if pagingState != nil {
+		flags |= frame.HasMorePages
+	}
+	b.WriteInt(flags)
+	b.WriteInt(frame.Int(len(cols)))
+	if pagingState != nil {
+		b.WriteBytes(pagingState)
+	}
+	writeColumns(b, cols)
+}
+
+func writeRows(b *frame.Buffer, res *Result) {
+	b.WriteInt(rowsKind)
+	writeResultMetadata(b, res.Columns, res.PagingState)
+	b.WriteInt(frame.Int(len(res.Rows)))
+	for _, r := range res.Rows {
+		for _, v := range r {
+			b.WriteBytes(v.Value)
+		}
+	}
+}
+
+func writePrepared(b *frame.Buffer, id []byte, m PreparedMetadata) {
+	b.WriteInt(preparedKind)
+	b.WriteShortBytes(id)
+
+	b.WriteInt(0) // Flags
+	b.WriteInt(frame.Int(len(m.BindColumns)))
+	b.WriteInt(frame.Int(len(m.PkIndexes)))
+	for _, v := range m.PkIndexes {
+		b.WriteShort(v)
+	}
+	writeColumns(b, m.BindColumns)
+
+	writeResultMetadata(b, m.ResultColumns, nil)
+}
+
+func writeError(b *frame.Buffer, err CodedError) {
+	switch v := err.(type) {
+	case UnavailableError:
+		writeScyllaError(b, v.ScyllaError)
+		b.WriteConsistency(v.Consistency)
+		b.WriteInt(v.Required)
+		b.WriteInt(v.Alive)
+	case WriteTimeoutError:
+		writeScyllaError(b, v.ScyllaError)
+		b.WriteConsistency(v.Consistency)
+		b.WriteInt(v.Received)
+		b.WriteInt(v.BlockFor)
+		b.WriteString(string(v.WriteType))
+	case ReadTimeoutError:
+		writeScyllaError(b, v.ScyllaError)
+		b.WriteConsistency(v.Consistency)
+		b.WriteInt(v.Received)
+		b.WriteInt(v.BlockFor)
+		b.WriteByte(boolByte(v.DataPresent))
+	case ReadFailureError:
+		writeScyllaError(b, v.ScyllaError)
+		b.WriteConsistency(v.Consistency)
+		b.WriteInt(v.Received)
+		b.WriteInt(v.BlockFor)
+		b.WriteInt(v.NumFailures)
+		b.WriteByte(boolByte(v.DataPresent))
+	case WriteFailureError:
+		writeScyllaError(b, v.ScyllaError)
+		b.WriteConsistency(v.Consistency)
+		b.WriteInt(v.Received)
+		b.WriteInt(v.BlockFor)
+		b.WriteInt(v.NumFailures)
+		b.WriteString(string(v.WriteType))
+	case FuncFailureError:
+		writeScyllaError(b, v.ScyllaError)
+		b.WriteString(v.Keyspace)
+		b.WriteString(v.Function)
+		b.WriteStringList(v.ArgTypes)
+	case AlreadyExistsError:
+		writeScyllaError(b, v.ScyllaError)
+		b.WriteString(v.Keyspace)
+		b.WriteString(v.Table)
+	case UnpreparedError:
+		writeScyllaError(b, v.ScyllaError)
+		b.WriteShortBytes(v.UnknownID)
+	case ScyllaError:
+		writeScyllaError(b, v)
+	default:
+		writeScyllaError(b, ScyllaError{Code: err.ErrorCode(), Message: err.Error()})
+	}
+}
+
+func writeScyllaError(b *frame.Buffer, err ScyllaError) {
+	b.WriteInt(err.Code)
+	b.WriteString(err.Message)
+}
+
+func boolByte(v bool) frame.Byte {
+	if v {
+		return 1
+	}
+	return 0
+}
+
+func writeInet(b *frame.Buffer, addr string) {
+	b.WriteInet(frame.Inet{IP: ipBytes(addr), Port: DefaultPort})
+}
+
+func ipBytes(addr string) []byte {
+	ip := net.ParseIP(addr)
+	if v := ip.To4(); v != nil {
+		return v
+	}
+	return ip
+}
+
+// Helpers encoding values of system tables.
+
+func textValue(s string) frame.CqlValue {
+	return frame.CqlValue{Type: &frame.Option{ID: frame.VarcharID}, Value: []byte(s)}
+}
+
+func uuidValue(u frame.UUID) frame.CqlValue {
+	return frame.CqlValue{Type: &frame.Option{ID: frame.UUIDID}, Value: u[:]}
+}
+
+func inetValue(addr string) frame.CqlValue {
+	return frame.CqlValue{Type: &frame.Option{ID: frame.InetID}, Value: ipBytes(addr)}
+}
+
+func textSetValue(v []string) frame.CqlValue {
+	b := appendUint32(nil, uint32(len(v)))
+	for _, s := range v {
+		b = appendUint32(b, uint32(len(s)))
+		b = append(b, s...)
+	}
+	return frame.CqlValue{Type: &textSetType, Value: b}
+}
+
+func textMapValue(m map[string]string) frame.CqlValue {
+	b := appendUint32(nil, uint32(len(m)))
+	for k, v := range m {
+		b = appendUint32(b, uint32(len(k)))
+		b = append(b, k...)
+		b = appendUint32(b, uint32(len(v)))
+		b = append(b, v...)
+	}
+	return frame.CqlValue{Type: &textMapType, Value: b}
+}
+
+func appendUint32(b []byte, v uint32) []byte {
+	return append(b, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
+
+var (
+	textSetType = frame.Option{ID: frame.SetID, Set: &frame.SetOption{Element: frame.Option{ID: frame.VarcharID}}}
+	textMapType = frame.Option{ID: frame.MapID, Map: &frame.MapOption{
+		Key:   frame.Option{ID: frame.VarcharID},
+		Value: frame.Option{ID: frame.VarcharID},
+	}}
+)
+
+func systemColumn(table, name string, t frame.Option) frame.ColumnSpec {
+	return frame.ColumnSpec{Keyspace: "system", Table: table, Name: name, Type: t}
+}
+
+func protocolError(format string, v ...any) ScyllaError {
+	return ScyllaError{Code: frame.ErrCodeProtocol, Message: fmt.Sprintf(format, v...)}
+}
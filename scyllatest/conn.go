@@ -0,0 +1,280 @@
+package scyllatest
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/scylladb/scylla-go-driver/frame"
+	. "github.com/scylladb/scylla-go-driver/frame/response"
+
+	"go.uber.org/atomic"
+)
+
+const eventStreamID = -1
+
+type preparedStmt struct {
+	query    string
+	metadata PreparedMetadata
+}
+
+// serverConn serves a single connection, requests are handled concurrently.
+type serverConn struct {
+	srv   *Server
+	node  NodeConfig
+	shard int
+	conn  net.Conn
+
+	registered atomic.Bool
+	// tablets is set if the driver negotiated TABLETS_ROUTING_V1 extension.
+	tablets   atomic.Bool
+	wmu       sync.Mutex // guards writes to conn
+	closeOnce sync.Once
+	handlers  sync.WaitGroup
+}
+
+func (c *serverConn) loop() {
+	defer c.srv.connsDone.Done()
+	defer c.srv.removeConn(c)
+	defer c.handlers.Wait()
+	defer c.close()
+
+	r := bufio.NewReader(c.conn)
+	header := make([]byte, frame.HeaderSize)
+	for {
+		if _, err := io.ReadFull(r, header); err != nil {
+			return
+		}
+		var b frame.Buffer
+		b.Write(header)
+		h := frame.ParseHeader(&b)
+
+		body := make([]byte, h.Length)
+		if _, err := io.ReadFull(r, body); err != nil {
+			return
+		}
+		if h.Flags&frame.Compress != 0 {
+			c.writeError(h.StreamID, protocolError("compression is not supported"))
+			continue
+		}
+
+		c.handlers.Add(1)
+		go func() {
+			defer c.handlers.Done()
+			c.handle(h, body)
+		}()
+	}
+}
+
+func (c *serverConn) handle(h frame.Header, body []byte) {
+	var b frame.Buffer
+	b.Write(body)
+	var payload frame.BytesMap
+	if h.Flags&frame.CustomPayload != 0 {
+		payload = b.ReadBytesMap()
+	}
+
+	switch h.OpCode {
+	case frame.OpOptions:
+		c.write(h.StreamID, frame.OpSupported, func(b *frame.Buffer) {
+			b.WriteStringMultiMap(c.supported())
+		})
+	case frame.OpStartup:
+		opts := b.ReadStringMap()
+		if _, ok := opts["COMPRESSION"]; ok {
+			c.writeError(h.StreamID, protocolError("compression is not supported"))
+			return
+		}
+		if _, ok := opts[ScyllaTabletsRoutingV1]; ok && c.srv.cfg.Tablets {
+			c.tablets.Store(true)
+		}
+		c.write(h.StreamID, frame.OpReady, func(*frame.Buffer) {})
+	case frame.OpRegister:
+		b.ReadStringList()
+		c.registered.Store(true)
+		c.write(h.StreamID, frame.OpReady, func(*frame.Buffer) {})
+	case frame.OpPrepare:
+		c.handlePrepare(h.StreamID, b.ReadLongString())
+	case frame.OpQuery:
+		req := Request{
+			OpCode:  frame.OpQuery,
+			Query:   b.ReadLongString(),
+			Tracing: h.Flags&frame.Tracing != 0,
+		}
+		c.handleQuery(h.StreamID, req, &b, payload)
+	case frame.OpExecute:
+		id := b.ReadShortBytes()
+		c.srv.mu.Lock()
+		stmt, ok := c.srv.prepared[string(id)]
+		c.srv.mu.Unlock()
+		if !ok {
+			c.writeError(h.StreamID, UnpreparedError{
+				ScyllaError: ScyllaError{Code: frame.ErrCodeUnprepared, Message: "unknown prepared statement"},
+				UnknownID:   id,
+			})
+			return
+		}
+		req := Request{
+			OpCode:  frame.OpExecute,
+			Query:   stmt.query,
+			Tracing: h.Flags&frame.Tracing != 0,
+		}
+		c.handleQuery(h.StreamID, req, &b, payload)
+	default:
+		c.writeError(h.StreamID, protocolError("opcode %d is not supported", h.OpCode))
+	}
+}
+
+func (c *serverConn) supported() frame.StringMultiMap {
+	cfg := c.srv.cfg
+	opts := frame.StringMultiMap{
+		"CQL_VERSION": {"3.0.0"},
+		"COMPRESSION": {},
+	}
+	if cfg.NrShards == 0 {
+		return opts
+	}
+	opts[ScyllaShard] = []string{strconv.Itoa(c.shard)}
+	opts[ScyllaNrShards] = []string{strconv.Itoa(int(cfg.NrShards))}
+	opts[ScyllaShardingIgnoreMSB] = []string{strconv.Itoa(int(cfg.MsbIgnore))}
+	opts[ScyllaPartitioner] = []string{"org.apache.cassandra.dht.Murmur3Partitioner"}
+	opts[ScyllaShardingAlgorithm] = []string{"biased-token-round-robin"}
+	if cfg.ShardAwarePort != 0 {
+		opts[ScyllaShardAwarePort] = []string{strconv.Itoa(int(cfg.ShardAwarePort))}
+	}
+	if cfg.Tablets {
+		opts[ScyllaTabletsRoutingV1] = []string{}
+	}
+	return opts
+}
+
+func (c *serverConn) handlePrepare(streamID frame.StreamID, query string) {
+	sum := sha256.Sum256([]byte(query))
+	id := sum[:16]
+
+	c.srv.mu.Lock()
+	m, ok := c.srv.metadata[query]
+	if !ok {
+		for i := 0; i < strings.Count(query, "?"); i++ {
+			m.BindColumns = append(m.BindColumns, Column("col"+strconv.Itoa(i), frame.BigIntID))
+		}
+	}
+	c.srv.prepared[string(id)] = preparedStmt{query: query, metadata: m}
+	c.srv.mu.Unlock()
+
+	c.write(streamID, frame.OpResult, func(b *frame.Buffer) {
+		writePrepared(b, id, m)
+	})
+}
+
+func (c *serverConn) handleQuery(streamID frame.StreamID, req Request, b *frame.Buffer, payload frame.BytesMap) {
+	req.Node = c.node.Addr
+	req.Shard = c.shard
+	req.Consistency = b.ReadConsistency()
+	opts := b.ReadQueryOptions()
+	req.Values = opts.Values
+	req.PageSize = opts.PageSize
+	req.PagingState = opts.PagingState
+	req.CustomPayload = payload
+	if err := b.Error(); err != nil {
+		c.writeError(streamID, protocolError("parse request: %s", err))
+		return
+	}
+
+	res := c.srv.dispatch(req)
+	if res.Delay > 0 {
+		t := time.NewTimer(res.Delay)
+		select {
+		case <-t.C:
+		case <-c.srv.closeCh:
+			t.Stop()
+			return
+		}
+	}
+	if res.CloseConn {
+		c.close()
+		return
+	}
+	c.writeResult(streamID, res, req.Tracing)
+}
+
+func (c *serverConn) writeResult(streamID frame.StreamID, res *Result, tracing bool) {
+	var flags frame.HeaderFlags
+	if tracing {
+		flags |= frame.Tracing
+	}
+	payload := res.CustomPayload
+	if _, ok := payload[tabletsRoutingKey]; ok && !c.tablets.Load() {
+		payload = make(frame.BytesMap, len(res.CustomPayload))
+		for k, v := range res.CustomPayload {
+			if k != tabletsRoutingKey {
+				payload[k] = v
+			}
+		}
+	}
+	if len(payload) > 0 {
+		flags |= frame.CustomPayload
+	}
+	op := frame.OpResult
+	if res.Err != nil {
+		op = frame.OpError
+	}
+
+	c.writeFrame(streamID, op, flags, func(b *frame.Buffer) {
+		if tracing {
+			b.WriteUUID(res.TracingID)
+		}
+		if len(payload) > 0 {
+			b.WriteBytesMap(payload)
+		}
+		switch {
+		case res.Err != nil:
+			writeError(b, res.Err)
+		case res.Keyspace != "":
+			b.WriteInt(setKeyspaceKind)
+			b.WriteString(res.Keyspace)
+		case res.Columns == nil && res.Rows == nil:
+			b.WriteInt(voidKind)
+		default:
+			writeRows(b, res)
+		}
+	})
+}
+
+func (c *serverConn) writeError(streamID frame.StreamID, err CodedError) {
+	c.write(streamID, frame.OpError, func(b *frame.Buffer) {
+		writeError(b, err)
+	})
+}
+
+func (c *serverConn) write(streamID frame.StreamID, op frame.OpCode, body func(b *frame.Buffer)) {
+	c.writeFrame(streamID, op, 0, body)
+}
+
+func (c *serverConn) writeFrame(streamID frame.StreamID, op frame.OpCode, flags frame.HeaderFlags, body func(b *frame.Buffer)) {
+	var b frame.Buffer
+	body(&b)
+	msg := frameBytes(frame.Header{
+		Version:  responseVersion,
+		Flags:    flags,
+		StreamID: streamID,
+		OpCode:   op,
+	}, b.Bytes())
+
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	if _, err := c.conn.Write(msg); err != nil {
+		c.close()
+	}
+}
+
+func (c *serverConn) close() {
+	c.closeOnce.Do(func() {
+		c.conn.Close()
+	})
+}
@@ -0,0 +1,359 @@
+package scyllatest
+
+import (
+	"context"
+	"hash/fnv"
+	"io"
+	"math/rand"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/scylladb/scylla-go-driver/frame"
+	. "github.com/scylladb/scylla-go-driver/frame/response"
+	"github.com/scylladb/scylla-go-driver/transport"
+)
+
+// Rule describes faults injected into responses to matching requests.
+// Empty Nodes, Shards and OpCodes match all nodes, shards and request opcodes.
+type Rule struct {
+	// Nodes are node IP addresses.
+	Nodes  []string
+	Shards []int
+	// OpCodes are opcodes of requests, e.g. frame.OpQuery or frame.OpExecute.
+	OpCodes []frame.OpCode
+
+	// Probability of injecting the fault into a matching request, 0 means always.
+	Probability float64
+
+	// Latency delays the response, responses to subsequent requests are not delayed
+	// so that the driver receives them out of order.
+	Latency time.Duration
+	// Err replaces the response with an error.
+	Err CodedError
+	// Truncate sends only a part of the response and closes the connection.
+	Truncate bool
+	// Blackhole drops the request, the driver never gets a response.
+	Blackhole bool
+	// CloseAfterFrames closes the connection after it receives CloseAfterFrames responses
+	// to matching requests, Probability is ignored.
+	CloseAfterFrames int
+}
+
+func (r *Rule) match(node string, shard int, op frame.OpCode) bool {
+	return matchAny(r.Nodes, node) && matchAny(r.Shards, shard) && matchAny(r.OpCodes, op)
+}
+
+func matchAny[T comparable](s []T, v T) bool {
+	if len(s) == 0 {
+		return true
+	}
+	for _, x := range s {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
+
+// FaultInjector is a transport.Dialer that wraps connections opened by another dialer
+// and injects faults defined by rules into them.
+//
+// Every connection makes random decisions using its own source seeded with
+// the injector seed, node address, shard and the connection ordinal number,
+// thus given the same sequence of requests the same faults are injected.
+type FaultInjector struct {
+	dialer transport.Dialer
+	seed   int64
+
+	mu    sync.RWMutex
+	rules []*Rule
+	dials map[string]int
+}
+
+var _ transport.Dialer = (*FaultInjector)(nil)
+
+func NewFaultInjector(d transport.Dialer, seed int64) *FaultInjector {
+	return &FaultInjector{
+		dialer: d,
+		seed:   seed,
+		dials:  make(map[string]int),
+	}
+}
+
+// Add adds rule, it affects open connections as well.
+func (f *FaultInjector) Add(r Rule) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.rules = append(f.rules, &r)
+}
+
+// Reset removes all rules.
+func (f *FaultInjector) Reset() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.rules = nil
+}
+
+func (f *FaultInjector) DialContext(ctx context.Context, addr string, si transport.ShardInfo, localPort uint16) (net.Conn, error) {
+	conn, err := f.dialer.DialContext(ctx, addr, si, localPort)
+	if err != nil {
+		return nil, err
+	}
+
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+	shard := -1
+	if si.NrShards > 0 {
+		shard = int(si.Shard)
+	}
+
+	key := host + "/" + strconv.Itoa(shard)
+	f.mu.Lock()
+	n := f.dials[key]
+	f.dials[key]++
+	f.mu.Unlock()
+
+	h := fnv.New64a()
+	h.Write([]byte(key + "/" + strconv.Itoa(n)))
+
+	pr, pw := io.Pipe()
+	c := &faultConn{
+		Conn:     conn,
+		injector: f,
+		node:     host,
+		shard:    shard,
+		rand:     rand.New(rand.NewSource(f.seed ^ int64(h.Sum64()))), // nolint:gosec // Deterministic randomness is desired.
+		faults:   make(map[frame.StreamID]fault),
+		counters: make(map[*Rule]int),
+		pr:       pr,
+		pw:       pw,
+		done:     make(chan struct{}),
+	}
+	c.wg.Add(1)
+	go c.readLoop()
+	return c, nil
+}
+
+func (f *FaultInjector) matching(node string, shard int, op frame.OpCode) []*Rule {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	var res []*Rule
+	for _, r := range f.rules {
+		if r.match(node, shard, op) {
+			res = append(res, r)
+		}
+	}
+	return res
+}
+
+// fault is a decision made for a single request.
+type fault struct {
+	op       frame.OpCode
+	latency  time.Duration
+	err      CodedError
+	truncate bool
+	rules    []*Rule
+}
+
+// faultConn parses frames written by the driver to decide which faults to inject,
+// responses are read from the wrapped connection in a separate goroutine
+// and passed to the driver through a pipe.
+type faultConn struct {
+	net.Conn
+	injector *FaultInjector
+	node     string
+
+	mu       sync.Mutex
+	shard    int
+	rand     *rand.Rand
+	faults   map[frame.StreamID]fault
+	counters map[*Rule]int
+	pending  []byte // partial frame written by the driver
+
+	pr *io.PipeReader
+	pw *io.PipeWriter
+	// wmu serializes writes to pw.
+	wmu sync.Mutex
+
+	done      chan struct{}
+	closeOnce sync.Once
+	wg        sync.WaitGroup
+}
+
+func (c *faultConn) Read(b []byte) (int, error) {
+	return c.pr.Read(b)
+}
+
+func (c *faultConn) Write(b []byte) (int, error) {
+	c.mu.Lock()
+	c.pending = append(c.pending, b...)
+	var out []byte
+	for len(c.pending) >= frame.HeaderSize {
+		var buf frame.Buffer
+		buf.Write(c.pending[:frame.HeaderSize])
+		h := frame.ParseHeader(&buf)
+		size := frame.HeaderSize + int(h.Length)
+		if len(c.pending) < size {
+			break
+		}
+		if !c.decide(h) {
+			out = append(out, c.pending[:size]...)
+		}
+		c.pending = c.pending[size:]
+	}
+	c.mu.Unlock()
+
+	if len(out) > 0 {
+		if _, err := c.Conn.Write(out); err != nil {
+			return 0, err
+		}
+	}
+	return len(b), nil
+}
+
+// decide draws faults for the request, it returns true if the request should be dropped.
+// It must be called with c.mu held.
+func (c *faultConn) decide(h frame.Header) bool {
+	f := fault{op: h.OpCode}
+	blackhole := false
+	for _, r := range c.injector.matching(c.node, c.shard, h.OpCode) {
+		if r.CloseAfterFrames > 0 {
+			f.rules = append(f.rules, r)
+		}
+		if r.Probability > 0 && c.rand.Float64() >= r.Probability {
+			continue
+		}
+		f.latency += r.Latency
+		if r.Err != nil {
+			f.err = r.Err
+		}
+		f.truncate = f.truncate || r.Truncate
+		blackhole = blackhole || r.Blackhole
+	}
+	c.faults[h.StreamID] = f
+	return blackhole
+}
+
+func (c *faultConn) readLoop() {
+	defer c.wg.Done()
+	defer c.closeConn()
+
+	header := make([]byte, frame.HeaderSize)
+	for {
+		if _, err := io.ReadFull(c.Conn, header); err != nil {
+			return
+		}
+		var buf frame.Buffer
+		buf.Write(header)
+		h := frame.ParseHeader(&buf)
+		body := make([]byte, h.Length)
+		if _, err := io.ReadFull(c.Conn, body); err != nil {
+			return
+		}
+		if !c.respond(h, header, body) {
+			return
+		}
+	}
+}
+
+// respond passes response to the driver applying faults, it returns false if the connection is closed.
+func (c *faultConn) respond(h frame.Header, header, body []byte) bool {
+	c.mu.Lock()
+	f, ok := c.faults[h.StreamID]
+	if h.StreamID < 0 {
+		ok = false
+	} else {
+		delete(c.faults, h.StreamID)
+	}
+	if ok && f.op == frame.OpOptions && h.OpCode == frame.OpSupported && c.shard < 0 {
+		c.learnShard(body)
+	}
+	closeAfter := false
+	for _, r := range f.rules {
+		c.counters[r]++
+		if c.counters[r] >= r.CloseAfterFrames {
+			closeAfter = true
+		}
+	}
+	c.mu.Unlock()
+
+	if !ok {
+		return c.deliver(append(header, body...))
+	}
+
+	msg := append(append([]byte(nil), header...), body...)
+	if f.err != nil {
+		msg = errorFrame(h, f.err)
+	}
+	if f.truncate {
+		c.deliver(msg[:len(msg)/2])
+		return false
+	}
+	if f.latency > 0 {
+		c.wg.Add(1)
+		go func() {
+			defer c.wg.Done()
+			t := time.NewTimer(f.latency)
+			defer t.Stop()
+			select {
+			case <-t.C:
+				if !c.deliver(msg) || closeAfter {
+					c.closeConn()
+				}
+			case <-c.done:
+			}
+		}()
+		return true
+	}
+	return c.deliver(msg) && !closeAfter
+}
+
+// learnShard sets shard of a connection that was not dialed to a specific shard based on SUPPORTED response.
+// It must be called with c.mu held.
+func (c *faultConn) learnShard(body []byte) {
+	var buf frame.Buffer
+	buf.Write(body)
+	s := ParseSupported(&buf)
+	if buf.Error() == nil {
+		if si := s.ScyllaSupported(); si.NrShards > 0 {
+			c.shard = int(si.Shard)
+		}
+	}
+}
+
+func (c *faultConn) deliver(msg []byte) bool {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	_, err := c.pw.Write(msg)
+	return err == nil
+}
+
+func errorFrame(h frame.Header, err CodedError) []byte {
+	var b frame.Buffer
+	writeError(&b, err)
+	return frameBytes(frame.Header{
+		Version:  h.Version,
+		StreamID: h.StreamID,
+		OpCode:   frame.OpError,
+	}, b.Bytes())
+}
+
+// closeConn closes connection without waiting for goroutines, it's safe to call it from them.
+func (c *faultConn) closeConn() {
+	c.closeOnce.Do(func() {
+		close(c.done)
+		c.Conn.Close()
+		c.pw.CloseWithError(io.ErrUnexpectedEOF)
+		c.pr.Close()
+	})
+}
+
+func (c *faultConn) Close() error {
+	c.closeConn()
+	c.wg.Wait()
+	return nil
+}
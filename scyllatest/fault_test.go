@@ -0,0 +1,170 @@
+package scyllatest_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/scylladb/scylla-go-driver/frame"
+	. "github.com/scylladb/scylla-go-driver/frame/response"
+	"github.com/scylladb/scylla-go-driver/scyllatest"
+	"github.com/scylladb/scylla-go-driver/transport"
+)
+
+func openFaultConn(t *testing.T, srv *scyllatest.Server, f *scyllatest.FaultInjector) *transport.Conn {
+	t.Helper()
+	cfg := srv.ConnConfig()
+	cfg.Dialer = f
+	conn, err := transport.OpenConn(context.Background(), "127.0.0.1", nil, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return conn
+}
+
+var faultStmt = transport.Statement{Content: "SELECT v FROM ks.t", Consistency: frame.ONE}
+
+func TestFaultInjectorErr(t *testing.T) {
+	t.Parallel()
+	srv := scyllatest.NewServer(scyllatest.DefaultConfig(1))
+	defer srv.Close()
+
+	f := scyllatest.NewFaultInjector(srv, 1)
+	f.Add(scyllatest.Rule{
+		OpCodes: []frame.OpCode{frame.OpQuery},
+		Err:     UnavailableError{ScyllaError: ScyllaError{Code: frame.ErrCodeUnavailable}, Consistency: frame.QUORUM, Required: 2, Alive: 1},
+	})
+	conn := openFaultConn(t, srv, f)
+	defer conn.Close()
+
+	var unavailable UnavailableError
+	if _, err := conn.Query(context.Background(), faultStmt, nil); !errors.As(err, &unavailable) || unavailable.Required != 2 {
+		t.Fatalf("expected unavailable error, got %v", err)
+	}
+
+	f.Reset()
+	if _, err := conn.Query(context.Background(), faultStmt, nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFaultInjectorLatencyAndBlackhole(t *testing.T) {
+	t.Parallel()
+	srv := scyllatest.NewServer(scyllatest.DefaultConfig(1))
+	defer srv.Close()
+
+	f := scyllatest.NewFaultInjector(srv, 1)
+	conn := openFaultConn(t, srv, f)
+	defer conn.Close()
+
+	const latency = 50 * time.Millisecond
+	f.Add(scyllatest.Rule{Nodes: []string{"127.0.0.1"}, OpCodes: []frame.OpCode{frame.OpQuery}, Latency: latency})
+	start := time.Now()
+	if _, err := conn.Query(context.Background(), faultStmt, nil); err != nil {
+		t.Fatal(err)
+	}
+	if d := time.Since(start); d < latency {
+		t.Fatalf("expected response to be delayed by %s, got %s", latency, d)
+	}
+
+	f.Reset()
+	f.Add(scyllatest.Rule{Blackhole: true, OpCodes: []frame.OpCode{frame.OpQuery}})
+	ctx, cancel := context.WithTimeout(context.Background(), latency)
+	defer cancel()
+	if _, err := conn.Query(ctx, faultStmt, nil); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+}
+
+func TestFaultInjectorClose(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name          string
+		rule          scyllatest.Rule
+		okBeforeClose int
+	}{
+		{
+			name:          "close after frames",
+			rule:          scyllatest.Rule{OpCodes: []frame.OpCode{frame.OpQuery}, CloseAfterFrames: 2},
+			okBeforeClose: 2,
+		},
+		{
+			name:          "truncate",
+			rule:          scyllatest.Rule{OpCodes: []frame.OpCode{frame.OpQuery}, Truncate: true},
+			okBeforeClose: 0,
+		},
+		{
+			name:          "other shard",
+			rule:          scyllatest.Rule{Shards: []int{1}, Truncate: true},
+			okBeforeClose: 5,
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			srv := scyllatest.NewServer(scyllatest.DefaultConfig(1))
+			defer srv.Close()
+
+			f := scyllatest.NewFaultInjector(srv, 1)
+			f.Add(tc.rule)
+			conn := openFaultConn(t, srv, f)
+			defer conn.Close()
+			if conn.Shard() != 0 {
+				t.Fatalf("expected first connection to land on shard 0, got %d", conn.Shard())
+			}
+
+			for i := 0; i < 5; i++ {
+				_, err := conn.Query(context.Background(), faultStmt, nil)
+				if i < tc.okBeforeClose && err != nil {
+					t.Fatalf("query %d: %v", i, err)
+				}
+				if i >= tc.okBeforeClose && err == nil {
+					t.Fatalf("query %d: expected error", i)
+				}
+			}
+		})
+	}
+}
+
+func TestFaultInjectorDeterministic(t *testing.T) {
+	t.Parallel()
+
+	run := func(seed int64) []bool {
+		srv := scyllatest.NewServer(scyllatest.DefaultConfig(1))
+		defer srv.Close()
+
+		f := scyllatest.NewFaultInjector(srv, seed)
+		f.Add(scyllatest.Rule{
+			OpCodes:     []frame.OpCode{frame.OpQuery},
+			Probability: 0.5,
+			Err:         ScyllaError{Code: frame.ErrCodeOverloaded},
+		})
+		conn := openFaultConn(t, srv, f)
+		defer conn.Close()
+
+		var res []bool
+		for i := 0; i < 32; i++ {
+			_, err := conn.Query(context.Background(), faultStmt, nil)
+			res = append(res, err != nil)
+		}
+		return res
+	}
+
+	a, b := run(42), run(42)
+	failed := 0
+	for i := range a {
+		if a[i] != b[i] {
+			t.Fatalf("expected the same faults for the same seed, got %v and %v", a, b)
+		}
+		if a[i] {
+			failed++
+		}
+	}
+	if failed == 0 || failed == len(a) {
+		t.Fatalf("expected some of the queries to fail, got %v", a)
+	}
+}
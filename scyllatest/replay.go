@@ -0,0 +1,226 @@
+package scyllatest
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"reflect"
+	"strconv"
+	"sync"
+
+	"github.com/scylladb/scylla-go-driver/frame"
+	"github.com/scylladb/scylla-go-driver/frame/request"
+	. "github.com/scylladb/scylla-go-driver/frame/response"
+	"github.com/scylladb/scylla-go-driver/transport"
+)
+
+// Replayer is a transport.Dialer that replays connections recorded with transport.FrameRecorder.
+//
+// Every dial takes the first unused recorded connection to the same address,
+// and to the same shard if the dial is for a specific shard. Requests sent by the driver
+// are matched with the recorded ones in order, when they match, recorded responses
+// are sent back with stream IDs translated. Requests match if they have the same opcode and,
+// ignoring stream IDs, the same content. Content of compressed requests and of AUTH_RESPONSE,
+// which isn't recorded, is not compared. When a request doesn't match the recording,
+// the driver gets a protocol error describing the difference.
+//
+// Responses are sent as soon as possible, timing of the recording is not reproduced.
+// Replaying works best with heartbeats disabled as they depend on timing.
+type Replayer struct {
+	mu     sync.Mutex
+	conns  []*recordedConn
+	closed bool
+	open   map[*replayConn]struct{}
+	wg     sync.WaitGroup
+}
+
+type recordedConn struct {
+	addr   string
+	shard  int
+	frames []transport.FrameRecord
+	used   bool
+}
+
+var _ transport.Dialer = (*Replayer)(nil)
+
+func NewReplayer(records []transport.FrameRecord) *Replayer {
+	r := &Replayer{
+		open: make(map[*replayConn]struct{}),
+	}
+
+	byID := make(map[uint64]*recordedConn)
+	for _, f := range records {
+		c, ok := byID[f.ConnID]
+		if !ok {
+			c = &recordedConn{addr: f.Addr, shard: -1}
+			byID[f.ConnID] = c
+			r.conns = append(r.conns, c)
+		}
+		if f.Direction == transport.FrameReceived && f.Header.OpCode == frame.OpSupported && c.shard < 0 {
+			var b frame.Buffer
+			b.Write(f.Body)
+			if si := ParseSupported(&b).ScyllaSupported(); b.Error() == nil && si.NrShards > 0 {
+				c.shard = int(si.Shard)
+			}
+		}
+		c.frames = append(c.frames, f)
+	}
+	return r
+}
+
+func (r *Replayer) DialContext(ctx context.Context, addr string, si transport.ShardInfo, localPort uint16) (net.Conn, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return nil, fmt.Errorf("replayer closed")
+	}
+
+	var rc *recordedConn
+	for _, c := range r.conns {
+		if c.used || c.addr != addr || (si.NrShards > 0 && c.shard != int(si.Shard)) {
+			continue
+		}
+		rc = c
+		break
+	}
+	if rc == nil {
+		return nil, fmt.Errorf("dial %s: no recorded connection", addr)
+	}
+	rc.used = true
+
+	client, server := net.Pipe()
+	c := &replayConn{
+		r:       r,
+		frames:  rc.frames,
+		conn:    server,
+		streams: make(map[frame.StreamID]frame.StreamID),
+	}
+	r.open[c] = struct{}{}
+	r.wg.Add(1)
+	go c.loop()
+
+	return pipeConn{
+		Conn:   client,
+		local:  pipeAddr(net.JoinHostPort("127.0.0.1", strconv.Itoa(int(localPort)))),
+		remote: pipeAddr(addr),
+	}, nil
+}
+
+// Close closes all connections and waits for them to terminate.
+func (r *Replayer) Close() {
+	r.mu.Lock()
+	r.closed = true
+	conns := make([]*replayConn, 0, len(r.open))
+	for c := range r.open {
+		conns = append(conns, c)
+	}
+	r.mu.Unlock()
+
+	for _, c := range conns {
+		c.conn.Close()
+	}
+	r.wg.Wait()
+}
+
+type replayConn struct {
+	r      *Replayer
+	frames []transport.FrameRecord
+	pos    int
+	conn   net.Conn
+	// streams maps recorded stream IDs to stream IDs of requests sent by the driver.
+	streams map[frame.StreamID]frame.StreamID
+}
+
+func (c *replayConn) loop() {
+	defer func() {
+		c.conn.Close()
+		c.r.mu.Lock()
+		delete(c.r.open, c)
+		c.r.mu.Unlock()
+		c.r.wg.Done()
+	}()
+
+	br := bufio.NewReader(c.conn)
+	header := make([]byte, frame.HeaderSize)
+	for {
+		if _, err := io.ReadFull(br, header); err != nil {
+			return
+		}
+		var b frame.Buffer
+		b.Write(header)
+		h := frame.ParseHeader(&b)
+		body := make([]byte, h.Length)
+		if _, err := io.ReadFull(br, body); err != nil {
+			return
+		}
+
+		if err := c.handle(h, body); err != nil {
+			return
+		}
+	}
+}
+
+func (c *replayConn) handle(h frame.Header, body []byte) error {
+	if c.pos >= len(c.frames) || c.frames[c.pos].Direction != transport.FrameSent {
+		return c.write(h.StreamID, errorFrame(h, protocolError("replay: unexpected request with opcode 0x%02x", h.OpCode)))
+	}
+	rec := c.frames[c.pos]
+	if rec.Header.OpCode != h.OpCode {
+		return c.write(h.StreamID, errorFrame(h, protocolError("replay: expected request with opcode 0x%02x, got 0x%02x", rec.Header.OpCode, h.OpCode)))
+	}
+	if h.Flags&frame.Compress == 0 && h.OpCode != frame.OpAuthResponse {
+		if expected, got := parseRequest(h.OpCode, rec.Body), parseRequest(h.OpCode, body); !reflect.DeepEqual(expected, got) {
+			return c.write(h.StreamID, errorFrame(h, protocolError("replay: request with opcode 0x%02x differs from the recording, expected %+v, got %+v", h.OpCode, expected, got)))
+		}
+	}
+	c.streams[rec.Header.StreamID] = h.StreamID
+	c.pos++
+
+	for ; c.pos < len(c.frames) && c.frames[c.pos].Direction == transport.FrameReceived; c.pos++ {
+		f := c.frames[c.pos]
+		streamID := f.Header.StreamID
+		if streamID >= 0 {
+			v, ok := c.streams[streamID]
+			if !ok {
+				continue
+			}
+			delete(c.streams, streamID)
+			streamID = v
+		}
+		if err := c.write(streamID, frameBytes(f.Header, f.Body)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseRequest returns parsed request or body if the request can't be parsed,
+// parsed requests are compared as their encoding isn't deterministic, e.g. for STARTUP options.
+func parseRequest(op frame.OpCode, body []byte) any {
+	var b frame.Buffer
+	b.Write(body)
+	if v := request.ParseRequest(op, &b); v != nil && b.Error() == nil {
+		return v
+	}
+	return body
+}
+
+func (c *replayConn) write(streamID frame.StreamID, msg []byte) error {
+	msg[2], msg[3] = byte(uint16(streamID)>>8), byte(streamID)
+	_, err := c.conn.Write(msg)
+	return err
+}
+
+func frameBytes(h frame.Header, body []byte) []byte {
+	var b frame.Buffer
+	h.Length = frame.Int(len(body))
+	h.WriteTo(&b)
+	b.Write(body)
+	return b.Bytes()
+}
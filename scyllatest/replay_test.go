@@ -0,0 +1,125 @@
+package scyllatest_test
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	scylla "github.com/scylladb/scylla-go-driver"
+	"github.com/scylladb/scylla-go-driver/frame"
+	"github.com/scylladb/scylla-go-driver/scyllatest"
+	"github.com/scylladb/scylla-go-driver/transport"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func runSession(t *testing.T, cfg scylla.SessionConfig, queries ...string) []scylla.Result {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	session, err := scylla.NewSession(ctx, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer session.Close()
+
+	var res []scylla.Result
+	for _, q := range queries {
+		q := session.Query(q)
+		r, err := q.Exec(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res = append(res, r)
+	}
+	return res
+}
+
+func TestReplaySession(t *testing.T) {
+	t.Parallel()
+
+	cfg := scyllatest.DefaultConfig(1)
+	cfg.NrShards = 1
+	srv := scyllatest.NewServer(cfg)
+	defer srv.Close()
+	const query = "SELECT v FROM ks.t"
+	srv.On(query, scyllatest.Result{
+		Columns: []frame.ColumnSpec{scyllatest.Column("v", frame.VarcharID)},
+		Rows:    []frame.Row{{{Value: []byte("a")}}},
+	})
+
+	var buf bytes.Buffer
+	rec := transport.NewFileRecorder(&buf)
+	sessionCfg := scylla.DefaultSessionConfig("", srv.Hosts()...)
+	sessionCfg.ConnConfig = srv.ConnConfig()
+	sessionCfg.FrameRecorder = rec
+	expected := runSession(t, sessionCfg, query, query)
+	if err := rec.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := transport.ReadRecording(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := scyllatest.NewReplayer(records)
+	defer r.Close()
+	sessionCfg.Dialer = r
+	sessionCfg.FrameRecorder = nil
+	got := runSession(t, sessionCfg, query, query)
+	if diff := cmp.Diff(expected, got); diff != "" {
+		t.Fatal(diff)
+	}
+}
+
+func TestReplayDiverged(t *testing.T) {
+	t.Parallel()
+
+	srv := scyllatest.NewServer(scyllatest.DefaultConfig(1))
+	defer srv.Close()
+
+	var buf bytes.Buffer
+	rec := transport.NewFileRecorder(&buf)
+	cfg := srv.ConnConfig()
+	cfg.FrameRecorder = rec
+	ctx := context.Background()
+	conn, err := transport.OpenConn(ctx, "127.0.0.1", nil, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.Query(ctx, faultStmt, nil); err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+	if err := rec.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := transport.ReadRecording(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := scyllatest.NewReplayer(records)
+	defer r.Close()
+	cfg.Dialer = r
+	cfg.FrameRecorder = nil
+	conn, err = transport.OpenConn(ctx, "127.0.0.1", nil, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	other := faultStmt
+	other.Content = "SELECT v FROM ks.other"
+	if _, err := conn.Query(ctx, other, nil); err == nil || !strings.Contains(err.Error(), "differs from the recording") {
+		t.Fatalf("expected error when statement doesn't match the recording, got %v", err)
+	}
+	if _, err := conn.Prepare(ctx, faultStmt); err == nil {
+		t.Fatal("expected error when request doesn't match the recording")
+	}
+	if _, err := transport.OpenConn(ctx, "127.0.0.1", nil, cfg); err == nil {
+		t.Fatal("expected error when there are no more recorded connections")
+	}
+}
@@ -0,0 +1,135 @@
+package scyllatest
+
+import (
+	"time"
+
+	"github.com/scylladb/scylla-go-driver/frame"
+	. "github.com/scylladb/scylla-go-driver/frame/response"
+	"github.com/scylladb/scylla-go-driver/transport"
+)
+
+// Request describes QUERY or EXECUTE request received by the server,
+// for EXECUTE Query holds the content of the prepared statement.
+type Request struct {
+	Node        string
+	Shard       int
+	OpCode      frame.OpCode
+	Query       string
+	Values      []frame.Value
+	Consistency frame.Consistency
+	PageSize    frame.Int
+	PagingState frame.Bytes
+	// CustomPayload is the payload sent with the request, if any.
+	CustomPayload frame.BytesMap
+	// Tracing is set if the request has tracing flag.
+	Tracing bool
+}
+
+// Result is the server reply to a request.
+// Result without Columns, Rows and Keyspace is sent as VOID result.
+type Result struct {
+	// Keyspace is sent as SET_KEYSPACE result if set.
+	Keyspace string
+	Columns  []frame.ColumnSpec
+	Rows     []frame.Row
+	// PagingState is returned with rows, non nil value marks that there are more pages.
+	PagingState frame.Bytes
+	// Err is sent instead of the result if set, it should be one of frame/response errors
+	// e.g. ScyllaError, UnavailableError or ReadTimeoutError.
+	Err CodedError
+	// Delay postpones sending the reply.
+	Delay time.Duration
+	// CloseConn closes the connection instead of replying.
+	CloseConn bool
+	// CustomPayload is sent with the result if set.
+	CustomPayload frame.BytesMap
+	// TracingID is sent with the result if the request has tracing flag.
+	TracingID frame.UUID
+}
+
+// Handler returns reply for a request, nil means that the request is not handled
+// and should be passed to the next handler.
+type Handler func(Request) *Result
+
+// Handle adds handler that is consulted before the handlers added earlier,
+// it can be used to override topology queries as well.
+func (s *Server) Handle(h Handler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handlers = append([]Handler{h}, s.handlers...)
+}
+
+// On scripts replies to query, consecutive requests get consecutive results,
+// the last result is repeated when the script is exhausted.
+func (s *Server) On(query string, results ...Result) {
+	if len(results) == 0 {
+		return
+	}
+
+	i := 0
+	s.Handle(func(r Request) *Result {
+		if r.Query != query {
+			return nil
+		}
+		// Handlers are called with s.mu held.
+		res := results[i]
+		if i < len(results)-1 {
+			i++
+		}
+		return &res
+	})
+}
+
+// PreparedMetadata is returned to the driver when preparing a statement.
+type PreparedMetadata struct {
+	// BindColumns describe bind markers of the statement.
+	BindColumns []frame.ColumnSpec
+	// PkIndexes are indexes of bind markers that form the partition key.
+	PkIndexes []frame.Short
+	// ResultColumns describe rows returned by the statement.
+	ResultColumns []frame.ColumnSpec
+}
+
+// SetPreparedMetadata sets metadata returned when preparing query.
+// By default, every bind marker is a bigint column and partition key is unknown.
+func (s *Server) SetPreparedMetadata(query string, m PreparedMetadata) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.metadata[query] = m
+}
+
+// Column returns spec of a column of a given type, it's a helper for building results.
+func Column(name string, id frame.OptionID) frame.ColumnSpec {
+	return frame.ColumnSpec{
+		Keyspace: "ks",
+		Table:    "t",
+		Name:     name,
+		Type:     frame.Option{ID: id},
+	}
+}
+
+// tabletsRoutingKey is the custom payload key of tablet routing information.
+const tabletsRoutingKey = "tablets-routing-v1"
+
+// TabletPayload returns custom payload with tablet routing information, Scylla sends it
+// with results of requests sent to a node or shard that is not a replica of the tablet.
+// It's only sent if Config.Tablets is set.
+func TabletPayload(t transport.Tablet) frame.BytesMap {
+	var replicas frame.Buffer
+	replicas.WriteInt(frame.Int(len(t.Replicas)))
+	for _, r := range t.Replicas {
+		var v frame.Buffer
+		v.WriteBytes(r.HostID[:])
+		v.WriteInt(4)
+		v.WriteInt(frame.Int(r.Shard))
+		replicas.WriteBytes(v.Bytes())
+	}
+
+	var b frame.Buffer
+	b.WriteInt(8)
+	b.WriteLong(frame.Long(t.FirstToken))
+	b.WriteInt(8)
+	b.WriteLong(frame.Long(t.LastToken))
+	b.WriteBytes(replicas.Bytes())
+	return frame.BytesMap{tabletsRoutingKey: b.Bytes()}
+}
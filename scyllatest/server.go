@@ -0,0 +1,339 @@
+// Package scyllatest implements an in-memory CQL v4 server that can be used
+// to test the driver, and applications using it, without a running cluster.
+//
+// Server connects to the driver through transport.Dialer, it simulates a cluster
+// of nodes with configurable shards and shard aware port, serves topology
+// from fake system.local, system.peers and system_schema.keyspaces tables
+// and replies to other queries with scripted results:
+//
+//	srv := scyllatest.NewServer(scyllatest.DefaultConfig(3))
+//	defer srv.Close()
+//	srv.On("SELECT v FROM ks.t WHERE pk=?", scyllatest.Result{Err: response.ScyllaError{Code: frame.ErrCodeOverloaded}})
+//
+//	cfg := scylla.DefaultSessionConfig("", srv.Hosts()...)
+//	cfg.ConnConfig = srv.ConnConfig()
+//
+// FaultInjector wraps any transport.Dialer, including the server, and injects
+// latency, errors and connection failures into responses.
+package scyllatest
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"strconv"
+	"sync"
+
+	"github.com/scylladb/scylla-go-driver/frame"
+	"github.com/scylladb/scylla-go-driver/transport"
+)
+
+// NodeConfig describes a single simulated node.
+type NodeConfig struct {
+	// Addr is the node IP address, it's used to dial the node and it's reported in topology.
+	Addr       string
+	HostID     frame.UUID
+	Datacenter string
+	Rack       string
+	Tokens     []string
+}
+
+type Config struct {
+	Nodes []NodeConfig
+	// NrShards is the number of shards of every node, 0 means that nodes
+	// don't report sharding information like Apache Cassandra.
+	NrShards  uint16
+	MsbIgnore uint8
+	// ShardAwarePort is advertised as SCYLLA_SHARD_AWARE_PORT if not 0.
+	ShardAwarePort uint16
+	// Keyspaces maps keyspace names to their replication options.
+	Keyspaces map[string]map[string]string
+	// SchemaVersion is reported by all nodes in system.local.
+	SchemaVersion frame.UUID
+	// Tablets enables TABLETS_ROUTING_V1 protocol extension, tablet routing information
+	// built with TabletPayload is only sent on connections that negotiated it.
+	Tablets bool
+}
+
+const (
+	DefaultPort           = 9042
+	DefaultShardAwarePort = 19042
+)
+
+// DefaultConfig returns config of a cluster of n nodes with 2 shards each,
+// in a single datacenter and rack, with tokens evenly distributed.
+func DefaultConfig(n int) Config {
+	cfg := Config{
+		NrShards:       2,
+		MsbIgnore:      12,
+		ShardAwarePort: DefaultShardAwarePort,
+		Keyspaces:      make(map[string]map[string]string),
+		SchemaVersion:  frame.UUID{0: 0xaa, 15: 0xaa},
+	}
+
+	step := math.MaxUint64 / uint64(n)
+	for i := 0; i < n; i++ {
+		cfg.Nodes = append(cfg.Nodes, NodeConfig{
+			Addr:       "127.0.0." + strconv.Itoa(i+1),
+			HostID:     frame.UUID{15: byte(i + 1)},
+			Datacenter: "datacenter1",
+			Rack:       "rack1",
+			Tokens:     []string{strconv.FormatInt(math.MinInt64+int64(step*uint64(i+1)), 10)},
+		})
+	}
+	return cfg
+}
+
+type node struct {
+	cfg       NodeConfig
+	up        bool
+	nextShard int
+}
+
+// Server is an in-memory CQL v4 server simulating a cluster of nodes.
+type Server struct {
+	mu        sync.Mutex
+	cfg       Config
+	nodes     []*node
+	conns     map[*serverConn]struct{}
+	handlers  []Handler
+	prepared  map[string]preparedStmt // by statement ID
+	metadata  map[string]PreparedMetadata
+	requests  []Request
+	closed    bool
+	closeCh   chan struct{}
+	connsDone sync.WaitGroup
+}
+
+var _ transport.Dialer = (*Server)(nil)
+
+func NewServer(cfg Config) *Server {
+	s := &Server{
+		cfg:      cfg,
+		conns:    make(map[*serverConn]struct{}),
+		prepared: make(map[string]preparedStmt),
+		metadata: make(map[string]PreparedMetadata),
+		closeCh:  make(chan struct{}),
+	}
+	for _, n := range cfg.Nodes {
+		s.nodes = append(s.nodes, &node{cfg: n, up: true})
+	}
+	return s
+}
+
+// Hosts returns addresses of all nodes.
+func (s *Server) Hosts() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	res := make([]string, len(s.nodes))
+	for i, n := range s.nodes {
+		res[i] = n.cfg.Addr
+	}
+	return res
+}
+
+// ConnConfig returns default connection config with connections dialed to the server.
+// Heartbeats are disabled, so that they don't show up in requests and injected faults.
+func (s *Server) ConnConfig() transport.ConnConfig {
+	cfg := transport.DefaultConnConfig("")
+	cfg.Dialer = s
+	cfg.HeartbeatInterval = 0
+	return cfg
+}
+
+// DialContext implements transport.Dialer, it opens in-memory connection to the node with given address.
+// Connections to shard aware port are mapped to shards based on localPort, other connections
+// are assigned to shards in a round-robin fashion.
+func (s *Server) DialContext(ctx context.Context, addr string, _ transport.ShardInfo, localPort uint16) (net.Conn, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid port %q: %w", portStr, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil, fmt.Errorf("server closed")
+	}
+	n := s.node(host)
+	if n == nil || !n.up || (port != DefaultPort && (s.cfg.ShardAwarePort == 0 || port != int(s.cfg.ShardAwarePort))) {
+		return nil, fmt.Errorf("dial %s: connection refused", addr)
+	}
+
+	shard := 0
+	if s.cfg.NrShards > 0 {
+		if port == int(s.cfg.ShardAwarePort) && localPort != 0 {
+			shard = int(localPort % s.cfg.NrShards)
+		} else {
+			shard = n.nextShard % int(s.cfg.NrShards)
+			n.nextShard++
+		}
+	}
+
+	client, server := net.Pipe()
+	c := &serverConn{
+		srv:   s,
+		node:  n.cfg,
+		shard: shard,
+		conn:  server,
+	}
+	s.conns[c] = struct{}{}
+	s.connsDone.Add(1)
+	go c.loop()
+
+	return pipeConn{
+		Conn:   client,
+		local:  pipeAddr(net.JoinHostPort("127.0.0.1", strconv.Itoa(int(localPort)))),
+		remote: pipeAddr(addr),
+	}, nil
+}
+
+// node must be called with s.mu held.
+func (s *Server) node(addr string) *node {
+	for _, n := range s.nodes {
+		if n.cfg.Addr == addr {
+			return n
+		}
+	}
+	return nil
+}
+
+// StopNode closes all connections to the node and refuses new ones until StartNode is called.
+func (s *Server) StopNode(addr string) {
+	s.mu.Lock()
+	if n := s.node(addr); n != nil {
+		n.up = false
+	}
+	var conns []*serverConn
+	for c := range s.conns {
+		if c.node.Addr == addr {
+			conns = append(conns, c)
+		}
+	}
+	s.mu.Unlock()
+
+	for _, c := range conns {
+		c.close()
+	}
+}
+
+func (s *Server) StartNode(addr string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if n := s.node(addr); n != nil {
+		n.up = true
+	}
+}
+
+// AddNode adds node to the topology, use SendTopologyChange to notify the driver.
+func (s *Server) AddNode(cfg NodeConfig) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.nodes = append(s.nodes, &node{cfg: cfg, up: true})
+}
+
+// RemoveNode stops the node and removes it from the topology, use SendTopologyChange to notify the driver.
+func (s *Server) RemoveNode(addr string) {
+	s.StopNode(addr)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, n := range s.nodes {
+		if n.cfg.Addr == addr {
+			s.nodes = append(s.nodes[:i], s.nodes[i+1:]...)
+			return
+		}
+	}
+}
+
+// SendStatusChange sends STATUS_CHANGE event to all connections registered for events.
+func (s *Server) SendStatusChange(addr string, status frame.StatusChangeType) {
+	s.sendEvent(func(b *frame.Buffer) {
+		b.WriteString(frame.StatusChange)
+		b.WriteString(string(status))
+		writeInet(b, addr)
+	})
+}
+
+// SendTopologyChange sends TOPOLOGY_CHANGE event to all connections registered for events.
+func (s *Server) SendTopologyChange(addr string, change frame.TopologyChangeType) {
+	s.sendEvent(func(b *frame.Buffer) {
+		b.WriteString(frame.TopologyChange)
+		b.WriteString(string(change))
+		writeInet(b, addr)
+	})
+}
+
+func (s *Server) sendEvent(body func(b *frame.Buffer)) {
+	s.mu.Lock()
+	var conns []*serverConn
+	for c := range s.conns {
+		if c.registered.Load() {
+			conns = append(conns, c)
+		}
+	}
+	s.mu.Unlock()
+
+	for _, c := range conns {
+		c.write(eventStreamID, frame.OpEvent, body)
+	}
+}
+
+// Requests returns QUERY and EXECUTE requests received by the server that were not
+// addressed to system tables nor answered by handlers, in the order they were handled.
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	res := make([]Request, len(s.requests))
+	copy(res, s.requests)
+	return res
+}
+
+// Close closes all connections and waits for them to terminate.
+func (s *Server) Close() {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return
+	}
+	s.closed = true
+	close(s.closeCh)
+	conns := make([]*serverConn, 0, len(s.conns))
+	for c := range s.conns {
+		conns = append(conns, c)
+	}
+	s.mu.Unlock()
+
+	for _, c := range conns {
+		c.close()
+	}
+	s.connsDone.Wait()
+}
+
+func (s *Server) removeConn(c *serverConn) {
+	s.mu.Lock()
+	delete(s.conns, c)
+	s.mu.Unlock()
+}
+
+type pipeAddr string
+
+func (a pipeAddr) Network() string { return "pipe" }
+func (a pipeAddr) String() string  { return string(a) }
+
+// pipeConn reports node address as remote address of in-memory connection.
+type pipeConn struct {
+	net.Conn
+	local  net.Addr
+	remote net.Addr
+}
+
+func (c pipeConn) LocalAddr() net.Addr  { return c.local }
+func (c pipeConn) RemoteAddr() net.Addr { return c.remote }
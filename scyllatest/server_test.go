@@ -0,0 +1,143 @@
+package scyllatest_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	scylla "github.com/scylladb/scylla-go-driver"
+	"github.com/scylladb/scylla-go-driver/frame"
+	. "github.com/scylladb/scylla-go-driver/frame/response"
+	"github.com/scylladb/scylla-go-driver/scyllatest"
+	"github.com/scylladb/scylla-go-driver/transport"
+
+	"go.uber.org/goleak"
+)
+
+func TestMain(m *testing.M) {
+	goleak.VerifyTestMain(m)
+}
+
+func TestServerShardAwarePort(t *testing.T) {
+	t.Parallel()
+	srv := scyllatest.NewServer(scyllatest.DefaultConfig(1))
+	defer srv.Close()
+
+	cfg := srv.ConnConfig()
+	ctx := context.Background()
+	for shard := uint16(0); shard < 2; shard++ {
+		si := transport.ShardInfo{Shard: shard, NrShards: 2, MsbIgnore: 12}
+		conn, err := transport.OpenShardConn(ctx, "127.0.0.1:19042", si, cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if conn.Shard() != int(shard) {
+			t.Fatalf("expected shard %d, got %d", shard, conn.Shard())
+		}
+		conn.Close()
+	}
+}
+
+func TestServerScriptedResults(t *testing.T) {
+	t.Parallel()
+	srv := scyllatest.NewServer(scyllatest.DefaultConfig(1))
+	defer srv.Close()
+
+	const query = "SELECT v FROM ks.t"
+	overloaded := ScyllaError{Code: frame.ErrCodeOverloaded, Message: "overloaded"}
+	srv.On(query,
+		scyllatest.Result{Err: overloaded},
+		scyllatest.Result{
+			Columns: []frame.ColumnSpec{scyllatest.Column("v", frame.VarcharID)},
+			Rows:    []frame.Row{{{Value: []byte("a")}}, {{Value: []byte("b")}}},
+		},
+	)
+
+	ctx := context.Background()
+	conn, err := transport.OpenConn(ctx, "127.0.0.1", nil, srv.ConnConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	stmt := transport.Statement{Content: query, Consistency: frame.ONE}
+	var scyllaErr ScyllaError
+	if _, err := conn.Query(ctx, stmt, nil); !errors.As(err, &scyllaErr) || scyllaErr.Code != frame.ErrCodeOverloaded {
+		t.Fatalf("expected overloaded error, got %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		res, err := conn.Query(ctx, stmt, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(res.Rows) != 2 {
+			t.Fatalf("expected 2 rows, got %d", len(res.Rows))
+		}
+		if v, _ := res.Rows[1][0].AsText(); v != "b" {
+			t.Fatalf("expected %q, got %q", "b", v)
+		}
+	}
+	if got := len(srv.Requests()); got != 0 {
+		t.Fatalf("expected scripted requests not to be recorded, got %d", got)
+	}
+}
+
+func TestServerSession(t *testing.T) {
+	t.Parallel()
+	srv := scyllatest.NewServer(scyllatest.DefaultConfig(3))
+	defer srv.Close()
+
+	cfg := scylla.DefaultSessionConfig("", srv.Hosts()...)
+	cfg.ConnConfig = srv.ConnConfig()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	session, err := scylla.NewSession(ctx, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer session.Close()
+
+	if nodes := session.Nodes(); len(nodes) != 3 {
+		t.Fatalf("expected 3 nodes, got %+v", nodes)
+	}
+
+	const query = "INSERT INTO ks.t (pk) VALUES (1)"
+	q := session.Query(query)
+	if _, err := q.Exec(ctx); err != nil {
+		t.Fatal(err)
+	}
+	reqs := srv.Requests()
+	if len(reqs) != 1 || reqs[0].Query != query {
+		t.Fatalf("expected single request, got %+v", reqs)
+	}
+}
+
+func TestServerStopNode(t *testing.T) {
+	t.Parallel()
+	srv := scyllatest.NewServer(scyllatest.DefaultConfig(1))
+	defer srv.Close()
+
+	ctx := context.Background()
+	conn, err := transport.OpenConn(ctx, "127.0.0.1", nil, srv.ConnConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	srv.StopNode("127.0.0.1")
+	if _, err := conn.Query(ctx, transport.Statement{Content: "SELECT v FROM ks.t"}, nil); err == nil {
+		t.Fatal("expected error on stopped node")
+	}
+	if _, err := transport.OpenConn(ctx, "127.0.0.1", nil, srv.ConnConfig()); err == nil {
+		t.Fatal("expected dial error on stopped node")
+	}
+
+	srv.StartNode("127.0.0.1")
+	conn, err = transport.OpenConn(ctx, "127.0.0.1", nil, srv.ConnConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+}
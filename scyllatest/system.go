@@ -0,0 +1,115 @@
+package scyllatest
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/scylladb/scylla-go-driver/frame"
+)
+
+// dispatch returns the reply to the request, user handlers are consulted first
+// and then the built-in system tables.
+func (s *Server) dispatch(req Request) *Result {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, h := range s.handlers {
+		if res := h(req); res != nil {
+			return res
+		}
+	}
+
+	q := req.Query
+	switch {
+	case strings.HasPrefix(q, "SELECT schema_version FROM system.local"):
+		return &Result{
+			Columns: []frame.ColumnSpec{systemColumn("local", "schema_version", frame.Option{ID: frame.UUIDID})},
+			Rows:    []frame.Row{{uuidValue(s.cfg.SchemaVersion)}},
+		}
+	case strings.Contains(q, "FROM system.local"):
+		return s.localResult(req.Node)
+	case strings.Contains(q, "FROM system.peers"):
+		return s.peersResult(req.Node)
+	case strings.Contains(q, "FROM system_schema.keyspaces"):
+		return s.keyspacesResult()
+	case strings.HasPrefix(q, "USE "):
+		return &Result{Keyspace: strings.Trim(strings.TrimPrefix(q, "USE "), `"`)}
+	}
+
+	s.requests = append(s.requests, req)
+	return &Result{}
+}
+
+func (s *Server) localResult(addr string) *Result {
+	res := &Result{
+		Columns: []frame.ColumnSpec{
+			systemColumn("local", "host_id", frame.Option{ID: frame.UUIDID}),
+			systemColumn("local", "data_center", frame.Option{ID: frame.VarcharID}),
+			systemColumn("local", "rack", frame.Option{ID: frame.VarcharID}),
+			systemColumn("local", "tokens", textSetType),
+			systemColumn("local", "rpc_address", frame.Option{ID: frame.InetID}),
+			systemColumn("local", "broadcast_address", frame.Option{ID: frame.InetID}),
+		},
+	}
+	if n := s.node(addr); n != nil {
+		res.Rows = []frame.Row{{
+			uuidValue(n.cfg.HostID),
+			textValue(n.cfg.Datacenter),
+			textValue(n.cfg.Rack),
+			textSetValue(n.cfg.Tokens),
+			inetValue(n.cfg.Addr),
+			inetValue(n.cfg.Addr),
+		}}
+	}
+	return res
+}
+
+func (s *Server) peersResult(addr string) *Result {
+	res := &Result{
+		Columns: []frame.ColumnSpec{
+			systemColumn("peers", "host_id", frame.Option{ID: frame.UUIDID}),
+			systemColumn("peers", "data_center", frame.Option{ID: frame.VarcharID}),
+			systemColumn("peers", "rack", frame.Option{ID: frame.VarcharID}),
+			systemColumn("peers", "tokens", textSetType),
+			systemColumn("peers", "rpc_address", frame.Option{ID: frame.InetID}),
+			systemColumn("peers", "preferred_ip", frame.Option{ID: frame.InetID}),
+			systemColumn("peers", "peer", frame.Option{ID: frame.InetID}),
+		},
+		Rows: []frame.Row{},
+	}
+	for _, n := range s.nodes {
+		if n.cfg.Addr == addr {
+			continue
+		}
+		res.Rows = append(res.Rows, frame.Row{
+			uuidValue(n.cfg.HostID),
+			textValue(n.cfg.Datacenter),
+			textValue(n.cfg.Rack),
+			textSetValue(n.cfg.Tokens),
+			inetValue(n.cfg.Addr),
+			inetValue(n.cfg.Addr),
+			inetValue(n.cfg.Addr),
+		})
+	}
+	return res
+}
+
+func (s *Server) keyspacesResult() *Result {
+	res := &Result{
+		Columns: []frame.ColumnSpec{
+			{Keyspace: "system_schema", Table: "keyspaces", Name: "keyspace_name", Type: frame.Option{ID: frame.VarcharID}},
+			{Keyspace: "system_schema", Table: "keyspaces", Name: "replication", Type: textMapType},
+		},
+		Rows: []frame.Row{},
+	}
+
+	names := make([]string, 0, len(s.cfg.Keyspaces))
+	for name := range s.cfg.Keyspaces {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		res.Rows = append(res.Rows, frame.Row{textValue(name), textMapValue(s.cfg.Keyspaces[name])})
+	}
+	return res
+}
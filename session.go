@@ -55,7 +55,10 @@ var (
 		"SERIAL      Consistency = 0x0008\n" +
 		"LOCALSERIAL Consistency = 0x0009\n" +
 		"LOCALONE    Consistency = 0x000A")
-	ErrNoConnection = fmt.Errorf("no connection to execute the query on")
+	ErrSlowQuerySampleRate = fmt.Errorf("error in session config: slow query sample rate must be in range [0, 1]")
+	ErrNoConnection        = fmt.Errorf("no connection to execute the query on")
+	// ErrOverloaded is returned if query exceeds Limits and Limits.FailFast is set.
+	ErrOverloaded = transport.ErrOverloaded
 )
 
 type Compression = frame.Compression
@@ -80,6 +83,38 @@ type SessionConfig struct {
 	// Default: 60 seconds.
 	AutoAwaitSchemaAgreementTimeout time.Duration
 
+	// QueryObserver is notified about execution of queries.
+	// Default: nil.
+	QueryObserver QueryObserver
+	// Interceptors are called in order before every attempt to execute a statement.
+	// Default: nil.
+	Interceptors []Interceptor
+
+	// SlowQueryThreshold controls logging of queries that take longer than the threshold, including retries.
+	// Slow queries are logged as warnings with the statement, sizes of bound values, coordinator, shard,
+	// number of attempts and latency. If less or equal to 0, slow queries are not logged.
+	// Default: 0.
+	SlowQueryThreshold time.Duration
+	// SlowQuerySampleRate is the fraction of slow queries that are logged, it must be in range [0, 1].
+	// Default: 1.
+	SlowQuerySampleRate float64
+	// SlowQueryTracing enables re-running logged idempotent slow queries with tracing.
+	// The query is re-run in background on the connection of the last attempt, the tracing ID
+	// is logged in a separate "session: slow query trace" message. Re-runs in progress are
+	// canceled when the session is closed.
+	// Default: false.
+	SlowQueryTracing bool
+	// SlowQueryTracingTimeout bounds the re-run of slow query with tracing, it doesn't depend on the query context
+	// which may be already done, only on the session. If less or equal to 0, the default is used.
+	// Default: 10s.
+	SlowQueryTracingTimeout time.Duration
+
+	// InferIdempotence marks statements created by Query and Prepare as idempotent if they are
+	// SELECTs, or INSERTs and UPDATEs without lightweight transactions, counters, list appends
+	// and non-deterministic functions such as now(). Query.SetIdempotent overrides it.
+	// Default: false.
+	InferIdempotence bool
+
 	transport.ConnConfig
 }
 
@@ -90,6 +125,8 @@ func DefaultSessionConfig(keyspace string, hosts ...string) SessionConfig {
 		RetryPolicy:                     transport.NewDefaultRetryPolicy(),
 		SchemaAgreementInterval:         200 * time.Millisecond,
 		AutoAwaitSchemaAgreementTimeout: 60 * time.Second,
+		SlowQuerySampleRate:             1,
+		SlowQueryTracingTimeout:         defaultSlowQueryTracingTimeout,
 		ConnConfig:                      transport.DefaultConnConfig(keyspace),
 	}
 }
@@ -103,6 +140,9 @@ func (cfg SessionConfig) Clone() SessionConfig {
 	v.Events = make([]EventType, len(cfg.Events))
 	copy(v.Events, cfg.Events)
 
+	v.Interceptors = make([]Interceptor, len(cfg.Interceptors))
+	copy(v.Interceptors, cfg.Interceptors)
+
 	v.TLSConfig = v.TLSConfig.Clone()
 
 	return v
@@ -120,12 +160,16 @@ func (cfg *SessionConfig) Validate() error {
 	if cfg.DefaultConsistency > LOCALONE {
 		return ErrConsistency
 	}
+	if cfg.SlowQuerySampleRate < 0 || cfg.SlowQuerySampleRate > 1 {
+		return ErrSlowQuerySampleRate
+	}
 	return nil
 }
 
 type Session struct {
 	cfg     SessionConfig
 	cluster *transport.Cluster
+	tracer  *tracer
 }
 
 func NewSession(ctx context.Context, cfg SessionConfig) (*Session, error) {
@@ -143,14 +187,19 @@ func NewSession(ctx context.Context, cfg SessionConfig) (*Session, error) {
 	s := &Session{
 		cfg:     cfg,
 		cluster: cluster,
+		tracer:  newTracer(),
 	}
 
 	return s, nil
 }
 
 func (s *Session) Query(content string) Query {
+	stmt := transport.Statement{Content: content, Consistency: s.cfg.DefaultConsistency}
+	if s.cfg.InferIdempotence {
+		stmt.Idempotent = inferIdempotent(stmt)
+	}
 	return Query{session: s,
-		stmt: transport.Statement{Content: content, Consistency: s.cfg.DefaultConsistency},
+		stmt: stmt,
 		exec: func(ctx context.Context, conn *transport.Conn, stmt transport.Statement, pagingState frame.Bytes) (transport.QueryResult, error) {
 			return conn.Query(ctx, stmt, pagingState)
 		},
@@ -174,6 +223,11 @@ func (s *Session) Prepare(ctx context.Context, content string) (Query, error) {
 		wg.Add(1)
 		go func(idx int) {
 			defer wg.Done()
+			if err := s.cluster.Admit(ctx, nodes[idx]); err != nil {
+				resErr[idx] = err
+				return
+			}
+			defer s.cluster.Release(nodes[idx])
 			resStmt[idx], resErr[idx] = nodes[idx].Prepare(ctx, stmt)
 		}(i)
 	}
@@ -182,6 +236,9 @@ func (s *Session) Prepare(ctx context.Context, content string) (Query, error) {
 	// Find first result that succeeded.
 	for i := range nodes {
 		if resErr[i] == nil {
+			if s.cfg.InferIdempotence {
+				resStmt[i].Idempotent = inferIdempotent(resStmt[i])
+			}
 			return Query{
 				session: s,
 				stmt:    resStmt[i],
@@ -270,6 +327,28 @@ func (s *Session) CheckSchemaAgreement(ctx context.Context) (bool, error) {
 	return true, nil
 }
 
+// Nodes returns a snapshot of the state of all nodes in the current topology.
+func (s *Session) Nodes() []transport.NodeInfo {
+	nodes := s.cluster.Topology().Nodes
+	res := make([]transport.NodeInfo, len(nodes))
+	for i, n := range nodes {
+		res[i] = n.Info()
+	}
+	return res
+}
+
+// SetHostFilter replaces host filter, it's applied asynchronously by topology refresh.
+func (s *Session) SetHostFilter(f transport.HostFilter) {
+	s.cluster.SetHostFilter(f)
+}
+
+// addTablet stores tablet routing information sent with the result of a prepared statement.
+func (s *Session) addTablet(stmt transport.Statement, res transport.QueryResult) {
+	if res.Tablet != nil && stmt.Table != "" {
+		s.cluster.AddTablet(stmt.Keyspace, stmt.Table, *res.Tablet)
+	}
+}
+
 func (s *Session) NewTokenAwarePolicy() transport.HostSelectionPolicy {
 	return transport.NewTokenAwarePolicy("")
 }
@@ -278,7 +357,12 @@ func (s *Session) NewTokenAwareDCAwarePolicy(localDC string) transport.HostSelec
 	return transport.NewTokenAwarePolicy(localDC)
 }
 
+func (s *Session) NewTokenAwareRackAwarePolicy(localDC, localRack string) transport.HostSelectionPolicy {
+	return transport.NewTokenAwareRackAwarePolicy(localDC, localRack)
+}
+
 func (s *Session) Close() {
 	s.cfg.Logger.Info("session: close")
+	s.tracer.close()
 	s.cluster.Close()
 }
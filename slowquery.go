@@ -0,0 +1,157 @@
+package scylla
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/scylladb/scylla-go-driver/frame"
+	"github.com/scylladb/scylla-go-driver/log"
+	"github.com/scylladb/scylla-go-driver/transport"
+)
+
+// isSlow reports if query taking latency should be logged as slow, it takes sampling into account.
+func (r *queryRun) isSlow(latency time.Duration) bool {
+	if t := r.cfg.SlowQueryThreshold; t <= 0 || latency <= t {
+		return false
+	}
+	rate := r.cfg.SlowQuerySampleRate
+	return rate >= 1 || rand.Float64() < rate // nolint:gosec // Sampling doesn't need secure random numbers.
+}
+
+func (r *queryRun) logSlowQuery(ctx context.Context, latency time.Duration, err error) {
+	attrs := r.slowQueryAttrs()
+	attrs = append(attrs, log.Int("attempts", r.attempts), log.Duration("latency", latency))
+	if r.paged {
+		attrs = append(attrs, log.Any("paged", true))
+	}
+	if err != nil {
+		attrs = append(attrs, log.Err(err))
+	}
+	r.cfg.Logger.Warn("session: slow query", attrs...)
+	if r.cfg.SlowQueryTracing && r.tracer != nil {
+		r.tracer.trace(ctx, *r)
+	}
+}
+
+// slowQueryAttrs returns attributes identifying the statement and the node of the last attempt.
+func (r *queryRun) slowQueryAttrs() []log.Attr {
+	attrs := []log.Attr{
+		log.String("statement", r.stmt.Content),
+		log.Any("value_sizes", valueSizes(r.stmt.Values)),
+		log.Keyspace(stmtKeyspace(r.cfg, r.stmt)),
+	}
+	if r.last.Conn != nil {
+		attrs = append(attrs, log.Node(r.last.Addr))
+		if r.last.Shard != transport.UnknownShard {
+			attrs = append(attrs, log.Shard(int(r.last.Shard)))
+		}
+	}
+	return attrs
+}
+
+const defaultSlowQueryTracingTimeout = 10 * time.Second
+
+// tracer re-runs slow queries with tracing in background, re-runs are canceled and waited for
+// when the session is closed.
+type tracer struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu     sync.Mutex
+	closed bool
+	wg     sync.WaitGroup
+}
+
+func newTracer() *tracer {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &tracer{
+		ctx:    ctx,
+		cancel: cancel,
+	}
+}
+
+// trace re-runs the last attempt of r with tracing enabled and logs the tracing ID.
+// Only idempotent statements are re-run as the statement is executed once more.
+func (t *tracer) trace(ctx context.Context, r queryRun) {
+	if r.exec == nil || r.last.Conn == nil || !r.last.Stmt.Idempotent {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		return
+	}
+	t.wg.Add(1)
+	go func() {
+		defer t.wg.Done()
+		attrs := append(r.slowQueryAttrs(), r.trace(detachedContext{Context: t.ctx, parent: ctx}))
+		r.cfg.Logger.Warn("session: slow query trace", attrs...)
+	}()
+}
+
+func (t *tracer) close() {
+	t.mu.Lock()
+	t.closed = true
+	t.mu.Unlock()
+
+	t.cancel()
+	t.wg.Wait()
+}
+
+// trace re-runs the last attempt with tracing enabled and returns attribute with the tracing ID or error.
+// The re-run is subject to admission limits and circuit breaker of the node like any other attempt.
+func (r *queryRun) trace(ctx context.Context) log.Attr {
+	timeout := r.cfg.SlowQueryTracingTimeout
+	if timeout <= 0 {
+		timeout = defaultSlowQueryTracingTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	n := r.last.Node
+	if err := r.cluster.Admit(ctx, n); err != nil {
+		return log.String("tracing_err", err.Error())
+	}
+	defer r.cluster.Release(n)
+	if err := n.AdmitAttempt(); err != nil {
+		return log.String("tracing_err", err.Error())
+	}
+
+	stmt := r.last.Stmt
+	stmt.Tracing = true
+	start := transport.Now()
+	res, err := r.exec(ctx, r.last.Conn, stmt, r.pagingState)
+	n.ObserveAttempt(transport.Now().Sub(start), err)
+	if err != nil {
+		return log.String("tracing_err", err.Error())
+	}
+	return log.String("tracing_id", uuidString(res.TracingID))
+}
+
+// detachedContext keeps values of the parent context, but it's done only when the embedded context is done.
+type detachedContext struct {
+	context.Context
+	parent context.Context
+}
+
+func (c detachedContext) Value(key any) any { return c.parent.Value(key) }
+
+// valueSizes returns sizes of bound values, -1 means null.
+func valueSizes(values []frame.Value) []int {
+	if len(values) == 0 {
+		return nil
+	}
+	sizes := make([]int, len(values))
+	for i, v := range values {
+		sizes[i] = int(v.N)
+	}
+	return sizes
+}
+
+func uuidString(u frame.UUID) string {
+	return fmt.Sprintf("%x-%x-%x-%x-%x", u[0:4], u[4:6], u[6:8], u[8:10], u[10:16])
+}
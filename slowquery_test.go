@@ -0,0 +1,269 @@
+package scylla_test
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	scylla "github.com/scylladb/scylla-go-driver"
+	"github.com/scylladb/scylla-go-driver/frame"
+	"github.com/scylladb/scylla-go-driver/log"
+	"github.com/scylladb/scylla-go-driver/scyllatest"
+)
+
+// warnLogger records warnings as maps of attributes with the "msg" key holding the message.
+type warnLogger struct {
+	log.NopLogger
+
+	mu       sync.Mutex
+	warnings []map[string]string
+}
+
+func (l *warnLogger) Warn(msg string, attrs ...log.Attr) {
+	m := map[string]string{"msg": msg}
+	for _, a := range attrs {
+		m[a.Key] = fmt.Sprint(a.Value)
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.warnings = append(l.warnings, m)
+}
+
+func (l *warnLogger) Warnings() []map[string]string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]map[string]string(nil), l.warnings...)
+}
+
+// waitWarning waits for the first warning with message msg.
+func (l *warnLogger) waitWarning(t *testing.T, msg string) map[string]string {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		for _, w := range l.Warnings() {
+			if w["msg"] == msg {
+				return w
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("%q not logged", msg)
+	return nil
+}
+
+func TestSlowQueryLog(t *testing.T) {
+	t.Parallel()
+	srv := scyllatest.NewServer(scyllatest.DefaultConfig(1))
+	defer srv.Close()
+
+	const (
+		slow = "SELECT v FROM ks.t WHERE pk = ?"
+		fast = "SELECT v FROM ks.fast"
+	)
+	tracingID := frame.UUID{0xde, 0xad, 0xbe, 0xef, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12}
+	srv.On(slow, scyllatest.Result{
+		Columns:   []frame.ColumnSpec{scyllatest.Column("v", frame.VarcharID)},
+		Rows:      []frame.Row{{{Value: []byte("a")}}},
+		Delay:     50 * time.Millisecond,
+		TracingID: tracingID,
+	})
+	var (
+		mu     sync.Mutex
+		traced []scyllatest.Request
+	)
+	srv.Handle(func(r scyllatest.Request) *scyllatest.Result {
+		if r.Tracing {
+			mu.Lock()
+			traced = append(traced, r)
+			mu.Unlock()
+		}
+		return nil
+	})
+
+	logger := &warnLogger{}
+	cfg := testSessionConfig(srv)
+	cfg.Logger = logger
+	cfg.SlowQueryThreshold = 20 * time.Millisecond
+	cfg.SlowQueryTracing = true
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	session, err := scylla.NewSession(ctx, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer session.Close()
+
+	fq := session.Query(fast)
+	if _, err := fq.Exec(ctx); err != nil {
+		t.Fatal(err)
+	}
+	q, err := session.Prepare(ctx, slow)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q.SetIdempotent(true)
+	q.BindInt64(0, 1)
+	if _, err := q.Exec(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	var slowLogs []map[string]string
+	for _, w := range logger.Warnings() {
+		if w["msg"] == "session: slow query" {
+			slowLogs = append(slowLogs, w)
+		}
+	}
+	if len(slowLogs) != 1 {
+		t.Fatalf("expected 1 slow query log, got %+v", slowLogs)
+	}
+	l := slowLogs[0]
+	for k, v := range map[string]string{
+		"statement":   slow,
+		"value_sizes": "[8]",
+		log.NodeKey:   srv.Hosts()[0],
+		"attempts":    "1",
+	} {
+		if !strings.HasPrefix(l[k], v) {
+			t.Errorf("%s = %q, expected %q", k, l[k], v)
+		}
+	}
+	if d, err := time.ParseDuration(l["latency"]); err != nil || d < cfg.SlowQueryThreshold {
+		t.Errorf("unexpected latency %q", l["latency"])
+	}
+
+	tl := logger.waitWarning(t, "session: slow query trace")
+	for k, v := range map[string]string{
+		"statement":  slow,
+		log.NodeKey:  srv.Hosts()[0],
+		"tracing_id": "deadbeef-0102-0304-0506-0708090a0b0c",
+	} {
+		if !strings.HasPrefix(tl[k], v) {
+			t.Errorf("%s = %q, expected %q", k, tl[k], v)
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(traced) != 1 || traced[0].Query != slow {
+		t.Fatalf("expected traced re-run of slow query, got %+v", traced)
+	}
+	// Query is re-run on the connection of the last attempt.
+	if v := strconv.Itoa(traced[0].Shard); l[log.ShardKey] != v {
+		t.Fatalf("shard = %q, expected %q", l[log.ShardKey], v)
+	}
+}
+
+func TestSlowQueryTracingContext(t *testing.T) {
+	t.Parallel()
+
+	const slow = "SELECT v FROM ks.t"
+	testCases := []struct {
+		name           string
+		queryTimeout   time.Duration
+		tracingTimeout time.Duration
+		key            string
+		expected       string
+	}{
+		{
+			name:         "query context done before re-run ends",
+			queryTimeout: 150 * time.Millisecond,
+			key:          "tracing_id",
+			expected:     "deadbeef-",
+		},
+		{
+			name:           "re-run timeout",
+			queryTimeout:   10 * time.Second,
+			tracingTimeout: 10 * time.Millisecond,
+			key:            "tracing_err",
+			expected:       "",
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			srv := scyllatest.NewServer(scyllatest.DefaultConfig(1))
+			defer srv.Close()
+			srv.On(slow, scyllatest.Result{
+				Delay:     100 * time.Millisecond,
+				TracingID: frame.UUID{0xde, 0xad, 0xbe, 0xef},
+			})
+
+			logger := &warnLogger{}
+			cfg := testSessionConfig(srv)
+			cfg.Logger = logger
+			cfg.SlowQueryThreshold = 20 * time.Millisecond
+			cfg.SlowQueryTracing = true
+			if tc.tracingTimeout > 0 {
+				cfg.SlowQueryTracingTimeout = tc.tracingTimeout
+			}
+			session, err := scylla.NewSession(context.Background(), cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer session.Close()
+
+			ctx, cancel := context.WithTimeout(context.Background(), tc.queryTimeout)
+			defer cancel()
+			q := session.Query(slow)
+			q.SetIdempotent(true)
+			if _, err := q.Exec(ctx); err != nil {
+				t.Fatal(err)
+			}
+
+			w := logger.waitWarning(t, "session: slow query trace")
+			if v, ok := w[tc.key]; !ok || !strings.HasPrefix(v, tc.expected) {
+				t.Fatalf("expected %s with prefix %q, got %+v", tc.key, tc.expected, w)
+			}
+		})
+	}
+}
+
+func TestSlowQueryTracingBackground(t *testing.T) {
+	t.Parallel()
+	srv := scyllatest.NewServer(scyllatest.DefaultConfig(1))
+	defer srv.Close()
+
+	const slow = "SELECT v FROM ks.t"
+	srv.On(slow, scyllatest.Result{Delay: 50 * time.Millisecond})
+	srv.Handle(func(r scyllatest.Request) *scyllatest.Result {
+		if r.Tracing {
+			return &scyllatest.Result{Delay: time.Minute}
+		}
+		return nil
+	})
+
+	logger := &warnLogger{}
+	cfg := testSessionConfig(srv)
+	cfg.Logger = logger
+	cfg.SlowQueryThreshold = 20 * time.Millisecond
+	cfg.SlowQueryTracing = true
+	session, err := scylla.NewSession(context.Background(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	q := session.Query(slow)
+	q.SetIdempotent(true)
+	start := time.Now()
+	if _, err := q.Exec(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Fatalf("Exec waited for re-run with tracing, took %s", d)
+	}
+	logger.waitWarning(t, "session: slow query")
+
+	// Closing session cancels the re-run in progress.
+	session.Close()
+	w := logger.waitWarning(t, "session: slow query trace")
+	if v := w["tracing_err"]; !strings.Contains(v, context.Canceled.Error()) {
+		t.Fatalf("expected canceled re-run, got %+v", w)
+	}
+}
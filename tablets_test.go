@@ -0,0 +1,73 @@
+package scylla_test
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	scylla "github.com/scylladb/scylla-go-driver"
+	"github.com/scylladb/scylla-go-driver/frame"
+	"github.com/scylladb/scylla-go-driver/scyllatest"
+	"github.com/scylladb/scylla-go-driver/transport"
+	"go.uber.org/atomic"
+)
+
+func TestTabletRouting(t *testing.T) {
+	t.Parallel()
+	scfg := scyllatest.DefaultConfig(3)
+	scfg.Tablets = true
+	scfg.Keyspaces["ks"] = map[string]string{"class": "SimpleStrategy", "replication_factor": "1"}
+	srv := scyllatest.NewServer(scfg)
+	defer srv.Close()
+
+	const query = "SELECT v FROM ks.t WHERE pk = ?"
+	srv.SetPreparedMetadata(query, scyllatest.PreparedMetadata{
+		BindColumns: []frame.ColumnSpec{scyllatest.Column("pk", frame.BigIntID)},
+		PkIndexes:   []frame.Short{0},
+	})
+	// The whole table is a single tablet on the last node and shard 1.
+	replica := scfg.Nodes[2]
+	payload := scyllatest.TabletPayload(transport.Tablet{
+		FirstToken: math.MinInt64,
+		LastToken:  math.MaxInt64,
+		Replicas:   []transport.TabletReplica{{HostID: replica.HostID, Shard: 1}},
+	})
+	var misrouted atomic.Int32
+	srv.Handle(func(r scyllatest.Request) *scyllatest.Result {
+		if r.Query != query || r.Node == replica.Addr && r.Shard == 1 {
+			return nil
+		}
+		misrouted.Inc()
+		return &scyllatest.Result{CustomPayload: payload}
+	})
+
+	cfg := testSessionConfig(srv)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	session, err := scylla.NewSession(ctx, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer session.Close()
+
+	q, err := session.Prepare(ctx, query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		q.BindInt64(0, int64(i))
+		if _, err := q.Exec(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Only the first request may be misrouted, following ones are routed by the tablet.
+	if v := misrouted.Load(); v > 1 {
+		t.Fatalf("expected at most 1 misrouted request, got %d", v)
+	}
+	if reqs := srv.Requests(); len(reqs)+int(misrouted.Load()) != 10 {
+		t.Fatalf("expected 10 requests, got %+v", reqs)
+	}
+}
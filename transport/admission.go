@@ -0,0 +1,179 @@
+package transport
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// ErrOverloaded is returned if request exceeds admission limits and AdmissionLimits.FailFast is set.
+var ErrOverloaded = errors.New("admission: request limit exceeded")
+
+// ErrNodeOverloaded is returned instead of ErrOverloaded if request exceeds AdmissionLimits.Node,
+// the request can be sent to another node. It wraps ErrOverloaded.
+var ErrNodeOverloaded = fmt.Errorf("%w for node", ErrOverloaded)
+
+// Limit restricts rate and concurrency of requests, zero values mean no limit.
+type Limit struct {
+	// Rate is the maximal number of requests per second.
+	Rate float64
+	// Burst is the number of requests that can be sent at once after a period of inactivity.
+	// Default: 1, if Rate is set.
+	Burst int
+	// MaxInFlight is the maximal number of requests waiting for response.
+	MaxInFlight int
+}
+
+// AdmissionLimits are client side limits of requests executed by Session, they protect
+// cluster from being overloaded by the client. Every attempt of a query, including retries,
+// is a request.
+type AdmissionLimits struct {
+	// Global limits all requests of the session.
+	Global Limit
+	// Node limits requests sent to every node.
+	Node Limit
+	// FailFast makes requests exceeding the limits fail immediately with ErrOverloaded,
+	// otherwise they wait until the limits allow them or until context is done.
+	// Queries exceeding Node limit are sent to the next node of the plan.
+	FailFast bool
+}
+
+// limiter enforces Limit, nil limiter doesn't limit requests.
+type limiter struct {
+	inFlight chan struct{}
+	bucket   *tokenBucket
+}
+
+func newLimiter(l Limit) *limiter {
+	if l.Rate <= 0 && l.MaxInFlight <= 0 {
+		return nil
+	}
+	res := &limiter{}
+	if l.MaxInFlight > 0 {
+		res.inFlight = make(chan struct{}, l.MaxInFlight)
+	}
+	if l.Rate > 0 {
+		res.bucket = newTokenBucket(l.Rate, l.Burst)
+	}
+	return res
+}
+
+func (l *limiter) acquire(ctx context.Context, failFast bool) error {
+	if l == nil {
+		return nil
+	}
+	if l.inFlight != nil {
+		select {
+		case l.inFlight <- struct{}{}:
+		default:
+			if failFast {
+				return ErrOverloaded
+			}
+			select {
+			case l.inFlight <- struct{}{}:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	}
+	if l.bucket != nil {
+		if err := l.bucket.take(ctx, failFast); err != nil {
+			l.release()
+			return err
+		}
+	}
+	return nil
+}
+
+func (l *limiter) release() {
+	if l == nil || l.inFlight == nil {
+		return
+	}
+	<-l.inFlight
+}
+
+// tokenBucket is refilled with rate tokens per second up to burst tokens, every request takes one token.
+// Number of tokens becomes negative when requests wait for tokens to be refilled.
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate float64, burst int) *tokenBucket {
+	if burst <= 0 {
+		burst = 1
+	}
+	return &tokenBucket{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+func (b *tokenBucket) refill(now time.Time) {
+	if d := now.Sub(b.last); d > 0 {
+		b.tokens = math.Min(b.burst, b.tokens+d.Seconds()*b.rate)
+		b.last = now
+	}
+}
+
+func (b *tokenBucket) take(ctx context.Context, failFast bool) error {
+	b.mu.Lock()
+	b.refill(time.Now())
+	if b.tokens >= 1 {
+		b.tokens--
+		b.mu.Unlock()
+		return nil
+	}
+	if failFast {
+		b.mu.Unlock()
+		return ErrOverloaded
+	}
+	// Token is reserved, so that waiting requests are admitted in order.
+	wait := time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+	b.tokens--
+	b.mu.Unlock()
+
+	t := time.NewTimer(wait)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		// Reserved token is returned, the bucket may have been refilled in the meantime.
+		b.mu.Lock()
+		b.tokens = math.Min(b.burst, b.tokens+1)
+		b.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+// Admit waits until request to node n is allowed by AdmissionLimits, Release must be called
+// after response to an admitted request is received.
+func (c *Cluster) Admit(ctx context.Context, n *Node) error {
+	failFast := c.cfg.Limits.FailFast
+	if err := c.limiter.acquire(ctx, failFast); err != nil {
+		return err
+	}
+	if err := n.limiter.acquire(ctx, failFast); err != nil {
+		c.limiter.release()
+		if errors.Is(err, ErrOverloaded) {
+			return ErrNodeOverloaded
+		}
+		return err
+	}
+	return nil
+}
+
+// Release ends request to node n admitted by Admit.
+func (c *Cluster) Release(n *Node) {
+	n.limiter.release()
+	c.limiter.release()
+}
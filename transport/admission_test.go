@@ -0,0 +1,121 @@
+package transport
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestLimiterInFlight(t *testing.T) {
+	t.Parallel()
+	l := newLimiter(Limit{MaxInFlight: 2})
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		if err := l.acquire(ctx, true); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := l.acquire(ctx, true); !errors.Is(err, ErrOverloaded) {
+		t.Fatalf("expected ErrOverloaded, got %v", err)
+	}
+
+	tctx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	if err := l.acquire(tctx, false); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+
+	done := make(chan error)
+	go func() {
+		done <- l.acquire(ctx, false)
+	}()
+	l.release()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLimiterRate(t *testing.T) {
+	t.Parallel()
+	const rate = 50
+	l := newLimiter(Limit{Rate: rate, Burst: 2})
+	ctx := context.Background()
+
+	// Burst is admitted at once.
+	for i := 0; i < 2; i++ {
+		if err := l.acquire(ctx, true); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := l.acquire(ctx, true); !errors.Is(err, ErrOverloaded) {
+		t.Fatalf("expected ErrOverloaded, got %v", err)
+	}
+
+	// Cancelled waiter returns its token.
+	cctx, cancel := context.WithCancel(ctx)
+	cancel()
+	if err := l.acquire(cctx, false); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context canceled, got %v", err)
+	}
+
+	start := time.Now()
+	const n = 5
+	for i := 0; i < n; i++ {
+		if err := l.acquire(ctx, false); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed, expected := time.Since(start), (n-1)*time.Second/rate; elapsed < expected {
+		t.Fatalf("%d requests admitted in %v, expected at least %v", n, elapsed, expected)
+	}
+}
+
+func TestNilLimiter(t *testing.T) {
+	t.Parallel()
+	l := newLimiter(Limit{})
+	if l != nil {
+		t.Fatalf("expected nil limiter, got %+v", l)
+	}
+	if err := l.acquire(context.Background(), true); err != nil {
+		t.Fatal(err)
+	}
+	l.release()
+}
+
+func TestTokenBucketCanceledWaiterDoesNotExceedBurst(t *testing.T) {
+	t.Parallel()
+	b := newTokenBucket(1, 2)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	for i := 0; i < 2; i++ {
+		if err := b.take(ctx, true); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	done := make(chan error)
+	go func() {
+		done <- b.take(ctx, false)
+	}()
+	for reserved := false; !reserved; {
+		time.Sleep(time.Millisecond)
+		b.mu.Lock()
+		reserved = b.tokens < 0
+		b.mu.Unlock()
+	}
+	// Bucket is refilled while the request waits.
+	b.mu.Lock()
+	b.tokens = b.burst
+	b.mu.Unlock()
+
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context canceled, got %v", err)
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.tokens > b.burst {
+		t.Fatalf("tokens = %v, expected at most %v", b.tokens, b.burst)
+	}
+}
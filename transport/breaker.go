@@ -0,0 +1,254 @@
+package transport
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/scylladb/scylla-go-driver/frame"
+	. "github.com/scylladb/scylla-go-driver/frame/response"
+	"github.com/scylladb/scylla-go-driver/log"
+)
+
+// CircuitState is the state of node circuit breaker.
+type CircuitState int32
+
+const (
+	// CircuitClosed node is used normally.
+	CircuitClosed CircuitState = iota
+	// CircuitOpen node is skipped by queries.
+	CircuitOpen
+	// CircuitHalfOpen node gets a limited number of probe queries that decide if circuit is closed or opened again.
+	CircuitHalfOpen
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	default:
+		return fmt.Sprintf("CircuitState(%d)", int32(s))
+	}
+}
+
+// CircuitBreakerConfig controls per node circuit breakers. Attempts failing with Overloaded errors or server side
+// read and write timeouts are failures, attempts failing with other server errors are successes, as the node was able
+// to respond. Attempts abandoned because query context is done are not counted, as the deadline is set by the caller.
+type CircuitBreakerConfig struct {
+	// Window is the period over which error rate and average latency are computed,
+	// if less or equal to 0, circuit breakers are disabled.
+	// Default: 0.
+	Window time.Duration
+	// MinRequests is the number of attempts in Window needed to open the circuit.
+	// Default: 20.
+	MinRequests int
+	// ErrorRate opens the circuit if the fraction of failed attempts in Window is at least ErrorRate,
+	// if less or equal to 0, error rate is not checked.
+	ErrorRate float64
+	// Latency opens the circuit if the average latency of attempts in Window is at least Latency,
+	// if less or equal to 0, latency is not checked.
+	Latency time.Duration
+	// OpenDuration is the time node is skipped before probe queries are sent to it.
+	// Default: Window.
+	OpenDuration time.Duration
+	// HalfOpenProbes is the number of successful probes needed to close the circuit,
+	// a single failed probe opens it again.
+	// Default: 1.
+	HalfOpenProbes int
+}
+
+const defaultCircuitBreakerMinRequests = 20
+
+// ErrCircuitOpen is returned by Node.Conn and Node.AdmitAttempt if node circuit breaker is open.
+var ErrCircuitOpen = errors.New("circuit breaker open")
+
+// circuitBreaker counts attempts in tumbling windows, nil circuitBreaker is always closed.
+type circuitBreaker struct {
+	cfg    CircuitBreakerConfig
+	addr   string
+	logger log.Logger
+
+	mu    sync.Mutex
+	state CircuitState
+	// since is the start of the current window in closed state and the time of the last state change otherwise.
+	since    time.Time
+	requests int
+	failures int
+	latency  time.Duration
+	// probes is the number of probes admitted in half-open state.
+	probes int
+}
+
+func newCircuitBreaker(cfg CircuitBreakerConfig, addr string, logger log.Logger) *circuitBreaker {
+	if cfg.Window <= 0 {
+		return nil
+	}
+	if cfg.MinRequests <= 0 {
+		cfg.MinRequests = defaultCircuitBreakerMinRequests
+	}
+	if cfg.OpenDuration <= 0 {
+		cfg.OpenDuration = cfg.Window
+	}
+	if cfg.HalfOpenProbes <= 0 {
+		cfg.HalfOpenProbes = 1
+	}
+	return &circuitBreaker{
+		cfg:    cfg,
+		addr:   addr,
+		logger: logger,
+		since:  Now(),
+	}
+}
+
+func (b *circuitBreaker) currentState() CircuitState {
+	if b == nil {
+		return CircuitClosed
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// open reports if attempts to the node would be rejected, unlike admit it doesn't change the breaker state.
+func (b *circuitBreaker) open(now time.Time) bool {
+	if b == nil {
+		return false
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case CircuitOpen:
+		return now.Sub(b.since) < b.cfg.OpenDuration
+	case CircuitHalfOpen:
+		return now.Sub(b.since) < b.cfg.OpenDuration && b.probes >= b.cfg.HalfOpenProbes
+	default:
+		return false
+	}
+}
+
+// admit reports if an attempt can be sent to the node, in half-open state it admits probes.
+// Probes that don't report their result in OpenDuration are replaced by new ones.
+func (b *circuitBreaker) admit(now time.Time) bool {
+	if b == nil {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case CircuitOpen:
+		if now.Sub(b.since) < b.cfg.OpenDuration {
+			return false
+		}
+		b.setState(CircuitHalfOpen, now)
+	case CircuitHalfOpen:
+		if now.Sub(b.since) >= b.cfg.OpenDuration {
+			b.since, b.probes, b.requests = now, 0, 0
+		}
+		if b.probes >= b.cfg.HalfOpenProbes {
+			return false
+		}
+	default:
+		return true
+	}
+	b.probes++
+	return true
+}
+
+// record updates breaker with result of an attempt.
+func (b *circuitBreaker) record(now time.Time, latency time.Duration, err error) {
+	if b == nil {
+		return
+	}
+	failure, ok := isBreakerFailure(err)
+	if !ok {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case CircuitClosed:
+		if now.Sub(b.since) >= b.cfg.Window {
+			b.since, b.requests, b.failures, b.latency = now, 0, 0, 0
+		}
+		b.requests++
+		b.latency += latency
+		if failure {
+			b.failures++
+		}
+		if b.tripped() {
+			b.setState(CircuitOpen, now)
+		}
+	case CircuitHalfOpen:
+		if failure || b.cfg.Latency > 0 && latency >= b.cfg.Latency {
+			b.setState(CircuitOpen, now)
+			return
+		}
+		b.requests++
+		if b.requests >= b.cfg.HalfOpenProbes {
+			b.setState(CircuitClosed, now)
+		}
+	}
+}
+
+func (b *circuitBreaker) tripped() bool {
+	if b.requests < b.cfg.MinRequests {
+		return false
+	}
+	if b.cfg.ErrorRate > 0 && float64(b.failures) >= b.cfg.ErrorRate*float64(b.requests) {
+		return true
+	}
+	return b.cfg.Latency > 0 && b.latency >= b.cfg.Latency*time.Duration(b.requests)
+}
+
+func (b *circuitBreaker) setState(s CircuitState, now time.Time) {
+	attrs := []log.Attr{log.Node(b.addr), log.String("from", b.state.String()), log.String("to", s.String())}
+	if b.state == CircuitClosed {
+		attrs = append(attrs, log.Int("requests", b.requests), log.Int("failures", b.failures))
+	}
+	if s == CircuitOpen {
+		b.logger.Warn("circuit breaker: state changed", attrs...)
+	} else {
+		b.logger.Info("circuit breaker: state changed", attrs...)
+	}
+
+	b.state = s
+	b.since, b.requests, b.failures, b.latency, b.probes = now, 0, 0, 0, 0
+}
+
+// isBreakerFailure reports if err means that node is struggling, ok is false if err
+// doesn't tell anything about the node, e.g. if the query was canceled or its deadline passed.
+func isBreakerFailure(err error) (failure, ok bool) {
+	if err == nil {
+		return false, true
+	}
+	var coded CodedError
+	if errors.As(err, &coded) {
+		switch coded.ErrorCode() {
+		case frame.ErrCodeOverloaded, frame.ErrCodeReadTimeout, frame.ErrCodeWriteTimeout:
+			return true, true
+		default:
+			return false, true
+		}
+	}
+	return false, false
+}
+
+// AdmitAttempt must be called right before an attempt to execute a statement is sent to node, it returns ErrCircuitOpen
+// if node circuit breaker is open. In half-open state it admits a limited number of probes, result of every admitted
+// attempt must be reported with ObserveAttempt.
+func (n *Node) AdmitAttempt() error {
+	if !n.breaker.admit(Now()) {
+		return fmt.Errorf("node %v: %w", n.addr, ErrCircuitOpen)
+	}
+	return nil
+}
+
+// ObserveAttempt reports result of an attempt to execute a statement on node to its circuit breaker.
+func (n *Node) ObserveAttempt(latency time.Duration, err error) {
+	n.breaker.record(Now(), latency, err)
+}
@@ -0,0 +1,214 @@
+package transport
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/scylladb/scylla-go-driver/frame"
+	. "github.com/scylladb/scylla-go-driver/frame/response"
+	"github.com/scylladb/scylla-go-driver/log"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	t.Parallel()
+	overloaded := ScyllaError{Code: frame.ErrCodeOverloaded}
+	cfg := CircuitBreakerConfig{
+		Window:         10 * time.Second,
+		MinRequests:    4,
+		ErrorRate:      0.5,
+		Latency:        100 * time.Millisecond,
+		OpenDuration:   5 * time.Second,
+		HalfOpenProbes: 2,
+	}
+
+	type event struct {
+		at      time.Duration
+		latency time.Duration
+		err     error
+		// allow checks if attempt is admitted instead of recording it.
+		allow    bool
+		expected bool
+	}
+	testCases := []struct {
+		name   string
+		events []event
+		state  CircuitState
+	}{
+		{
+			name: "not enough requests",
+			events: []event{
+				{err: overloaded},
+				{err: overloaded},
+				{err: overloaded},
+			},
+			state: CircuitClosed,
+		},
+		{
+			name: "error rate",
+			events: []event{
+				{err: overloaded},
+				{},
+				{err: ReadTimeoutError{ScyllaError: ScyllaError{Code: frame.ErrCodeReadTimeout}}},
+				{},
+				{allow: true, expected: false},
+			},
+			state: CircuitOpen,
+		},
+		{
+			name: "errors not caused by node",
+			events: []event{
+				{err: ScyllaError{Code: frame.ErrCodeSyntax}},
+				{err: ScyllaError{Code: frame.ErrCodeInvalid}},
+				{err: context.Canceled},
+				{err: overloaded},
+				{},
+			},
+			state: CircuitClosed,
+		},
+		{
+			name: "server timeout",
+			events: []event{
+				{err: WriteTimeoutError{ScyllaError: ScyllaError{Code: frame.ErrCodeWriteTimeout}}},
+				{err: ReadTimeoutError{ScyllaError: ScyllaError{Code: frame.ErrCodeReadTimeout}}},
+				{},
+				{},
+			},
+			state: CircuitOpen,
+		},
+		{
+			name: "query deadline",
+			events: []event{
+				{err: fmt.Errorf("no response, %w", context.DeadlineExceeded)},
+				{err: fmt.Errorf("no response, %w", context.DeadlineExceeded)},
+				{err: fmt.Errorf("no response, %w", context.DeadlineExceeded)},
+				{err: overloaded},
+				{},
+			},
+			state: CircuitClosed,
+		},
+		{
+			name: "latency",
+			events: []event{
+				{latency: 50 * time.Millisecond},
+				{latency: 50 * time.Millisecond},
+				{latency: 100 * time.Millisecond},
+				{latency: 200 * time.Millisecond},
+			},
+			state: CircuitOpen,
+		},
+		{
+			name: "window",
+			events: []event{
+				{err: overloaded},
+				{err: overloaded},
+				{err: overloaded},
+				{at: 11 * time.Second, err: overloaded},
+			},
+			state: CircuitClosed,
+		},
+		{
+			name: "probes close circuit",
+			events: []event{
+				{err: overloaded}, {err: overloaded}, {err: overloaded}, {err: overloaded},
+				{at: 4 * time.Second, allow: true, expected: false},
+				{at: 5 * time.Second, allow: true, expected: true},
+				{at: 5 * time.Second, allow: true, expected: true},
+				{at: 5 * time.Second, allow: true, expected: false},
+				{at: 5 * time.Second},
+				{at: 5 * time.Second},
+				{at: 5 * time.Second, allow: true, expected: true},
+			},
+			state: CircuitClosed,
+		},
+		{
+			name: "failed probe opens circuit",
+			events: []event{
+				{err: overloaded}, {err: overloaded}, {err: overloaded}, {err: overloaded},
+				{at: 5 * time.Second, allow: true, expected: true},
+				{at: 5 * time.Second, latency: time.Second},
+				{at: 9 * time.Second, allow: true, expected: false},
+			},
+			state: CircuitOpen,
+		},
+		{
+			name: "lost probes are replaced",
+			events: []event{
+				{err: overloaded}, {err: overloaded}, {err: overloaded}, {err: overloaded},
+				{at: 5 * time.Second, allow: true, expected: true},
+				{at: 5 * time.Second, allow: true, expected: true},
+				{at: 9 * time.Second, allow: true, expected: false},
+				{at: 10 * time.Second, allow: true, expected: true},
+			},
+			state: CircuitHalfOpen,
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			start := time.Unix(0, 0)
+			b := newCircuitBreaker(cfg, "127.0.0.1", log.NopLogger{})
+			b.since = start
+			for i, e := range tc.events {
+				now := start.Add(e.at)
+				if e.allow {
+					if v := !b.open(now); v != e.expected {
+						t.Fatalf("event %d: open() = %v, expected %v", i, !v, !e.expected)
+					}
+					if v := b.admit(now); v != e.expected {
+						t.Fatalf("event %d: admit() = %v, expected %v", i, v, e.expected)
+					}
+					continue
+				}
+				b.record(now, e.latency, e.err)
+			}
+			if s := b.currentState(); s != tc.state {
+				t.Fatalf("state %v, expected %v", s, tc.state)
+			}
+		})
+	}
+}
+
+func TestCircuitBreakerDisabled(t *testing.T) {
+	t.Parallel()
+	b := newCircuitBreaker(CircuitBreakerConfig{ErrorRate: 0.1}, "127.0.0.1", log.NopLogger{})
+	if b != nil {
+		t.Fatalf("expected nil breaker, got %+v", b)
+	}
+	b.record(time.Now(), 0, ScyllaError{Code: frame.ErrCodeOverloaded})
+	if b.open(time.Now()) || !b.admit(time.Now()) || b.currentState() != CircuitClosed {
+		t.Fatal("disabled breaker should be closed")
+	}
+}
+
+func TestCircuitBreakerDefaultMinRequests(t *testing.T) {
+	t.Parallel()
+	readTimeout := ReadTimeoutError{ScyllaError: ScyllaError{Code: frame.ErrCodeReadTimeout}}
+	start := time.Unix(0, 0)
+	b := newCircuitBreaker(CircuitBreakerConfig{Window: 10 * time.Second, ErrorRate: 0.5}, "127.0.0.1", log.NopLogger{})
+	b.since = start
+
+	// One failure at window start does not trip.
+	b.record(start, time.Millisecond, readTimeout)
+	if s := b.currentState(); s != CircuitClosed {
+		t.Fatalf("state %v after a single failure, expected %v", s, CircuitClosed)
+	}
+
+	for i := 1; i < defaultCircuitBreakerMinRequests-1; i++ {
+		var err error
+		if i%2 == 0 {
+			err = readTimeout
+		}
+		b.record(start, time.Millisecond, err)
+	}
+	if s := b.currentState(); s != CircuitClosed {
+		t.Fatalf("state %v before MinRequests, expected %v", s, CircuitClosed)
+	}
+	b.record(start, time.Millisecond, readTimeout)
+	if s := b.currentState(); s != CircuitOpen {
+		t.Fatalf("state %v after MinRequests, expected %v", s, CircuitOpen)
+	}
+}
@@ -7,10 +7,12 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/scylladb/scylla-go-driver/frame"
 	. "github.com/scylladb/scylla-go-driver/frame/response"
+	"github.com/scylladb/scylla-go-driver/log"
 
 	"go.uber.org/atomic"
 )
@@ -33,17 +35,48 @@ type Cluster struct {
 	refreshChan       requestChan
 	reopenControlChan requestChan
 	closeChan         requestChan
+	controlSchedule   ReconnectionSchedule
+	hostFilter        atomic.Value // hostFilter
+	tablets           *tabletCache
+	limiter           *limiter
 
 	queryInfoCounter atomic.Uint64
 }
 
 type topology struct {
 	localDC    string
+	localRack  string
 	peers      peerMap
 	dcRacks    dcRacksMap
 	Nodes      []*Node
 	policyInfo policyInfo
 	keyspaces  ksMap
+
+	// strategies caches policy info of replication strategies of keyspaces other than the session keyspace,
+	// it's computed on first use and shared by keyspaces with identical strategies.
+	strategies   sync.Map // strategy key -> *policyInfo
+	strategiesMu sync.Mutex
+}
+
+// strategyPolicyInfo returns policy info for replication strategy of keyspace ks.
+func (t *topology) strategyPolicyInfo(ks keyspace, logger log.Logger) *policyInfo {
+	key := ks.strategy.key()
+	if v, ok := t.strategies.Load(key); ok {
+		return v.(*policyInfo)
+	}
+
+	t.strategiesMu.Lock()
+	defer t.strategiesMu.Unlock()
+	if v, ok := t.strategies.Load(key); ok {
+		return v.(*policyInfo)
+	}
+	pi := &policyInfo{ring: make(Ring, len(t.policyInfo.ring))}
+	for i, e := range t.policyInfo.ring {
+		pi.ring[i] = RingEntry{node: e.node, token: e.token}
+	}
+	pi.Preprocess(t, ks, logger)
+	t.strategies.Store(key, pi)
+	return pi
 }
 
 type keyspace struct {
@@ -72,6 +105,34 @@ type strategy struct {
 	data  map[string]string // Used in other strategy.
 }
 
+// key returns string identifying strategy, identical strategies have equal keys.
+func (s strategy) key() string {
+	var b strings.Builder
+	b.WriteString(string(s.class))
+	b.WriteString(":")
+	b.WriteString(strconv.FormatUint(uint64(s.rf), 10))
+	writeSorted := func(m map[string]string) {
+		keys := make([]string, 0, len(m))
+		for k := range m {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			b.WriteString(",")
+			b.WriteString(k)
+			b.WriteString("=")
+			b.WriteString(m[k])
+		}
+	}
+	dcRF := make(map[string]string, len(s.dcRF))
+	for k, v := range s.dcRF {
+		dcRF[k] = strconv.FormatUint(uint64(v), 10)
+	}
+	writeSorted(dcRF)
+	writeSorted(s.data)
+	return b.String()
+}
+
 // QueryInfo represents data required for host selection policy to create query plan.
 // Token and strategy are only necessary for token aware policies.
 type QueryInfo struct {
@@ -80,6 +141,20 @@ type QueryInfo struct {
 	topology   *topology
 	strategy   strategy
 	offset     uint64 // For round robin strategies.
+	// pi is policy info of the query keyspace, nil means the session keyspace.
+	pi *policyInfo
+	// tablet holds replicas of the tablet containing token, if known they are used instead of the ring.
+	tablet *tabletReplicas
+	// plan memoizes load dependent decisions of policies, so that they don't change while the plan is iterated.
+	// It's shared by copies of QueryInfo, nil plan is not memoized.
+	plan *queryPlan
+}
+
+func (qi *QueryInfo) policyInfo() *policyInfo {
+	if qi.pi != nil {
+		return qi.pi
+	}
+	return &qi.topology.policyInfo
 }
 
 func (c *Cluster) NewQueryInfo() QueryInfo {
@@ -87,6 +162,7 @@ func (c *Cluster) NewQueryInfo() QueryInfo {
 		tokenAware: false,
 		topology:   c.Topology(),
 		offset:     c.generateOffset(),
+		plan:       new(queryPlan),
 	}
 }
 
@@ -100,22 +176,26 @@ func (c *Cluster) NewTokenAwareQueryInfo(t Token, ks string) (QueryInfo, error)
 		}
 		ks = c.cfg.Keyspace
 	}
-	if stg, ok := top.keyspaces[ks]; ok {
-		return QueryInfo{
-			tokenAware: true,
-			token:      t,
-			topology:   top,
-			strategy:   stg.strategy,
-			offset:     c.generateOffset(),
-		}, nil
-	} else {
-		var allKs []string
-		for k := range top.keyspaces {
-			allKs = append(allKs, k)
-		}
-		sort.Strings(allKs)
-		return QueryInfo{}, fmt.Errorf("couldn't find keyspace %q in current topology, known keyspaces are: %s", ks, strings.Join(allKs, ", "))
+	stg, ok := top.keyspaces[ks]
+	if !ok {
+		// Keyspace may have been created after the last topology refresh, fallback to non-token aware query
+		// until the topology is refreshed.
+		c.cfg.Logger.Debug("cluster: keyspace not found in topology, requesting topology refresh", log.Keyspace(ks))
+		c.RequestRefresh()
+		return c.NewQueryInfo(), nil
+	}
+	qi := QueryInfo{
+		tokenAware: true,
+		token:      t,
+		topology:   top,
+		strategy:   stg.strategy,
+		offset:     c.generateOffset(),
+		plan:       new(queryPlan),
 	}
+	if ks != c.cfg.Keyspace {
+		qi.pi = top.strategyPolicyInfo(stg, c.cfg.Logger)
+	}
+	return qi, nil
 }
 
 // TODO overflow and negative modulo.
@@ -137,13 +217,18 @@ func NewCluster(ctx context.Context, cfg ConnConfig, p HostSelectionPolicy, e []
 		refreshChan:       make(requestChan, 1),
 		reopenControlChan: make(requestChan, 1),
 		closeChan:         make(requestChan, 1),
+		controlSchedule:   newReconnectionSchedule(cfg.ReconnectionPolicy),
+		tablets:           newTabletCache(),
+		limiter:           newLimiter(cfg.Limits.Global),
 	}
+	c.hostFilter.Store(hostFilter{f: cfg.HostFilter})
 
-	localDC := ""
-	if p, ok := p.(*TokenAwarePolicy); ok {
-		localDC = p.localDC
+	top := &topology{}
+	if p, ok := tokenAwarePolicy(p); ok {
+		top.localDC = p.localDC
+		top.localRack = p.localRack
 	}
-	c.setTopology(&topology{localDC: localDC})
+	c.setTopology(top)
 
 	if control, err := c.NewControl(ctx); err != nil {
 		return nil, fmt.Errorf("create control connection: %w", err)
@@ -158,10 +243,11 @@ func NewCluster(ctx context.Context, cfg ConnConfig, p HostSelectionPolicy, e []
 	return c, nil
 }
 
+// NewControl opens control connection to one of known hosts accepted by host filter.
 func (c *Cluster) NewControl(ctx context.Context) (*Conn, error) {
 	c.cfg.Logger.Info("cluster: open control connection")
 	var errs []string
-	for addr := range c.knownHosts {
+	for _, addr := range c.controlHosts() {
 		conn, err := OpenConn(ctx, addr, nil, c.cfg)
 		if err == nil {
 			if err := conn.RegisterEventHandler(ctx, c.handleEvent, c.handledEvents...); err == nil {
@@ -180,10 +266,40 @@ func (c *Cluster) NewControl(ctx context.Context) (*Conn, error) {
 	return nil, fmt.Errorf("couldn't open control connection to any known host:\n%s", strings.Join(errs, "\n"))
 }
 
+// controlHosts returns known hosts in the order they should be tried for control connection.
+// Nodes from topology rejected by host filter are skipped. Hosts that are not in topology yet
+// can be checked only by address, the rejected ones are tried last as the filter may depend
+// on datacenter or rack that aren't known until topology is fetched.
+func (c *Cluster) controlHosts() []string {
+	filter := c.HostFilter()
+	peers := c.Topology().peers
+	var accepted, unknown []string
+	for addr := range c.knownHosts {
+		if n, ok := peers[addr]; ok {
+			if filter.accepts(n) {
+				accepted = append(accepted, addr)
+			}
+		} else if filter == nil || filter(NodeInfo{Addr: addr}) {
+			accepted = append(accepted, addr)
+		} else {
+			unknown = append(unknown, addr)
+		}
+	}
+	return append(accepted, unknown...)
+}
+
 // refreshTopology creates new topology filled with the result of keyspaceQuery, localQuery and peerQuery.
 // Old topology is replaced with the new one atomically to prevent dirty reads.
-func (c *Cluster) refreshTopology(ctx context.Context) error {
-	c.cfg.Logger.Infoln("cluster: refresh topology")
+func (c *Cluster) refreshTopology(ctx context.Context) (err error) {
+	c.cfg.Logger.Debug("cluster: refresh topology")
+	if c.cfg.Metrics != nil {
+		span := startSpan()
+		defer func() {
+			span.stop()
+			c.cfg.Metrics.OnTopologyRefresh(TopologyRefreshEvent{span: span, Err: err})
+		}()
+	}
+
 	rows, err := c.getAllNodesInfo(ctx)
 	if err != nil {
 		return fmt.Errorf("query info about nodes in cluster: %w", err)
@@ -192,10 +308,12 @@ func (c *Cluster) refreshTopology(ctx context.Context) error {
 	old := c.Topology().peers
 	t := newTopology()
 	t.localDC = c.Topology().localDC
+	t.localRack = c.Topology().localRack
 	t.keyspaces, err = c.updateKeyspace(ctx)
 	if err != nil {
 		return fmt.Errorf("query keyspaces: %w", err)
 	}
+	c.tablets.retainKeyspaces(t.keyspaces)
 
 	type uniqueRack struct {
 		dc   string
@@ -203,16 +321,29 @@ func (c *Cluster) refreshTopology(ctx context.Context) error {
 	}
 	u := make(map[uniqueRack]struct{})
 
+	filter := c.HostFilter()
 	for _, r := range rows {
 		n, err := c.parseNodeFromRow(r)
 		if err != nil {
 			return err
 		}
-		// If node is present in both maps we can reuse its connection pool.
-		if node, ok := old[n.addr]; ok {
-			n.pool = node.pool
+		prev, known := old[n.addr]
+		if known {
+			n.limiter, n.breaker = prev.limiter, prev.breaker
+		} else {
+			n.limiter = newLimiter(c.cfg.Limits.Node)
+			n.breaker = newCircuitBreaker(c.cfg.CircuitBreaker, n.addr, c.cfg.Logger)
+		}
+		n.filtered = !filter.accepts(n)
+		if !n.filtered {
+			// If node is present in both maps we can reuse its connection pool.
+			if known {
+				// Init doesn't set status of reused pool, without it the node would be marked DOWN.
+				n.pool = prev.pool
+				n.setStatus(prev.IsUp())
+			}
+			n.Init(ctx, c.cfg)
 		}
-		n.Init(ctx, c.cfg)
 
 		// Every encountered node becomes known host for future use.
 		c.knownHosts[n.addr] = struct{}{}
@@ -227,9 +358,9 @@ func (c *Cluster) refreshTopology(ctx context.Context) error {
 	for k := range u {
 		t.dcRacks[k.dc]++
 	}
-	// We want to close pools of nodes present in previous and absent in current topology.
+	// We want to close pools of nodes present in previous and absent or filtered in current topology.
 	for k, v := range old {
-		if _, ok := t.peers[k]; !ok {
+		if n, ok := t.peers[k]; !ok || n.filtered {
 			v.Close()
 		}
 	}
@@ -436,6 +567,18 @@ func parseTokensFromRow(n *Node, r frame.Row, ring *Ring) error {
 	return nil
 }
 
+// HostFilter returns the current host filter.
+func (c *Cluster) HostFilter() HostFilter {
+	return c.hostFilter.Load().(hostFilter).f
+}
+
+// SetHostFilter replaces host filter and requests topology refresh to apply it,
+// pools of nodes rejected by the new filter are closed.
+func (c *Cluster) SetHostFilter(f HostFilter) {
+	c.hostFilter.Store(hostFilter{f: f})
+	c.RequestRefresh()
+}
+
 func (c *Cluster) Topology() *topology {
 	return c.topology.Load().(*topology)
 }
@@ -449,7 +592,7 @@ func (c *Cluster) setTopology(t *topology) {
 // of registering handlers for them.
 func (c *Cluster) handleEvent(ctx context.Context, r response) {
 	if r.Err != nil {
-		c.cfg.Logger.Infoln("cluster: received event with error: %v", r.Err)
+		c.cfg.Logger.Info("cluster: received event with error", log.Err(r.Err))
 		c.RequestReopenControl()
 		return
 	}
@@ -461,30 +604,33 @@ func (c *Cluster) handleEvent(ctx context.Context, r response) {
 	case *SchemaChange:
 		// TODO: add schema change.
 	default:
-		c.cfg.Logger.Warnf("cluster: unsupported event type: %v", r.Response)
+		c.cfg.Logger.Warn("cluster: unsupported event type", log.Any("event", r.Response))
 	}
 }
 
 func (c *Cluster) handleTopologyChange(v *TopologyChange) {
-	c.cfg.Logger.Infof("cluster: handle topology change: %+#v", v)
+	c.cfg.Logger.Info("cluster: handle topology change", log.Node(v.Address.String()), log.Any("change", v.Change))
 	c.RequestRefresh()
 }
 
 func (c *Cluster) handleStatusChange(ctx context.Context, v *StatusChange) {
-	c.cfg.Logger.Infof("cluster: handle status change: %+#v", v)
+	c.cfg.Logger.Info("cluster: handle status change", log.Node(v.Address.String()), log.Any("status", v.Status))
 	m := c.Topology().peers
 	addr := v.Address.String()
 	if n, ok := m[addr]; ok {
+		if n.filtered {
+			return
+		}
 		switch v.Status {
 		case frame.Up:
 			n.Init(ctx, c.cfg)
 		case frame.Down:
 			n.setStatus(statusDown)
 		default:
-			c.cfg.Logger.Warnf("cluster: status change not supported: %+#v", v)
+			c.cfg.Logger.Warn("cluster: status change not supported", log.Node(addr), log.Any("status", v.Status))
 		}
 	} else {
-		c.cfg.Logger.Infof("cluster: unknown node %s received status change: %+#v in topology %v, requesting topology refresh", addr, v, m)
+		c.cfg.Logger.Info("cluster: status change of unknown node, requesting topology refresh", log.Node(addr), log.Any("status", v.Status))
 		c.RequestRefresh()
 	}
 }
@@ -502,7 +648,7 @@ func (c *Cluster) loop(ctx context.Context) {
 		case <-c.reopenControlChan:
 			c.tryReopenControl(ctx)
 		case <-ctx.Done():
-			c.cfg.Logger.Infof("cluster closing due to: %v", ctx.Err())
+			c.cfg.Logger.Info("cluster: closing", log.Err(ctx.Err()))
 			c.handleClose()
 			return
 		case <-c.closeChan:
@@ -522,18 +668,18 @@ func (c *Cluster) tryRefresh(ctx context.Context) {
 	if err := c.refreshTopology(ctx); err != nil {
 		c.RequestReopenControl()
 		time.AfterFunc(tryRefreshInterval, c.RequestRefresh)
-		c.cfg.Logger.Infof("cluster: refresh topology: %v", err)
+		c.cfg.Logger.Info("cluster: refresh topology failed", log.Err(err))
 	}
 }
 
-const tryReopenControlInterval = time.Second
-
 func (c *Cluster) tryReopenControl(ctx context.Context) {
-	c.cfg.Logger.Infoln("cluster: reopen control connection")
+	c.cfg.Logger.Info("cluster: reopen control connection")
 	if control, err := c.NewControl(ctx); err != nil {
-		time.AfterFunc(tryReopenControlInterval, c.RequestReopenControl)
-		c.cfg.Logger.Infof("cluster: failed to reopen control connection: %v", err)
+		d := c.controlSchedule.NextDelay()
+		time.AfterFunc(d, c.RequestReopenControl)
+		c.cfg.Logger.Info("cluster: failed to reopen control connection", log.Duration("next_attempt_in", d), log.Err(err))
 	} else {
+		c.controlSchedule.Reset()
 		c.control.Close()
 		c.control = control
 	}
@@ -541,7 +687,7 @@ func (c *Cluster) tryReopenControl(ctx context.Context) {
 }
 
 func (c *Cluster) handleClose() {
-	c.cfg.Logger.Infoln("cluster: handle cluster close")
+	c.cfg.Logger.Info("cluster: handle cluster close")
 	c.control.Close()
 	m := c.Topology().peers
 	for _, n := range m {
@@ -550,7 +696,7 @@ func (c *Cluster) handleClose() {
 }
 
 func (c *Cluster) RequestRefresh() {
-	c.cfg.Logger.Infoln("cluster: requested to refresh cluster topology")
+	c.cfg.Logger.Debug("cluster: requested to refresh cluster topology")
 	select {
 	case c.refreshChan <- struct{}{}:
 	default:
@@ -558,7 +704,7 @@ func (c *Cluster) RequestRefresh() {
 }
 
 func (c *Cluster) RequestReopenControl() {
-	c.cfg.Logger.Infoln("cluster: requested to reopen control connection")
+	c.cfg.Logger.Debug("cluster: requested to reopen control connection")
 	select {
 	case c.reopenControlChan <- struct{}{}:
 	default:
@@ -566,7 +712,7 @@ func (c *Cluster) RequestReopenControl() {
 }
 
 func (c *Cluster) Close() {
-	c.cfg.Logger.Infoln("cluster: requested to close cluster")
+	c.cfg.Logger.Debug("cluster: requested to close cluster")
 	select {
 	case c.closeChan <- struct{}{}:
 	default:
@@ -0,0 +1,139 @@
+package transport_test
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/scylladb/scylla-go-driver/scyllatest"
+	"github.com/scylladb/scylla-go-driver/transport"
+)
+
+func TestClusterRefreshTopologyKeepsNodeStatus(t *testing.T) {
+	t.Parallel()
+	srv := scyllatest.NewServer(scyllatest.DefaultConfig(3))
+	defer srv.Close()
+
+	cfg := srv.ConnConfig()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	c, err := transport.NewCluster(ctx, cfg, transport.NewTokenAwarePolicy(""), nil, srv.Hosts()...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	// Nodes present before and after refresh reuse their connection pools and stay UP.
+	old := c.Topology()
+	c.RequestRefresh()
+	for c.Topology() == old {
+		select {
+		case <-ctx.Done():
+			t.Fatal("topology not refreshed")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	for _, n := range c.Topology().Nodes {
+		if info := n.Info(); !info.Up {
+			t.Fatalf("node %s is down after refresh", info.Addr)
+		}
+	}
+}
+
+// dialRecorder counts dials of hosts through the wrapped dialer.
+type dialRecorder struct {
+	transport.Dialer
+
+	mu    sync.Mutex
+	hosts map[string]int
+}
+
+func newDialRecorder(d transport.Dialer) *dialRecorder {
+	return &dialRecorder{Dialer: d, hosts: make(map[string]int)}
+}
+
+func (d *dialRecorder) DialContext(ctx context.Context, addr string, si transport.ShardInfo, localPort uint16) (net.Conn, error) {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+	d.mu.Lock()
+	d.hosts[host]++
+	d.mu.Unlock()
+	return d.Dialer.DialContext(ctx, addr, si, localPort)
+}
+
+func (d *dialRecorder) dials(host string) int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.hosts[host]
+}
+
+func (d *dialRecorder) total() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var n int
+	for _, v := range d.hosts {
+		n += v
+	}
+	return n
+}
+
+func TestClusterControlConnHostFilter(t *testing.T) {
+	t.Parallel()
+	// Addresses of scyllatest.DefaultConfig nodes.
+	hosts := []string{"127.0.0.1", "127.0.0.2", "127.0.0.3"}
+
+	testCases := []struct {
+		name   string
+		filter transport.HostFilter
+		denied string
+	}{
+		{
+			name:   "deny host",
+			filter: transport.DenyHosts(hosts[0]),
+			denied: hosts[0],
+		},
+		{
+			// Datacenter of contact points is unknown before topology is fetched.
+			name:   "allow dc",
+			filter: transport.AllowDCs("datacenter1"),
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			srv := scyllatest.NewServer(scyllatest.DefaultConfig(len(hosts)))
+			defer srv.Close()
+			d := newDialRecorder(srv)
+			cfg := srv.ConnConfig()
+			cfg.Dialer = d
+			cfg.HostFilter = tc.filter
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			c, err := transport.NewCluster(ctx, cfg, transport.NewTokenAwarePolicy(""), nil, hosts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer c.Close()
+
+			// Control connection is reopened to hosts known from topology.
+			for i := 0; i < 20; i++ {
+				conn, err := c.NewControl(ctx)
+				if err != nil {
+					t.Fatal(err)
+				}
+				conn.Close()
+			}
+			if tc.denied != "" {
+				if n := d.dials(tc.denied); n > 0 {
+					t.Fatalf("filtered host %s dialed %d times", tc.denied, n)
+				}
+			}
+		})
+	}
+}
@@ -9,7 +9,6 @@ import (
 	"fmt"
 	"io"
 	"net"
-	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -25,6 +24,7 @@ import (
 
 type response struct {
 	frame.Header
+	frame.MsgOptionalFields
 	frame.Response
 	Err error
 }
@@ -36,6 +36,7 @@ type request struct {
 	StreamID        frame.StreamID
 	Compress        bool
 	Tracing         bool
+	CustomPayload   frame.BytesMap
 	ResponseHandler ResponseHandler
 
 	ctx context.Context // nolint:containedctx // cancelling sending request can't be done without it.
@@ -53,11 +54,14 @@ func (e *skippedError) Unwrap() error {
 	return e.err
 }
 
+// _connCloseRequest is the only request without frame.Request, it terminates writer loop.
 var _connCloseRequest = request{ctx: context.Background()}
 
 type stats struct {
 	inFlight atomic.Uint32
 	inQueue  atomic.Uint32
+	// received counts successfully received frames, it's used for idle detection.
+	received atomic.Uint64
 }
 
 type connWriter struct {
@@ -67,11 +71,16 @@ type connWriter struct {
 	requestCh  chan request
 	stats      *stats
 	connString func() string
+	connEvent  func() ConnEvent
 	connClose  func()
 
 	// For use only when skipping sending a request.
 	freeStream func(frame.StreamID)
-	log        log.Logger
+	// record is not nil if frame recording is enabled.
+	record func(FrameDirection, frame.Header, []byte)
+	// onFrame is not nil if metrics are enabled.
+	onFrame func(dir FrameDirection, size, uncompressedSize int)
+	log     log.Logger
 }
 
 func (c *connWriter) submit(r request) {
@@ -96,7 +105,7 @@ func (c *connWriter) loop(ctx context.Context) {
 
 		for i := 0; i < size; i++ {
 			r := <-c.requestCh
-			if r == _connCloseRequest {
+			if r.Request == nil {
 				return
 			}
 			c.stats.inQueue.Dec()
@@ -106,14 +115,14 @@ func (c *connWriter) loop(ctx context.Context) {
 					c.freeStream(r.StreamID)
 					continue
 				}
-				c.log.Infof("%s fatal send error, closing connection due to %s", c.connString(), err)
+				c.log.Info("fatal send error, closing connection", c.connEvent().logAttrs(log.Err(err))...)
 				c.connClose()
 				return
 			}
 			c.stats.inFlight.Inc()
 		}
 		if err := c.conn.Flush(); err != nil {
-			c.log.Infof("%s fatal flush error, closing connection due to %s", c.connString(), err)
+			c.log.Info("fatal flush error, closing connection", c.connEvent().logAttrs(log.Err(err))...)
 			c.connClose()
 			return
 		}
@@ -129,7 +138,16 @@ func (c *connWriter) send(ctx context.Context, r request) error {
 		StreamID: r.StreamID,
 		OpCode:   r.OpCode(),
 	}
+	if r.Tracing {
+		h.Flags |= frame.Tracing
+	}
+	if len(r.CustomPayload) > 0 {
+		h.Flags |= frame.CustomPayload
+	}
 	h.WriteTo(&c.buf)
+	if len(r.CustomPayload) > 0 {
+		c.buf.WriteBytesMap(r.CustomPayload)
+	}
 	r.WriteTo(&c.buf)
 
 	// Update length in header
@@ -145,16 +163,27 @@ func (c *connWriter) send(ctx context.Context, r request) error {
 		return &skippedError{err: r.ctx.Err()}
 	}
 
+	if c.record != nil {
+		h.Length = frame.Int(l)
+		c.record(FrameSent, h, b[frame.HeaderSize:])
+	}
+
 	// Send
-	var err error
+	var (
+		n   int64
+		err error
+	)
 	if r.Compress {
 		if c.compr != nil {
-			_, err = c.compr.compress(ctx, r.ctx, c.conn, c.buf.BytesBuffer())
+			n, err = c.compr.compress(ctx, r.ctx, c.conn, c.buf.BytesBuffer())
 		} else {
 			return errComprUnspecified
 		}
 	} else {
-		_, err = frame.CopyBuffer(&c.buf, c.conn)
+		n, err = frame.CopyBuffer(&c.buf, c.conn)
+	}
+	if err == nil && c.onFrame != nil {
+		c.onFrame(FrameSent, int(n), len(b))
 	}
 	return err
 }
@@ -167,12 +196,19 @@ type connReader struct {
 	compr       *compr
 	handleEvent func(context.Context, response)
 	connString  func() string
+	connEvent   func() ConnEvent
 	connClose   func()
-
-	h      map[frame.StreamID]ResponseHandler
-	s      streamIDAllocator
-	closed bool
-	mu     sync.Mutex // mu guards h, s and closed
+	record      func(FrameDirection, frame.Header, []byte)
+	// onFrame and onResponse are not nil if metrics are enabled.
+	onFrame    func(dir FrameDirection, size, uncompressedSize int)
+	onResponse func(start time.Time, resp response)
+
+	h map[frame.StreamID]ResponseHandler
+	// started holds start times of requests if metrics are enabled.
+	started map[frame.StreamID]time.Time
+	s       streamIDAllocator
+	closed  bool
+	mu      sync.Mutex // mu guards h, started, s and closed
 
 	log log.Logger
 }
@@ -191,23 +227,29 @@ func (c *connReader) setHandler(h ResponseHandler) (frame.StreamID, error) {
 	}
 
 	c.h[streamID] = h
+	if c.started != nil {
+		c.started[streamID] = Now()
+	}
 	return streamID, err
 }
 
-// handler free given streamID and return corresponding handler.
-func (c *connReader) handler(streamID frame.StreamID) ResponseHandler {
+// handler free given streamID and return corresponding handler and the request start time.
+func (c *connReader) handler(streamID frame.StreamID) (ResponseHandler, time.Time) {
 	c.mu.Lock()
 	h := c.h[streamID]
+	start := c.started[streamID]
 	c.s.Free(streamID)
 	delete(c.h, streamID)
+	delete(c.started, streamID)
 	c.mu.Unlock()
-	return h
+	return h, start
 }
 
 func (c *connReader) freeStream(streamID frame.StreamID) {
 	c.mu.Lock()
 	c.s.Free(streamID)
 	delete(c.h, streamID)
+	delete(c.started, streamID)
 	c.mu.Unlock()
 }
 
@@ -224,22 +266,25 @@ func (c *connReader) loop(ctx context.Context) {
 		}
 
 		if resp.Err != nil {
-			c.log.Infof("%s fatal receive error, closing connection due to %s", c.connString(), resp.Err)
+			c.log.Info("fatal receive error, closing connection", c.connEvent().logAttrs(log.Err(resp.Err))...)
 			c.connClose()
 			c.drainHandlers()
 			return
 		}
 
-		c.stats.inFlight.Dec()
-
-		if h := c.handler(resp.StreamID); h != nil {
-			h <- resp
-		} else {
-			c.log.Warnf("%s received unknown stream ID %d, closing connection", c.connString(), resp.StreamID)
+		h, start := c.handler(resp.StreamID)
+		if h == nil {
+			c.log.Warn("received unknown stream ID, closing connection", c.connEvent().logAttrs(log.Stream(int(resp.StreamID)))...)
 			c.connClose()
 			c.drainHandlers()
 			return
 		}
+
+		if c.onResponse != nil {
+			c.onResponse(start, resp)
+		}
+		c.stats.inFlight.Dec()
+		h <- resp
 	}
 }
 
@@ -274,6 +319,16 @@ func (c *connReader) recv() response {
 		}
 	}
 
+	if c.record != nil {
+		c.record(FrameReceived, r.Header, c.buf.Bytes())
+	}
+	if c.onFrame != nil {
+		c.onFrame(FrameReceived, frame.HeaderSize+int(r.Header.Length), frame.HeaderSize+len(c.buf.Bytes()))
+	}
+
+	if r.Header.Flags&(frame.Tracing|frame.Warning|frame.CustomPayload) != 0 {
+		r.MsgOptionalFields = frame.ParseMsgOptionalFields(&c.buf, r.Header.Flags)
+	}
 	r.Response = c.parse(r.Header.OpCode)
 	if r.Response == nil {
 		r.Err = fmt.Errorf("response type not supported")
@@ -284,6 +339,7 @@ func (c *connReader) recv() response {
 		return r
 	}
 
+	c.stats.received.Inc()
 	return r
 }
 
@@ -297,38 +353,25 @@ func (c *connReader) drainHandlers() {
 }
 
 func (c *connReader) parse(op frame.OpCode) frame.Response {
-	// TODO add all responses
-	switch op {
-	case frame.OpError:
-		return ParseError(&c.buf)
-	case frame.OpReady:
-		return ParseReady(&c.buf)
-	case frame.OpResult:
-		return ParseResult(&c.buf)
-	case frame.OpSupported:
-		return ParseSupported(&c.buf)
-	case frame.OpEvent:
-		return ParseEvent(&c.buf)
-	case frame.OpAuthenticate:
-		return ParseAuthenticate(&c.buf)
-	case frame.OpAuthSuccess:
-		return ParseAuthSuccess(&c.buf)
-	case frame.OpAuthChallenge:
-		return ParseAuthChallenge(&c.buf)
-	default:
-		c.log.Warnf("not supported")
-		return nil
+	res := ParseResponse(op, &c.buf)
+	if res == nil {
+		c.log.Warn("response not supported", c.connEvent().logAttrs(log.Any("opcode", op))...)
 	}
+	return res
 }
 
 type Conn struct {
-	cfg       ConnConfig
-	conn      net.Conn
-	event     ConnEvent
+	id   uint64
+	cfg  ConnConfig
+	conn net.Conn
+	addr string
+	// shard is set by init after the reader and writer loops are started.
+	shard     atomic.Uint32
 	w         connWriter
 	r         connReader
 	stats     *stats
 	closeOnce sync.Once
+	closed    chan struct{}
 	onClose   func(conn *Conn)
 }
 
@@ -343,6 +386,10 @@ type ConnConfig struct {
 	// Default: 500 milliseconds.
 	Timeout time.Duration
 
+	// Dialer opens network connections to nodes.
+	// Default: DefaultDialer with Timeout and TCPNoDelay.
+	Dialer Dialer
+
 	// If not nil, all connections will use TLS according to TLSConfig,
 	// please note that the default port (9042) may not support TLS.
 	TLSConfig *tls.Config
@@ -354,9 +401,58 @@ type ConnConfig struct {
 	Compression     frame.Compression
 	ComprBufferSize int
 
+	// HeartbeatInterval is the interval of sending heartbeats on idle connections,
+	// connection is considered idle if it did not receive any frame during the interval.
+	// If less or equal to 0, heartbeats are disabled.
+	// Default: 30 seconds.
+	HeartbeatInterval time.Duration
+	// HeartbeatTimeout is the time to wait for heartbeat response,
+	// connection that doesn't respond in time is closed.
+	// Default: 10 seconds.
+	HeartbeatTimeout time.Duration
+
+	// ConnsPerShard is the number of connections kept to every shard of a Scylla node.
+	// Default: 1
+	ConnsPerShard int
+	// ConnsPerHost is the number of connections kept to nodes that do not
+	// report sharding information, such as Apache Cassandra.
+	// Default: 4
+	ConnsPerHost int
+
+	// ReconnectionPolicy controls delays between attempts to refill connection pools
+	// and to reopen control connection.
+	// Default: ExponentialReconnectionPolicy with 1 second base and 1 minute max delay.
+	ReconnectionPolicy ReconnectionPolicy
+
+	// FrameRecorder records all frames sent and received on connections,
+	// it's meant for debugging protocol issues.
+	// Default: nil, frames are not recorded.
+	FrameRecorder FrameRecorder
+
+	// Metrics collects metrics of requests, connection pools and topology refreshes.
+	// Default: nil, metrics are not collected.
+	Metrics Metrics
+
+	// HostFilter excludes nodes from query plans, filtered nodes don't get connection pools.
+	// It can be replaced at runtime with Cluster.SetHostFilter.
+	// Default: nil, all nodes are used.
+	HostFilter HostFilter
+
+	// Limits are client side limits of request rate and concurrency.
+	// Default: no limits.
+	Limits AdmissionLimits
+
+	// CircuitBreaker controls per node circuit breakers, nodes with open circuit are skipped by queries.
+	// Default: disabled.
+	CircuitBreaker CircuitBreakerConfig
+
 	// Default: LoggingConnObserver
 	ConnObserver ConnObserver
 	Logger       log.Logger
+
+	// onClose is called when connection is closed, it's set by PoolRefiller before the connection
+	// is opened, so that connections closed before being stored in the pool are not missed.
+	onClose func(conn *Conn)
 }
 
 func DefaultConnConfig(keyspace string) ConnConfig {
@@ -371,6 +467,11 @@ func DefaultConnConfig(keyspace string) ConnConfig {
 		DefaultPort:        "9042",
 		ConnObserver:       LoggingConnObserver{l},
 		ComprBufferSize:    comprBufferSize,
+		HeartbeatInterval:  30 * time.Second,
+		HeartbeatTimeout:   10 * time.Second,
+		ConnsPerShard:      1,
+		ConnsPerHost:       defaultConnsPerHost,
+		ReconnectionPolicy: NewExponentialReconnectionPolicy(defaultReconnectionBase, defaultReconnectionMax),
 		Logger:             l,
 	}
 }
@@ -381,6 +482,7 @@ const (
 	maxCoalescedRequests = 100
 	ioBufferSize         = 8192
 	comprBufferSize      = 64 * 1024 // 64 Kb
+	defaultConnsPerHost  = 4
 )
 
 // OpenShardConn opens connection mapped to a specific shard on Scylla node.
@@ -388,9 +490,9 @@ func OpenShardConn(ctx context.Context, addr string, si ShardInfo, cfg ConnConfi
 	it := ShardPortIterator(si)
 	maxTries := (maxPort-minPort+1)/int(si.NrShards) + 1
 	for i := 0; i < maxTries; i++ {
-		conn, err := OpenLocalPortConn(ctx, addr, it(), cfg)
+		conn, err := openConn(ctx, addr, si, it(), cfg)
 		if err != nil {
-			cfg.Logger.Infof("%s dial error: %s (try %d/%d)", addr, err, i, maxTries)
+			cfg.Logger.Debug("dial error", log.Node(addr), log.Shard(int(si.Shard)), log.Err(err), log.Int("try", i), log.Int("max_tries", maxTries))
 			if conn != nil {
 				conn.Close()
 			}
@@ -406,35 +508,33 @@ func OpenShardConn(ctx context.Context, addr string, si ShardInfo, cfg ConnConfi
 //
 // If error and connection are returned the connection is not valid and must be closed by the caller.
 func OpenLocalPortConn(ctx context.Context, addr string, localPort uint16, cfg ConnConfig) (*Conn, error) {
-	localAddr, err := net.ResolveTCPAddr("tcp", ":"+strconv.Itoa(int(localPort)))
-	if err != nil {
-		return nil, fmt.Errorf("resolve local TCP address: %w", err)
-	}
-
-	return OpenConn(ctx, addr, localAddr, cfg)
+	return openConn(ctx, addr, ShardInfo{}, localPort, cfg)
 }
 
 // OpenConn opens connection with specific local address.
-// In case lAddr is nil, random local address is used.
+// In case lAddr is nil, random local address is used, otherwise only its port is taken into account.
 //
 // If error and connection are returned the connection is not valid and must be closed by the caller.
 func OpenConn(ctx context.Context, addr string, localAddr *net.TCPAddr, cfg ConnConfig) (*Conn, error) {
-	d := net.Dialer{
-		Timeout:   cfg.Timeout,
-		LocalAddr: localAddr,
-	}
-	conn, err := d.DialContext(ctx, "tcp", withPort(addr, cfg.DefaultPort))
-	if err != nil {
-		return nil, fmt.Errorf("dial TCP address %s: %w", addr, err)
+	var localPort uint16
+	if localAddr != nil {
+		localPort = uint16(localAddr.Port)
 	}
+	return openConn(ctx, addr, ShardInfo{}, localPort, cfg)
+}
 
-	tcpConn := conn.(*net.TCPConn)
-	if err := tcpConn.SetNoDelay(cfg.TCPNoDelay); err != nil {
-		return nil, fmt.Errorf("set TCP no delay option: %w", err)
+func openConn(ctx context.Context, addr string, si ShardInfo, localPort uint16, cfg ConnConfig) (*Conn, error) {
+	d := cfg.Dialer
+	if d == nil {
+		d = DefaultDialer{Timeout: cfg.Timeout, TCPNoDelay: cfg.TCPNoDelay}
+	}
+	conn, err := d.DialContext(ctx, withPort(addr, cfg.DefaultPort), si, localPort)
+	if err != nil {
+		return nil, fmt.Errorf("dial address %s: %w", addr, err)
 	}
 
 	if cfg.TLSConfig != nil {
-		tConn, err := WrapTLS(ctx, tcpConn, cfg)
+		tConn, err := WrapTLS(ctx, conn, cfg)
 		if err != nil {
 			return nil, err
 		}
@@ -442,17 +542,17 @@ func OpenConn(ctx context.Context, addr string, localAddr *net.TCPAddr, cfg Conn
 		return WrapConn(ctx, tConn, cfg)
 	}
 
-	return WrapConn(ctx, tcpConn, cfg)
+	return WrapConn(ctx, conn, cfg)
 }
 
-func WrapTLS(ctx context.Context, conn *net.TCPConn, cfg ConnConfig) (net.Conn, error) {
+func WrapTLS(ctx context.Context, conn net.Conn, cfg ConnConfig) (net.Conn, error) {
 	tlsConfig := cfg.TLSConfig.Clone()
 	tconn := tls.Client(conn, tlsConfig)
 	if err := tconn.HandshakeContext(ctx); err != nil {
 		if err := tconn.Close(); err != nil {
-			cfg.Logger.Warnf("%s failed to close: %s", tconn.RemoteAddr(), err)
+			cfg.Logger.Warn("failed to close", log.Node(tconn.RemoteAddr().String()), log.Err(err))
 		} else {
-			cfg.Logger.Infof("%s closed", tconn.RemoteAddr())
+			cfg.Logger.Debug("closed", log.Node(tconn.RemoteAddr().String()))
 		}
 
 		return nil, err
@@ -461,23 +561,22 @@ func WrapTLS(ctx context.Context, conn *net.TCPConn, cfg ConnConfig) (net.Conn,
 	return tconn, nil
 }
 
-// WrapConn transforms tcp connection to a working Scylla connection.
+// WrapConn transforms network connection to a working Scylla connection.
 // If error and connection are returned the connection is not valid and must be closed by the caller.
 func WrapConn(ctx context.Context, conn net.Conn, cfg ConnConfig) (*Conn, error) {
 	s := new(stats)
 	c := new(Conn)
 	*c = Conn{
+		id:   connIDGen.Inc(),
 		cfg:  cfg,
 		conn: conn,
-		event: ConnEvent{
-			Addr:  conn.RemoteAddr().String(),
-			Shard: UnknownShard,
-		},
+		addr: conn.RemoteAddr().String(),
 		w: connWriter{
 			conn:       bufio.NewWriterSize(conn, ioBufferSize),
 			requestCh:  make(chan request, requestChanSize),
 			stats:      s,
 			connString: c.String,
+			connEvent:  c.Event,
 			connClose:  c.Close,
 			log:        cfg.Logger,
 		},
@@ -488,12 +587,26 @@ func WrapConn(ctx context.Context, conn net.Conn, cfg ConnConfig) (*Conn, error)
 			stats:      s,
 			h:          make(map[frame.StreamID]ResponseHandler),
 			connString: c.String,
+			connEvent:  c.Event,
 			connClose:  c.Close,
 			log:        cfg.Logger,
 		},
-		stats: s,
+		stats:   s,
+		closed:  make(chan struct{}),
+		onClose: cfg.onClose,
 	}
+	c.shard.Store(uint32(UnknownShard))
 	c.w.freeStream = c.r.freeStream
+	if cfg.FrameRecorder != nil {
+		c.w.record = c.recordFrame
+		c.r.record = c.recordFrame
+	}
+	if cfg.Metrics != nil {
+		c.w.onFrame = c.onFrame
+		c.r.onFrame = c.onFrame
+		c.r.onResponse = c.onResponse
+		c.r.started = make(map[frame.StreamID]time.Time)
+	}
 
 	if cfg.Compression != "" {
 		if compr, err := newCompr(false, cfg.Compression, cfg.ComprBufferSize); err != nil {
@@ -515,6 +628,10 @@ func WrapConn(ctx context.Context, conn net.Conn, cfg ConnConfig) (*Conn, error)
 		return c, err
 	}
 
+	if cfg.HeartbeatInterval > 0 {
+		go c.heartbeatLoop(ctx)
+	}
+
 	return c, nil
 }
 
@@ -546,12 +663,15 @@ func validateKeyspace(keyspace string) error {
 const cqlVersion = "3.0.0"
 
 func (c *Conn) init(ctx context.Context) error {
-	if s, err := c.Supported(ctx); err != nil {
+	s, err := c.Supported(ctx)
+	if err != nil {
 		return fmt.Errorf("supported: %w", err)
-	} else {
-		c.event.Shard = s.ScyllaSupported().Shard
 	}
+	c.shard.Store(uint32(s.ScyllaSupported().Shard))
 	opts := frame.StartupOptions{"CQL_VERSION": cqlVersion}
+	if _, ok := s.Options[ScyllaTabletsRoutingV1]; ok {
+		opts[ScyllaTabletsRoutingV1] = ""
+	}
 	if c.cfg.Compression != "" {
 		opts["COMPRESSION"] = string(c.cfg.Compression)
 	}
@@ -568,7 +688,7 @@ func (c *Conn) init(ctx context.Context) error {
 }
 
 func (c *Conn) Supported(ctx context.Context) (*Supported, error) {
-	res, err := c.sendRequest(ctx, &Options{}, false, false)
+	res, err := c.sendRequest(ctx, &Options{}, false, false, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -579,7 +699,7 @@ func (c *Conn) Supported(ctx context.Context) (*Supported, error) {
 }
 
 func (c *Conn) Startup(ctx context.Context, options frame.StartupOptions) error {
-	res, err := c.sendRequest(ctx, &Startup{Options: options}, false, false)
+	res, err := c.sendRequest(ctx, &Startup{Options: options}, false, false, nil)
 	if err != nil {
 		return err
 	}
@@ -608,7 +728,7 @@ func (c *Conn) AuthResponse(ctx context.Context, a *Authenticate) error {
 		Username: c.cfg.Username,
 		Password: c.cfg.Password,
 	}
-	res, err := c.sendRequest(ctx, &req, false, false)
+	res, err := c.sendRequest(ctx, &req, false, false, nil)
 	if err != nil {
 		return fmt.Errorf("can't send auth response: %w", err)
 	}
@@ -629,17 +749,17 @@ func (c *Conn) UseKeyspace(ctx context.Context, ks string) error {
 
 func (c *Conn) Query(ctx context.Context, s Statement, pagingState frame.Bytes) (QueryResult, error) {
 	req := makeQuery(s, pagingState)
-	res, err := c.sendRequest(ctx, &req, s.Compression, s.Tracing)
+	res, err := c.roundTrip(ctx, &req, s.Compression, s.Tracing, s.CustomPayload)
 	if err != nil {
 		return QueryResult{}, err
 	}
 
-	return MakeQueryResult(res, s.Metadata)
+	return makeQueryResult(res, s.Metadata)
 }
 
 func (c *Conn) Prepare(ctx context.Context, s Statement) (Statement, error) {
 	req := Prepare{Query: s.Content}
-	res, err := c.sendRequest(ctx, &req, false, false)
+	res, err := c.sendRequest(ctx, &req, false, false, nil)
 	if err != nil {
 		return Statement{}, err
 	}
@@ -650,6 +770,11 @@ func (c *Conn) Prepare(ctx context.Context, s Statement) (Statement, error) {
 		s.PkIndexes = v.Metadata.PkIndexes
 		s.PkCnt = v.Metadata.PkCnt
 		s.Metadata = &v.ResultMetadata
+		s.BindMetadata = &v.Metadata
+		s.Keyspace, s.Table = v.Metadata.GlobalKeyspace, v.Metadata.GlobalTable
+		if s.Keyspace == "" && len(v.Metadata.Columns) > 0 {
+			s.Keyspace, s.Table = v.Metadata.Columns[0].Keyspace, v.Metadata.Columns[0].Table
+		}
 		return s, nil
 	}
 
@@ -658,18 +783,18 @@ func (c *Conn) Prepare(ctx context.Context, s Statement) (Statement, error) {
 
 func (c *Conn) Execute(ctx context.Context, s Statement, pagingState frame.Bytes) (QueryResult, error) {
 	req := makeExecute(s, pagingState)
-	res, err := c.sendRequest(ctx, &req, s.Compression, s.Tracing)
+	res, err := c.roundTrip(ctx, &req, s.Compression, s.Tracing, s.CustomPayload)
 	if err != nil {
 		return QueryResult{}, err
 	}
 
-	return MakeQueryResult(res, s.Metadata)
+	return makeQueryResult(res, s.Metadata)
 }
 
 func (c *Conn) RegisterEventHandler(ctx context.Context, h func(context.Context, response), e ...frame.EventType) error {
 	c.r.handleEvent = h
 	req := Register{EventTypes: e}
-	res, err := c.sendRequest(ctx, &req, false, false)
+	res, err := c.sendRequest(ctx, &req, false, false, nil)
 	if err != nil {
 		return err
 	}
@@ -692,15 +817,21 @@ func MakeResponseHandlerWithError(err error) ResponseHandler {
 	return h
 }
 
-func (c *Conn) sendRequest(ctx context.Context, req frame.Request, compress, tracing bool) (frame.Response, error) {
+func (c *Conn) sendRequest(ctx context.Context, req frame.Request, compress, tracing bool, payload frame.BytesMap) (frame.Response, error) {
+	res, err := c.roundTrip(ctx, req, compress, tracing, payload)
+	return res.Response, err
+}
+
+// roundTrip is like sendRequest but it returns response optional fields as well.
+func (c *Conn) roundTrip(ctx context.Context, req frame.Request, compress, tracing bool, payload frame.BytesMap) (response, error) {
 	if err := c.sendController(ctx); err != nil {
-		return nil, fmt.Errorf("request skipped, %w", err)
+		return response{}, fmt.Errorf("request skipped, %w", err)
 	}
 	h := MakeResponseHandler()
 
 	streamID, err := c.r.setHandler(h)
 	if err != nil {
-		return nil, fmt.Errorf("set handler: %w", err)
+		return response{}, fmt.Errorf("set handler: %w", err)
 	}
 
 	r := request{
@@ -708,6 +839,7 @@ func (c *Conn) sendRequest(ctx context.Context, req frame.Request, compress, tra
 		StreamID:        streamID,
 		Compress:        compress,
 		Tracing:         tracing,
+		CustomPayload:   payload,
 		ResponseHandler: h,
 		ctx:             ctx,
 	}
@@ -719,13 +851,16 @@ func (c *Conn) sendRequest(ctx context.Context, req frame.Request, compress, tra
 
 	select {
 	case resp := <-h:
-		return resp.Response, resp.Err
+		return resp, resp.Err
 	case <-ctx.Done():
-		return nil, fmt.Errorf("no response, %w", ctx.Err())
+		if c.cfg.Metrics != nil {
+			c.cfg.Metrics.OnTimeout(c.Event())
+		}
+		return response{}, fmt.Errorf("no response, %w", ctx.Err())
 	}
 }
 
-func (c *Conn) asyncSendRequest(ctx context.Context, req frame.Request, compress, tracing bool, h ResponseHandler) {
+func (c *Conn) asyncSendRequest(ctx context.Context, req frame.Request, compress, tracing bool, payload frame.BytesMap, h ResponseHandler) {
 control:
 	if err := c.sendController(ctx); err != nil {
 		h <- response{Err: fmt.Errorf("no response, %v", err)}
@@ -747,6 +882,7 @@ control:
 		StreamID:        streamID,
 		Compress:        compress,
 		Tracing:         tracing,
+		CustomPayload:   payload,
 		ResponseHandler: h,
 		ctx:             ctx,
 	}
@@ -777,39 +913,95 @@ func (c *Conn) sendController(ctx context.Context) error {
 	}
 }
 
+// heartbeatLoop sends heartbeats when connection is idle and closes it if heartbeat fails.
+func (c *Conn) heartbeatLoop(ctx context.Context) {
+	ticker := time.NewTicker(c.cfg.HeartbeatInterval)
+	defer ticker.Stop()
+
+	last := c.stats.received.Load()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-c.closed:
+			return
+		case <-ticker.C:
+		}
+
+		if v := c.stats.received.Load(); v != last {
+			last = v
+			continue
+		}
+		if err := c.heartbeat(ctx); err != nil {
+			c.cfg.Logger.Info("heartbeat failed, closing connection", c.Event().logAttrs(log.Err(err))...)
+			c.Close()
+			return
+		}
+		last = c.stats.received.Load()
+	}
+}
+
+func (c *Conn) heartbeat(ctx context.Context) error {
+	timeout := c.cfg.HeartbeatTimeout
+	if timeout <= 0 {
+		timeout = c.cfg.HeartbeatInterval
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	span := startSpan()
+	res, err := c.sendRequest(ctx, &Options{}, false, false, nil)
+	span.stop()
+	if err == nil {
+		if _, ok := res.(*Supported); !ok {
+			err = responseAsError(res)
+		}
+	}
+	if o, ok := c.cfg.ConnObserver.(HeartbeatObserver); ok {
+		o.OnHeartbeat(HeartbeatEvent{ConnEvent: c.Event(), span: span, Err: err})
+	}
+	return err
+}
+
 func (c *Conn) AsyncQuery(ctx context.Context, s Statement, pagingState frame.Bytes, h ResponseHandler) {
 	req := makeQuery(s, pagingState)
-	c.asyncSendRequest(ctx, &req, s.Compression, s.Tracing, h)
+	c.asyncSendRequest(ctx, &req, s.Compression, s.Tracing, s.CustomPayload, h)
 }
 
 func (c *Conn) AsyncExecute(ctx context.Context, s Statement, pagingState frame.Bytes, h ResponseHandler) {
 	req := makeExecute(s, pagingState)
-	c.asyncSendRequest(ctx, &req, s.Compression, s.Tracing, h)
+	c.asyncSendRequest(ctx, &req, s.Compression, s.Tracing, s.CustomPayload, h)
 }
 
 func (c *Conn) Waiting() int {
 	return int(c.stats.inQueue.Load() + c.stats.inFlight.Load())
 }
 
-func (c *Conn) setOnClose(f func(conn *Conn)) {
-	c.onClose = f
-}
-
 func (c *Conn) Event() ConnEvent {
-	return c.event
+	return ConnEvent{Addr: c.addr, Shard: uint16(c.shard.Load())}
 }
 
 func (c *Conn) Shard() int {
-	return int(c.event.Shard)
+	return int(c.shard.Load())
+}
+
+func (c *Conn) isClosed() bool {
+	select {
+	case <-c.closed:
+		return true
+	default:
+		return false
+	}
 }
 
 // Close closes connection and terminates reader and writer go routines.
 func (c *Conn) Close() {
 	c.closeOnce.Do(func() {
+		close(c.closed)
 		if err := c.conn.Close(); err != nil {
-			c.cfg.Logger.Warnf("%s failed to close: %s", c, err)
+			c.cfg.Logger.Warn("failed to close", c.Event().logAttrs(log.Err(err))...)
 		} else {
-			c.cfg.Logger.Infof("%s closed", c)
+			c.cfg.Logger.Debug("closed", c.Event().logAttrs()...)
 		}
 		c.w.requestCh <- _connCloseRequest
 		if c.onClose != nil {
@@ -819,7 +1011,7 @@ func (c *Conn) Close() {
 }
 
 func (c *Conn) String() string {
-	return fmt.Sprintf("[addr=%s shard=%d]", c.conn.RemoteAddr(), c.event.Shard)
+	return fmt.Sprintf("[addr=%s shard=%d]", c.conn.RemoteAddr(), c.Shard())
 }
 
 func (c *Conn) RemoteAddr() net.Addr {
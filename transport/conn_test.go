@@ -1,7 +1,12 @@
 package transport
 
 import (
+	"context"
+	"errors"
+	"net"
 	"testing"
+
+	"github.com/scylladb/scylla-go-driver/log"
 )
 
 func TestPortParsing(t *testing.T) {
@@ -49,3 +54,41 @@ func TestPortParsing(t *testing.T) {
 		})
 	}
 }
+
+type recordingDialer struct {
+	addrs []string
+	ports []uint16
+	err   error
+}
+
+func (d *recordingDialer) DialContext(_ context.Context, addr string, _ ShardInfo, localPort uint16) (net.Conn, error) {
+	d.addrs = append(d.addrs, addr)
+	d.ports = append(d.ports, localPort)
+	return nil, d.err
+}
+
+func TestOpenShardConnDialer(t *testing.T) {
+	t.Parallel()
+
+	d := &recordingDialer{err: errors.New("dial error")}
+	cfg := DefaultConnConfig("")
+	cfg.Logger = log.NopLogger{}
+	cfg.Dialer = d
+
+	si := ShardInfo{Shard: 3, NrShards: 1024}
+	if _, err := OpenShardConn(context.Background(), "192.168.100.1", si, cfg); err == nil {
+		t.Fatal("expected error")
+	}
+
+	if len(d.ports) == 0 {
+		t.Fatal("dialer was not used")
+	}
+	for i := range d.ports {
+		if d.addrs[i] != "192.168.100.1:9042" {
+			t.Fatalf("got address %s, expected default port to be appended", d.addrs[i])
+		}
+		if int(d.ports[i])%int(si.NrShards) != int(si.Shard) {
+			t.Fatalf("port %d doesn't correspond to shard %d", d.ports[i], si.Shard)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package transport
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+)
+
+// Dialer opens network connections to nodes, it allows to route traffic through proxies,
+// to use other transports such as Unix domain sockets, or in-memory connections in tests.
+type Dialer interface {
+	// DialContext connects to addr (host:port). si describes the shard the connection is meant for,
+	// si.NrShards is 0 if connection is not opened to a specific shard.
+	// localPort is the desired local port, 0 if any port can be used.
+	//
+	// Shard aware port maps connections to shards based on their local port,
+	// dialers that can't control it make the pool fall back to non shard aware connections.
+	DialContext(ctx context.Context, addr string, si ShardInfo, localPort uint16) (net.Conn, error)
+}
+
+// DefaultDialer opens TCP connections using net.Dialer.
+type DefaultDialer struct {
+	Timeout    time.Duration
+	TCPNoDelay bool
+}
+
+var _ Dialer = DefaultDialer{}
+
+func (d DefaultDialer) DialContext(ctx context.Context, addr string, _ ShardInfo, localPort uint16) (net.Conn, error) {
+	nd := net.Dialer{
+		Timeout: d.Timeout,
+	}
+	if localPort != 0 {
+		localAddr, err := net.ResolveTCPAddr("tcp", ":"+strconv.Itoa(int(localPort)))
+		if err != nil {
+			return nil, fmt.Errorf("resolve local TCP address: %w", err)
+		}
+		nd.LocalAddr = localAddr
+	}
+
+	conn, err := nd.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	if tcpConn, ok := conn.(*net.TCPConn); ok {
+		if err := tcpConn.SetNoDelay(d.TCPNoDelay); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("set TCP no delay option: %w", err)
+		}
+	}
+	return conn, nil
+}
@@ -0,0 +1,61 @@
+package transport
+
+// HostFilter decides if node is used to execute queries. Nodes rejected by the filter are tracked
+// in topology, but they don't get a connection pool, they are not part of query plans and they
+// are not used for control connection.
+// Filter is called with info of nodes found during topology refresh, it must be safe for concurrent use.
+type HostFilter func(NodeInfo) bool
+
+// AllowDCs returns filter accepting only nodes from the given datacenters.
+func AllowDCs(dcs ...string) HostFilter {
+	m := stringSet(dcs)
+	return func(n NodeInfo) bool {
+		_, ok := m[n.Datacenter]
+		return ok
+	}
+}
+
+// DenyDCs returns filter rejecting nodes from the given datacenters.
+func DenyDCs(dcs ...string) HostFilter {
+	m := stringSet(dcs)
+	return func(n NodeInfo) bool {
+		_, ok := m[n.Datacenter]
+		return !ok
+	}
+}
+
+// AllowHosts returns filter accepting only nodes with the given addresses.
+func AllowHosts(addrs ...string) HostFilter {
+	m := stringSet(addrs)
+	return func(n NodeInfo) bool {
+		_, ok := m[n.Addr]
+		return ok
+	}
+}
+
+// DenyHosts returns filter rejecting nodes with the given addresses.
+func DenyHosts(addrs ...string) HostFilter {
+	m := stringSet(addrs)
+	return func(n NodeInfo) bool {
+		_, ok := m[n.Addr]
+		return !ok
+	}
+}
+
+func stringSet(v []string) map[string]struct{} {
+	m := make(map[string]struct{}, len(v))
+	for _, s := range v {
+		m[s] = struct{}{}
+	}
+	return m
+}
+
+// hostFilter wraps HostFilter so that it can be stored in atomic.Value.
+type hostFilter struct {
+	f HostFilter
+}
+
+// accepts reports if node n is accepted by filter f, nil filter accepts all nodes.
+func (f HostFilter) accepts(n *Node) bool {
+	return f == nil || f(n.Info())
+}
@@ -0,0 +1,128 @@
+package transport_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/scylladb/scylla-go-driver/frame"
+	"github.com/scylladb/scylla-go-driver/scyllatest"
+	"github.com/scylladb/scylla-go-driver/transport"
+)
+
+type heartbeatRecorder struct {
+	// onEvent is called synchronously before the connection reacts to the heartbeat result.
+	onEvent func(ev transport.HeartbeatEvent)
+
+	mu     sync.Mutex
+	events []transport.HeartbeatEvent
+}
+
+var (
+	_ transport.ConnObserver      = (*heartbeatRecorder)(nil)
+	_ transport.HeartbeatObserver = (*heartbeatRecorder)(nil)
+)
+
+func (r *heartbeatRecorder) OnConnect(transport.ConnectEvent)                      {}
+func (r *heartbeatRecorder) OnPickReplacedWithLessBusyConn(ev transport.ConnEvent) {}
+
+func (r *heartbeatRecorder) OnHeartbeat(ev transport.HeartbeatEvent) {
+	if r.onEvent != nil {
+		r.onEvent(ev)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, ev)
+}
+
+// wait waits for a heartbeat matching f.
+func (r *heartbeatRecorder) wait(t *testing.T, f func(transport.HeartbeatEvent) bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		r.mu.Lock()
+		for _, ev := range r.events {
+			if f(ev) {
+				r.mu.Unlock()
+				return
+			}
+		}
+		r.mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("heartbeat not observed")
+}
+
+func heartbeatConnConfig(srv *scyllatest.Server, obs transport.ConnObserver) transport.ConnConfig {
+	cfg := srv.ConnConfig()
+	cfg.HeartbeatInterval = 20 * time.Millisecond
+	cfg.HeartbeatTimeout = 50 * time.Millisecond
+	cfg.ConnObserver = obs
+	return cfg
+}
+
+func TestHeartbeatIdleConn(t *testing.T) {
+	t.Parallel()
+	srv := scyllatest.NewServer(scyllatest.DefaultConfig(1))
+	defer srv.Close()
+
+	obs := &heartbeatRecorder{}
+	conn, err := transport.OpenConn(context.Background(), "127.0.0.1", nil, heartbeatConnConfig(srv, obs))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	obs.wait(t, func(ev transport.HeartbeatEvent) bool { return ev.Err == nil })
+}
+
+func TestHeartbeatBlackholedConn(t *testing.T) {
+	t.Parallel()
+	scfg := scyllatest.DefaultConfig(1)
+	srv := scyllatest.NewServer(scfg)
+	defer srv.Close()
+
+	fi := scyllatest.NewFaultInjector(srv, 0)
+	// Rules are removed before the blackholed connection is closed, so that handshake of the new one isn't blackholed.
+	obs := &heartbeatRecorder{onEvent: func(ev transport.HeartbeatEvent) {
+		if ev.Err != nil {
+			fi.Reset()
+		}
+	}}
+	cfg := heartbeatConnConfig(srv, obs)
+	cfg.Dialer = fi
+	cfg.ReconnectionPolicy = transport.NewExponentialReconnectionPolicy(10*time.Millisecond, 10*time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	pool, err := transport.NewConnPool(ctx, scfg.Nodes[0].Addr, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+
+	shardConn := func() *transport.Conn {
+		deadline := time.Now().Add(5 * time.Second)
+		for time.Now().Before(deadline) {
+			if conn, err := pool.ShardConn(0); err == nil && conn.Shard() == 0 {
+				return conn
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		t.Fatal("no connection to shard 0")
+		return nil
+	}
+	old := shardConn()
+
+	fi.Add(scyllatest.Rule{Shards: []int{0}, OpCodes: []frame.OpCode{frame.OpOptions}, Blackhole: true})
+	obs.wait(t, func(ev transport.HeartbeatEvent) bool { return ev.Err != nil && ev.Shard == 0 })
+
+	deadline := time.Now().Add(5 * time.Second)
+	for shardConn() == old {
+		if time.Now().After(deadline) {
+			t.Fatal("blackholed connection was not replaced")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
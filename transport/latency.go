@@ -0,0 +1,195 @@
+package transport
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sync"
+	"time"
+
+	. "github.com/scylladb/scylla-go-driver/frame/response"
+)
+
+// LatencyObserver is implemented by host selection policies that route queries based on latency,
+// session reports latency of every attempt to execute a statement on a node.
+type LatencyObserver interface {
+	ObserveLatency(n *Node, latency time.Duration, err error)
+}
+
+const (
+	// latencyAlpha is the weight of a new sample in the average latency of a node.
+	latencyAlpha = 0.1
+	// latencyMinMeasurements is the number of samples needed for node average latency to be taken into account.
+	latencyMinMeasurements = 50
+)
+
+// LatencyAwarePolicy wraps a host selection policy and de-prioritises nodes with high latency.
+// It keeps exponentially-weighted moving average of latency of every node, nodes with average exceeding
+// exclusionThreshold times the best average of nodes from the same datacenter are moved to the end
+// of the datacenter nodes in the plan of the wrapped policy. Order of the remaining nodes is preserved,
+// so replicas and local datacenter nodes keep their precedence.
+//
+// Averages older than retryPeriod are not taken into account and they are reset by the next measurement,
+// that way penalised nodes are re-probed periodically and they are back in the plan if their latency improved.
+// The plan is built once per query, when it's first iterated.
+type LatencyAwarePolicy struct {
+	child              HostSelectionPolicy
+	exclusionThreshold float64
+	retryPeriod        time.Duration
+
+	alpha           float64
+	minMeasurements int
+	stats           sync.Map // addr -> *nodeLatency
+}
+
+var _ LatencyObserver = (*LatencyAwarePolicy)(nil)
+
+// NewLatencyAwarePolicy returns policy wrapping child, exclusionThreshold should be greater than 1.
+func NewLatencyAwarePolicy(child HostSelectionPolicy, exclusionThreshold float64, retryPeriod time.Duration) *LatencyAwarePolicy {
+	return &LatencyAwarePolicy{
+		child:              child,
+		exclusionThreshold: exclusionThreshold,
+		retryPeriod:        retryPeriod,
+		alpha:              latencyAlpha,
+		minMeasurements:    latencyMinMeasurements,
+	}
+}
+
+// Unwrap returns the wrapped policy.
+func (p *LatencyAwarePolicy) Unwrap() HostSelectionPolicy {
+	return p.child
+}
+
+type nodeLatency struct {
+	mu      sync.Mutex
+	avg     float64
+	n       int
+	updated time.Time
+}
+
+// ObserveLatency updates node average latency, failed attempts are taken into account
+// only if the node responded with an error or the attempt timed out.
+func (p *LatencyAwarePolicy) ObserveLatency(n *Node, latency time.Duration, err error) {
+	p.observe(n, latency, err, Now())
+}
+
+func (p *LatencyAwarePolicy) observe(n *Node, latency time.Duration, err error, now time.Time) {
+	if err != nil {
+		var coded CodedError
+		if !errors.As(err, &coded) && !errors.Is(err, context.DeadlineExceeded) {
+			return
+		}
+	}
+
+	v, ok := p.stats.Load(n.addr)
+	if !ok {
+		v, _ = p.stats.LoadOrStore(n.addr, &nodeLatency{})
+	}
+	s := v.(*nodeLatency)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// Average older than retryPeriod is discarded, so that re-probed node isn't judged by its old latency.
+	if s.n == 0 || now.Sub(s.updated) > p.retryPeriod {
+		s.avg = float64(latency)
+		s.n = 0
+	} else {
+		s.avg = p.alpha*float64(latency) + (1-p.alpha)*s.avg
+	}
+	s.n++
+	s.updated = now
+}
+
+// average returns node average latency if it's based on enough measurements and is up to date.
+func (p *LatencyAwarePolicy) average(n *Node, now time.Time) (float64, bool) {
+	v, ok := p.stats.Load(n.addr)
+	if !ok {
+		return 0, false
+	}
+	s := v.(*nodeLatency)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.n < p.minMeasurements || now.Sub(s.updated) > p.retryPeriod {
+		return 0, false
+	}
+	return s.avg, true
+}
+
+// Averages returns average latencies of nodes by address, it's useful for debugging.
+func (p *LatencyAwarePolicy) Averages() map[string]time.Duration {
+	m := make(map[string]time.Duration)
+	p.stats.Range(func(k, v any) bool {
+		s := v.(*nodeLatency)
+		s.mu.Lock()
+		m[k.(string)] = time.Duration(s.avg)
+		s.mu.Unlock()
+		return true
+	})
+	return m
+}
+
+func (p *LatencyAwarePolicy) Node(qi QueryInfo, offset int) *Node {
+	return p.node(qi, offset, Now())
+}
+
+func (p *LatencyAwarePolicy) node(qi QueryInfo, offset int, now time.Time) *Node {
+	nodes := qi.plan.latencyAware(func() []*Node {
+		return p.plan(qi, now)
+	})
+	if offset >= len(nodes) {
+		return nil
+	}
+	return nodes[offset]
+}
+
+// plan returns the child plan with penalised nodes moved to the end of consecutive nodes from the same datacenter.
+func (p *LatencyAwarePolicy) plan(qi QueryInfo, now time.Time) []*Node {
+	var nodes []*Node
+	for i := 0; ; i++ {
+		n := p.child.Node(qi, i)
+		if n == nil {
+			break
+		}
+		nodes = append(nodes, n)
+	}
+
+	// Nodes without enough measurements are never penalised.
+	avg := make([]float64, len(nodes))
+	for i, n := range nodes {
+		if v, ok := p.average(n, now); ok {
+			avg[i] = v
+		} else {
+			avg[i] = math.NaN()
+		}
+	}
+
+	res := make([]*Node, 0, len(nodes))
+	penalised := make([]bool, len(nodes))
+	for start := 0; start < len(nodes); {
+		end := start + 1
+		for end < len(nodes) && nodes[end].datacenter == nodes[start].datacenter {
+			end++
+		}
+
+		best := math.Inf(1)
+		for i := start; i < end; i++ {
+			if avg[i] < best {
+				best = avg[i]
+			}
+		}
+		limit := best * p.exclusionThreshold
+		for i := start; i < end; i++ {
+			penalised[i] = avg[i] > limit
+		}
+
+		// Return nodes that are not penalised first, then the penalised ones.
+		for _, v := range [2]bool{false, true} {
+			for i := start; i < end; i++ {
+				if penalised[i] == v {
+					res = append(res, nodes[i])
+				}
+			}
+		}
+		start = end
+	}
+	return res
+}
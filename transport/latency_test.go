@@ -0,0 +1,182 @@
+package transport
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestLatencyAwarePolicy(t *testing.T) {
+	t.Parallel()
+	c := mockCluster(mockTopologyRoundRobin(), "", "eu")
+
+	testCases := []struct {
+		name     string
+		latency  map[string]time.Duration
+		age      time.Duration
+		expected []string
+	}{
+		{
+			name:     "no measurements",
+			expected: []string{"1", "2", "3", "4", "5"},
+		},
+		{
+			name:     "similar latency",
+			latency:  map[string]time.Duration{"1": 15 * time.Millisecond, "2": 10 * time.Millisecond, "3": 12 * time.Millisecond},
+			expected: []string{"1", "2", "3", "4", "5"},
+		},
+		{
+			name:     "slow local node",
+			latency:  map[string]time.Duration{"1": 100 * time.Millisecond, "2": 10 * time.Millisecond, "3": 12 * time.Millisecond},
+			expected: []string{"2", "3", "1", "4", "5"},
+		},
+		{
+			name:     "slow remote node stays after local nodes",
+			latency:  map[string]time.Duration{"1": 10 * time.Millisecond, "4": 200 * time.Millisecond, "5": 50 * time.Millisecond},
+			expected: []string{"1", "2", "3", "5", "4"},
+		},
+		{
+			name:     "slow node is re-probed after retry period",
+			latency:  map[string]time.Duration{"1": 100 * time.Millisecond, "2": 10 * time.Millisecond},
+			age:      2 * time.Minute,
+			expected: []string{"1", "2", "3", "4", "5"},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			p := NewLatencyAwarePolicy(NewTokenAwarePolicy("eu"), 2, time.Minute)
+			for _, n := range c.Topology().Nodes {
+				if d, ok := tc.latency[n.addr]; ok {
+					for i := 0; i < latencyMinMeasurements; i++ {
+						p.ObserveLatency(n, d, nil)
+					}
+				}
+			}
+			now := Now().Add(tc.age)
+
+			qi := c.NewQueryInfo()
+			qi.offset = 0
+			var res []string
+			for i := 0; ; i++ {
+				n := p.node(qi, i, now)
+				if n == nil {
+					break
+				}
+				res = append(res, n.addr)
+			}
+			if len(res) != len(tc.expected) {
+				t.Fatalf("expected %v, got %v", tc.expected, res)
+			}
+			for i := range res {
+				if res[i] != tc.expected[i] {
+					t.Fatalf("expected %v, got %v", tc.expected, res)
+				}
+			}
+		})
+	}
+}
+
+func TestLatencyAwarePolicyObserveLatency(t *testing.T) {
+	t.Parallel()
+	p := NewLatencyAwarePolicy(NewTokenAwarePolicy(""), 2, time.Minute)
+	n := &Node{addr: "1"}
+
+	p.ObserveLatency(n, 100*time.Millisecond, nil)
+	p.ObserveLatency(n, time.Millisecond, errors.New("connection closed"))
+	p.ObserveLatency(n, 200*time.Millisecond, fmt.Errorf("no response, %w", context.DeadlineExceeded))
+	if v := p.Averages()["1"]; v != 110*time.Millisecond {
+		t.Fatalf("expected connection error to be ignored, got %v", v)
+	}
+}
+
+func TestLatencyAwarePolicyAveragesChange(t *testing.T) {
+	t.Parallel()
+	c := mockCluster(mockTopologyRoundRobin(), "", "eu")
+	p := NewLatencyAwarePolicy(NewTokenAwarePolicy("eu"), 2, time.Minute)
+	nodes := make(map[string]*Node)
+	for _, n := range c.Topology().Nodes {
+		nodes[n.addr] = n
+	}
+	observe := func(addr string, d time.Duration) {
+		for i := 0; i < 2*latencyMinMeasurements; i++ {
+			p.ObserveLatency(nodes[addr], d, nil)
+		}
+	}
+	observe("1", 100*time.Millisecond)
+	observe("2", 10*time.Millisecond)
+	observe("3", 10*time.Millisecond)
+
+	qi := c.NewQueryInfo()
+	qi.offset = 0
+	var res []string
+	for i := 0; ; i++ {
+		n := p.Node(qi, i)
+		if n == nil {
+			break
+		}
+		res = append(res, n.addr)
+		// Node "1" becomes the fastest one and node "2" the slowest one while the plan is iterated.
+		observe("1", time.Millisecond)
+		observe("2", time.Second)
+	}
+
+	expected := []string{"2", "3", "1", "4", "5"}
+	if fmt.Sprint(res) != fmt.Sprint(expected) {
+		t.Fatalf("expected %v, got %v", expected, res)
+	}
+}
+
+func TestLatencyAwarePolicyRecovery(t *testing.T) {
+	t.Parallel()
+	c := mockCluster(mockTopologyRoundRobin(), "", "eu")
+	p := NewLatencyAwarePolicy(NewTokenAwarePolicy("eu"), 2, time.Minute)
+	nodes := make(map[string]*Node)
+	for _, n := range c.Topology().Nodes {
+		nodes[n.addr] = n
+	}
+	plan := func(now time.Time) string {
+		qi := c.NewQueryInfo()
+		qi.offset = 0
+		var res []string
+		for i := 0; ; i++ {
+			n := p.node(qi, i, now)
+			if n == nil {
+				break
+			}
+			res = append(res, n.addr)
+		}
+		return fmt.Sprint(res)
+	}
+
+	start := Now()
+	for i := 0; i < latencyMinMeasurements; i++ {
+		p.observe(nodes["1"], 100*time.Millisecond, nil, start)
+		p.observe(nodes["2"], 10*time.Millisecond, nil, start)
+		p.observe(nodes["3"], 10*time.Millisecond, nil, start)
+	}
+	if res, expected := plan(start), fmt.Sprint([]string{"2", "3", "1", "4", "5"}); res != expected {
+		t.Fatalf("expected %v, got %v", expected, res)
+	}
+
+	// Node is re-probed after retry period, its old average must not exclude it again.
+	now := start.Add(2 * time.Minute)
+	p.observe(nodes["1"], 10*time.Millisecond, nil, now)
+	for i := 0; i < latencyMinMeasurements; i++ {
+		p.observe(nodes["2"], 10*time.Millisecond, nil, now)
+		p.observe(nodes["3"], 10*time.Millisecond, nil, now)
+	}
+	if res, expected := plan(now), fmt.Sprint([]string{"1", "2", "3", "4", "5"}); res != expected {
+		t.Fatalf("expected %v, got %v", expected, res)
+	}
+	for i := 1; i < latencyMinMeasurements; i++ {
+		p.observe(nodes["1"], 10*time.Millisecond, nil, now)
+	}
+	if res, expected := plan(now), fmt.Sprint([]string{"1", "2", "3", "4", "5"}); res != expected {
+		t.Fatalf("expected %v, got %v", expected, res)
+	}
+}
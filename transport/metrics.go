@@ -0,0 +1,368 @@
+package transport
+
+import (
+	"errors"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/scylladb/scylla-go-driver/frame"
+	. "github.com/scylladb/scylla-go-driver/frame/response"
+	"go.uber.org/atomic"
+)
+
+// Metrics collects driver metrics. Methods are called on hot paths from many goroutines,
+// implementations must be safe for concurrent use and must not block.
+type Metrics interface {
+	// OnRequest is called when response to a request is received.
+	OnRequest(ev RequestEvent)
+	// OnTimeout is called when request is abandoned because its context is done.
+	OnTimeout(ev ConnEvent)
+	// OnRetry is called when a failed request is retried.
+	OnRetry(ev RetryEvent)
+	// OnFrame is called for every frame sent or received.
+	OnFrame(ev FrameEvent)
+	// OnPoolSize is called when number of connections in a pool changes.
+	OnPoolSize(ev PoolSizeEvent)
+	// OnTopologyRefresh is called after every topology refresh.
+	OnTopologyRefresh(ev TopologyRefreshEvent)
+}
+
+// RequestEvent describes request that got a response, its duration is the request latency.
+type RequestEvent struct {
+	ConnEvent
+	span
+
+	// Err is the error returned by the server, or transport error (if any).
+	Err error
+	// StreamsInUse is the number of requests in flight on the connection.
+	StreamsInUse int
+}
+
+type RetryEvent struct {
+	ConnEvent
+
+	// Err is the error of the failed attempt.
+	Err      error
+	Decision RetryDecision
+}
+
+type FrameEvent struct {
+	ConnEvent
+
+	Direction FrameDirection
+	// Size is the number of bytes sent or received, including header.
+	Size int
+	// UncompressedSize equals Size if frame is not compressed.
+	UncompressedSize int
+}
+
+type PoolSizeEvent struct {
+	// Addr is the node address.
+	Addr string
+	// Size is the number of open connections.
+	Size int
+	// Capacity is the number of connections the pool tries to keep open.
+	Capacity int
+}
+
+type TopologyRefreshEvent struct {
+	span
+
+	Err error
+}
+
+// DefaultLatencyBuckets are upper bounds of latency histogram buckets used by MemoryMetrics.
+var DefaultLatencyBuckets = []time.Duration{
+	500 * time.Microsecond,
+	time.Millisecond,
+	2500 * time.Microsecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2500 * time.Millisecond,
+	5 * time.Second,
+	10 * time.Second,
+}
+
+// Histogram counts observed durations in buckets.
+type Histogram struct {
+	// Buckets are upper bounds of buckets.
+	Buckets []time.Duration
+	// Counts[i] is the number of observations not greater than Buckets[i]
+	// and greater than Buckets[i-1], Counts has one more element for observations above all bounds.
+	Counts []uint64
+	Count  uint64
+	Sum    time.Duration
+}
+
+func newHistogram(buckets []time.Duration) Histogram {
+	return Histogram{
+		Buckets: buckets,
+		Counts:  make([]uint64, len(buckets)+1),
+	}
+}
+
+func (h *Histogram) observe(d time.Duration) {
+	i := sort.Search(len(h.Buckets), func(i int) bool { return d <= h.Buckets[i] })
+	h.Counts[i]++
+	h.Count++
+	h.Sum += d
+}
+
+func (h Histogram) clone() Histogram {
+	h.Counts = append([]uint64(nil), h.Counts...)
+	return h
+}
+
+// NodeShard identifies shard of a node, Shard is UnknownShard for nodes without sharding information.
+type NodeShard struct {
+	Addr  string
+	Shard uint16
+}
+
+// ShardMetrics are metrics of a single shard.
+type ShardMetrics struct {
+	Latency  Histogram
+	Requests uint64
+	Errors   uint64
+	Timeouts uint64
+	// StreamsInUse is the number of requests in flight observed with the last response.
+	StreamsInUse int
+	// MaxStreamsInUse is the maximal number of requests in flight observed.
+	MaxStreamsInUse int
+}
+
+// PoolMetrics describe connection pool of a node.
+type PoolMetrics struct {
+	Size     int
+	Capacity int
+}
+
+// ErrCodeOther is used in MetricsSnapshot.Errors for errors that are not returned by the server.
+const ErrCodeOther = frame.ErrorCode(-1)
+
+// MetricsSnapshot is a copy of metrics collected by MemoryMetrics.
+type MetricsSnapshot struct {
+	Shards map[NodeShard]ShardMetrics
+	Pools  map[string]PoolMetrics
+	// Errors counts errors by error code.
+	Errors   map[frame.ErrorCode]uint64
+	Timeouts uint64
+	Retries  uint64
+
+	BytesSent                 uint64
+	BytesReceived             uint64
+	UncompressedBytesSent     uint64
+	UncompressedBytesReceived uint64
+
+	TopologyRefreshes      uint64
+	TopologyRefreshErrors  uint64
+	TopologyRefreshLatency Histogram
+}
+
+// CompressionRatio returns the ratio of uncompressed to actual size of all frames, 1 if nothing was sent.
+func (s MetricsSnapshot) CompressionRatio() float64 {
+	n := s.BytesSent + s.BytesReceived
+	if n == 0 {
+		return 1
+	}
+	return float64(s.UncompressedBytesSent+s.UncompressedBytesReceived) / float64(n)
+}
+
+// MemoryMetrics is a Metrics implementation keeping metrics in memory, use Snapshot to read them.
+// Counters updated for every frame are atomic, metrics of a shard are guarded by a per shard lock.
+type MemoryMetrics struct {
+	buckets []time.Duration
+
+	shards sync.Map // NodeShard -> *shardMetrics
+	errors sync.Map // frame.ErrorCode -> *atomic.Uint64
+
+	timeouts                  atomic.Uint64
+	retries                   atomic.Uint64
+	bytesSent                 atomic.Uint64
+	bytesReceived             atomic.Uint64
+	uncompressedBytesSent     atomic.Uint64
+	uncompressedBytesReceived atomic.Uint64
+
+	// mu guards rarely updated metrics.
+	mu                     sync.Mutex
+	pools                  map[string]PoolMetrics
+	topologyRefreshes      uint64
+	topologyRefreshErrors  uint64
+	topologyRefreshLatency Histogram
+}
+
+type shardMetrics struct {
+	mu sync.Mutex
+	v  ShardMetrics
+}
+
+var _ Metrics = (*MemoryMetrics)(nil)
+
+// NewMemoryMetrics returns MemoryMetrics with latency histograms using given buckets,
+// DefaultLatencyBuckets are used if buckets is nil.
+func NewMemoryMetrics(buckets []time.Duration) *MemoryMetrics {
+	if buckets == nil {
+		buckets = DefaultLatencyBuckets
+	}
+	return &MemoryMetrics{
+		buckets:                buckets,
+		pools:                  make(map[string]PoolMetrics),
+		topologyRefreshLatency: newHistogram(buckets),
+	}
+}
+
+func nodeShard(ev ConnEvent) NodeShard {
+	addr := ev.Addr
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		addr = host
+	}
+	return NodeShard{Addr: addr, Shard: ev.Shard}
+}
+
+func (m *MemoryMetrics) shard(ev ConnEvent) *shardMetrics {
+	k := nodeShard(ev)
+	if v, ok := m.shards.Load(k); ok {
+		return v.(*shardMetrics)
+	}
+	v, _ := m.shards.LoadOrStore(k, &shardMetrics{v: ShardMetrics{Latency: newHistogram(m.buckets)}})
+	return v.(*shardMetrics)
+}
+
+func (m *MemoryMetrics) OnRequest(ev RequestEvent) {
+	if ev.Err != nil {
+		code := errorCode(ev.Err)
+		v, ok := m.errors.Load(code)
+		if !ok {
+			v, _ = m.errors.LoadOrStore(code, new(atomic.Uint64))
+		}
+		v.(*atomic.Uint64).Inc()
+	}
+
+	s := m.shard(ev.ConnEvent)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.v.Latency.observe(ev.Duration())
+	s.v.Requests++
+	s.v.StreamsInUse = ev.StreamsInUse
+	if ev.StreamsInUse > s.v.MaxStreamsInUse {
+		s.v.MaxStreamsInUse = ev.StreamsInUse
+	}
+	if ev.Err != nil {
+		s.v.Errors++
+	}
+}
+
+func errorCode(err error) frame.ErrorCode {
+	var coded CodedError
+	if errors.As(err, &coded) {
+		return coded.ErrorCode()
+	}
+	return ErrCodeOther
+}
+
+func (m *MemoryMetrics) OnTimeout(ev ConnEvent) {
+	m.timeouts.Inc()
+	s := m.shard(ev)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.v.Timeouts++
+}
+
+func (m *MemoryMetrics) OnRetry(RetryEvent) {
+	m.retries.Inc()
+}
+
+func (m *MemoryMetrics) OnFrame(ev FrameEvent) {
+	if ev.Direction == FrameSent {
+		m.bytesSent.Add(uint64(ev.Size))
+		m.uncompressedBytesSent.Add(uint64(ev.UncompressedSize))
+	} else {
+		m.bytesReceived.Add(uint64(ev.Size))
+		m.uncompressedBytesReceived.Add(uint64(ev.UncompressedSize))
+	}
+}
+
+func (m *MemoryMetrics) OnPoolSize(ev PoolSizeEvent) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.pools[ev.Addr] = PoolMetrics{Size: ev.Size, Capacity: ev.Capacity}
+}
+
+func (m *MemoryMetrics) OnTopologyRefresh(ev TopologyRefreshEvent) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.topologyRefreshes++
+	if ev.Err != nil {
+		m.topologyRefreshErrors++
+	}
+	m.topologyRefreshLatency.observe(ev.Duration())
+}
+
+// Snapshot returns a copy of collected metrics, counters updated concurrently
+// with the snapshot may be included in some of the metrics only.
+func (m *MemoryMetrics) Snapshot() MetricsSnapshot {
+	s := MetricsSnapshot{
+		Shards:                    make(map[NodeShard]ShardMetrics),
+		Errors:                    make(map[frame.ErrorCode]uint64),
+		Timeouts:                  m.timeouts.Load(),
+		Retries:                   m.retries.Load(),
+		BytesSent:                 m.bytesSent.Load(),
+		BytesReceived:             m.bytesReceived.Load(),
+		UncompressedBytesSent:     m.uncompressedBytesSent.Load(),
+		UncompressedBytesReceived: m.uncompressedBytesReceived.Load(),
+	}
+	m.shards.Range(func(k, v any) bool {
+		sm := v.(*shardMetrics)
+		sm.mu.Lock()
+		c := sm.v
+		c.Latency = c.Latency.clone()
+		sm.mu.Unlock()
+		s.Shards[k.(NodeShard)] = c
+		return true
+	})
+	m.errors.Range(func(k, v any) bool {
+		s.Errors[k.(frame.ErrorCode)] = v.(*atomic.Uint64).Load()
+		return true
+	})
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s.Pools = make(map[string]PoolMetrics, len(m.pools))
+	for k, v := range m.pools {
+		s.Pools[k] = v
+	}
+	s.TopologyRefreshes = m.topologyRefreshes
+	s.TopologyRefreshErrors = m.topologyRefreshErrors
+	s.TopologyRefreshLatency = m.topologyRefreshLatency.clone()
+	return s
+}
+
+func (c *Conn) onFrame(dir FrameDirection, size, uncompressedSize int) {
+	c.cfg.Metrics.OnFrame(FrameEvent{
+		ConnEvent:        c.Event(),
+		Direction:        dir,
+		Size:             size,
+		UncompressedSize: uncompressedSize,
+	})
+}
+
+func (c *Conn) onResponse(start time.Time, resp response) {
+	ev := RequestEvent{
+		ConnEvent:    c.Event(),
+		span:         span{Start: start, End: Now()},
+		Err:          resp.Err,
+		StreamsInUse: int(c.stats.inFlight.Load()),
+	}
+	if err, ok := resp.Response.(CodedError); ok {
+		ev.Err = err
+	}
+	c.cfg.Metrics.OnRequest(ev)
+}
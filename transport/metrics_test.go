@@ -0,0 +1,250 @@
+package transport
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/scylladb/scylla-go-driver/frame"
+	. "github.com/scylladb/scylla-go-driver/frame/response"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestHistogramObserve(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name   string
+		values []time.Duration
+		counts []uint64
+	}{
+		{
+			name:   "empty",
+			counts: []uint64{0, 0, 0},
+		},
+		{
+			name:   "bounds are inclusive",
+			values: []time.Duration{time.Millisecond, 10 * time.Millisecond},
+			counts: []uint64{1, 1, 0},
+		},
+		{
+			name:   "above all bounds",
+			values: []time.Duration{time.Microsecond, 2 * time.Millisecond, time.Second, time.Minute},
+			counts: []uint64{1, 1, 2},
+		},
+	}
+
+	for i := 0; i < len(testCases); i++ {
+		tc := testCases[i]
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			h := newHistogram([]time.Duration{time.Millisecond, 10 * time.Millisecond})
+			var sum time.Duration
+			for _, v := range tc.values {
+				h.observe(v)
+				sum += v
+			}
+			if diff := cmp.Diff(tc.counts, h.Counts); diff != "" {
+				t.Fatal(diff)
+			}
+			if h.Count != uint64(len(tc.values)) || h.Sum != sum {
+				t.Fatalf("count %d sum %s, expected %d %s", h.Count, h.Sum, len(tc.values), sum)
+			}
+		})
+	}
+}
+
+func testMetrics() *MemoryMetrics {
+	m := NewMemoryMetrics([]time.Duration{time.Millisecond, 10 * time.Millisecond})
+	start := time.Unix(1700000000, 0)
+	ev := ConnEvent{Addr: "10.0.0.1:19042", Shard: 1}
+
+	m.OnRequest(RequestEvent{ConnEvent: ev, span: span{Start: start, End: start.Add(5 * time.Millisecond)}, StreamsInUse: 3})
+	m.OnRequest(RequestEvent{ConnEvent: ev, span: span{Start: start, End: start.Add(time.Second)}, StreamsInUse: 1,
+		Err: ScyllaError{Code: frame.ErrCodeOverloaded}})
+	m.OnRequest(RequestEvent{ConnEvent: ConnEvent{Addr: "10.0.0.2:9042", Shard: UnknownShard}, Err: errors.New("eof")})
+	m.OnTimeout(ev)
+	m.OnRetry(RetryEvent{ConnEvent: ev, Decision: RetryNextNode})
+	m.OnFrame(FrameEvent{ConnEvent: ev, Direction: FrameSent, Size: 50, UncompressedSize: 100})
+	m.OnFrame(FrameEvent{ConnEvent: ev, Direction: FrameReceived, Size: 50, UncompressedSize: 200})
+	m.OnPoolSize(PoolSizeEvent{Addr: "10.0.0.1", Size: 1, Capacity: 2})
+	m.OnPoolSize(PoolSizeEvent{Addr: "10.0.0.1", Size: 2, Capacity: 2})
+	m.OnTopologyRefresh(TopologyRefreshEvent{span: span{Start: start, End: start.Add(time.Millisecond)}})
+	m.OnTopologyRefresh(TopologyRefreshEvent{Err: errors.New("fail")})
+	return m
+}
+
+func TestMemoryMetricsSnapshot(t *testing.T) {
+	t.Parallel()
+
+	m := testMetrics()
+	s := m.Snapshot()
+
+	expected := MetricsSnapshot{
+		Shards: map[NodeShard]ShardMetrics{
+			{Addr: "10.0.0.1", Shard: 1}: {
+				Latency: Histogram{
+					Buckets: []time.Duration{time.Millisecond, 10 * time.Millisecond},
+					Counts:  []uint64{0, 1, 1},
+					Count:   2,
+					Sum:     time.Second + 5*time.Millisecond,
+				},
+				Requests:        2,
+				Errors:          1,
+				Timeouts:        1,
+				StreamsInUse:    1,
+				MaxStreamsInUse: 3,
+			},
+			{Addr: "10.0.0.2", Shard: UnknownShard}: {
+				Latency: Histogram{
+					Buckets: []time.Duration{time.Millisecond, 10 * time.Millisecond},
+					Counts:  []uint64{1, 0, 0},
+					Count:   1,
+				},
+				Requests: 1,
+				Errors:   1,
+			},
+		},
+		Pools: map[string]PoolMetrics{
+			"10.0.0.1": {Size: 2, Capacity: 2},
+		},
+		Errors: map[frame.ErrorCode]uint64{
+			frame.ErrCodeOverloaded: 1,
+			ErrCodeOther:            1,
+		},
+		Timeouts:                  1,
+		Retries:                   1,
+		BytesSent:                 50,
+		BytesReceived:             50,
+		UncompressedBytesSent:     100,
+		UncompressedBytesReceived: 200,
+		TopologyRefreshes:         2,
+		TopologyRefreshErrors:     1,
+		TopologyRefreshLatency: Histogram{
+			Buckets: []time.Duration{time.Millisecond, 10 * time.Millisecond},
+			Counts:  []uint64{2, 0, 0},
+			Count:   2,
+			Sum:     time.Millisecond,
+		},
+	}
+	if diff := cmp.Diff(expected, s); diff != "" {
+		t.Fatal(diff)
+	}
+	if r := s.CompressionRatio(); r != 3 {
+		t.Fatalf("compression ratio %v, expected 3", r)
+	}
+
+	// Snapshot must not share memory with metrics.
+	m.OnRequest(RequestEvent{ConnEvent: ConnEvent{Addr: "10.0.0.1:19042", Shard: 1}})
+	if diff := cmp.Diff(expected, s); diff != "" {
+		t.Fatal(diff)
+	}
+}
+
+func TestPrometheusHandler(t *testing.T) {
+	t.Parallel()
+
+	rec := httptest.NewRecorder()
+	NewPrometheusHandler(testMetrics()).ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	b, err := io.ReadAll(rec.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := string(b)
+
+	for _, line := range []string{
+		"# TYPE scylla_driver_request_latency_seconds histogram",
+		`scylla_driver_request_latency_seconds_bucket{node="10.0.0.1",shard="1",le="0.001"} 0`,
+		`scylla_driver_request_latency_seconds_bucket{node="10.0.0.1",shard="1",le="0.01"} 1`,
+		`scylla_driver_request_latency_seconds_bucket{node="10.0.0.1",shard="1",le="+Inf"} 2`,
+		`scylla_driver_request_latency_seconds_sum{node="10.0.0.1",shard="1"} 1.005`,
+		`scylla_driver_request_latency_seconds_count{node="10.0.0.2",shard="unknown"} 1`,
+		`scylla_driver_requests_total{node="10.0.0.1",shard="1"} 2`,
+		`scylla_driver_request_errors_total{code="other"} 1`,
+		`scylla_driver_request_errors_total{code="0x1001"} 1`,
+		"scylla_driver_timeouts_total 1",
+		"scylla_driver_retries_total 1",
+		`scylla_driver_pool_connections{node="10.0.0.1"} 2`,
+		`scylla_driver_pool_capacity{node="10.0.0.1"} 2`,
+		"scylla_driver_bytes_sent_total 50",
+		"scylla_driver_compression_ratio 3",
+		"scylla_driver_topology_refresh_errors_total 1",
+	} {
+		if !strings.Contains(out, line+"\n") {
+			t.Errorf("missing %q", line)
+		}
+	}
+	if t.Failed() {
+		t.Log(out)
+	}
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Fatalf("content type %q", ct)
+	}
+}
+
+func TestPrometheusLabelEscaping(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name     string
+		value    string
+		expected string
+	}{
+		{name: "plain", value: "10.0.0.1", expected: `m{l="10.0.0.1"} 1`},
+		{name: "escaped", value: "a\\b\"c\nd", expected: `m{l="a\\b\"c\nd"} 1`},
+		{name: "utf8 and tab kept", value: "zażółć\t", expected: "m{l=\"zażółć\t\"} 1"},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var b strings.Builder
+			p := promWriter{w: bufio.NewWriter(&b)}
+			p.sample("m", []string{"l", tc.value}, 1)
+			p.w.Flush()
+			if out := strings.TrimPrefix(b.String(), prometheusPrefix); out != tc.expected+"\n" {
+				t.Fatalf("got %q, expected %q", out, tc.expected)
+			}
+		})
+	}
+}
+
+func TestMemoryMetricsConcurrent(t *testing.T) {
+	t.Parallel()
+
+	const workers, n = 8, 1000
+	m := NewMemoryMetrics(nil)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(shard uint16) {
+			defer wg.Done()
+			for j := 0; j < n; j++ {
+				m.OnFrame(FrameEvent{Direction: FrameSent, Size: 1, UncompressedSize: 1})
+				m.OnRequest(RequestEvent{ConnEvent: ConnEvent{Addr: "10.0.0.1:9042", Shard: shard % 2}})
+				m.Snapshot()
+			}
+		}(uint16(i))
+	}
+	wg.Wait()
+
+	s := m.Snapshot()
+	if s.BytesSent != workers*n {
+		t.Fatalf("bytes sent %d, expected %d", s.BytesSent, workers*n)
+	}
+	var requests uint64
+	for _, v := range s.Shards {
+		requests += v.Requests
+	}
+	if requests != workers*n || len(s.Shards) != 2 {
+		t.Fatalf("requests %d in %d shards, expected %d in 2", requests, len(s.Shards), workers*n)
+	}
+}
@@ -3,8 +3,10 @@ package transport
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/scylladb/scylla-go-driver/frame"
+	"github.com/scylladb/scylla-go-driver/log"
 	"go.uber.org/atomic"
 )
 
@@ -22,6 +24,43 @@ type Node struct {
 	rack       string
 	pool       *ConnPool
 	status     nodeStatus
+	// filtered is set if node is rejected by HostFilter.
+	filtered bool
+	limiter  *limiter
+	breaker  *circuitBreaker
+}
+
+// NodeInfo is a snapshot of node state used for introspection.
+type NodeInfo struct {
+	HostID     frame.UUID
+	Addr       string
+	Datacenter string
+	Rack       string
+	Up         bool
+
+	// ReconnectAttempts is the number of consecutive attempts to fill node connection pool
+	// that left it incomplete, 0 if the pool is full.
+	ReconnectAttempts int
+	// ReconnectDelay is the current delay before the next attempt to fill node connection pool.
+	ReconnectDelay time.Duration
+	// Circuit is the state of node circuit breaker, it's always closed if circuit breakers are disabled.
+	Circuit CircuitState
+}
+
+func (n *Node) Info() NodeInfo {
+	info := NodeInfo{
+		HostID:     n.hostID,
+		Addr:       n.addr,
+		Datacenter: n.datacenter,
+		Rack:       n.rack,
+		Up:         n.IsUp(),
+		Circuit:    n.breaker.currentState(),
+	}
+	if n.pool != nil {
+		info.ReconnectAttempts = int(n.pool.reconnectAttempts.Load())
+		info.ReconnectDelay = n.pool.reconnectDelay.Load()
+	}
+	return info
 }
 
 func (n *Node) IsUp() bool {
@@ -39,7 +78,7 @@ func (n *Node) Init(ctx context.Context, cfg ConnConfig) {
 		if err == nil {
 			n.setStatus(statusUP)
 		} else {
-			cfg.Logger.Infof("couldn't create a connection pool to node %v: %v;setting node status to DOWN", n, err)
+			cfg.Logger.Info("couldn't create a connection pool to node, setting node status to DOWN", log.Node(n.addr), log.Err(err))
 			n.setStatus(statusDown)
 		}
 	}
@@ -63,11 +102,39 @@ func (n *Node) Conn(qi QueryInfo) (*Conn, error) {
 	if !n.IsUp() {
 		return nil, fmt.Errorf("node %v is down", n)
 	}
+	if n.breaker.open(Now()) {
+		return nil, fmt.Errorf("node %v: %w", n.addr, ErrCircuitOpen)
+	}
+	return n.conn(qi)
+}
+
+// conn returns connection that would be used to execute the query on node, it doesn't check node status.
+func (n *Node) conn(qi QueryInfo) (*Conn, error) {
+	if qi.tablet != nil {
+		if shard, ok := qi.tablet.shard(n); ok {
+			return n.pool.ShardConn(shard)
+		}
+	}
 	if qi.tokenAware {
 		return n.pool.Conn(qi.token)
 	}
 
-	return n.LeastBusyConn()
+	return n.pool.LeastBusyConn()
+}
+
+// load returns the number of requests waiting on the connection that would be used to execute the query,
+// it reads pool counters without picking the connection.
+func (n *Node) load(qi QueryInfo) int {
+	shard := -1
+	if qi.tablet != nil {
+		if s, ok := qi.tablet.shard(n); ok {
+			shard = s
+		}
+	}
+	if shard < 0 && qi.tokenAware && n.pool.sharded {
+		shard = n.pool.shardOf(qi.token)
+	}
+	return n.pool.waiting(shard)
 }
 
 func (n *Node) Prepare(ctx context.Context, s Statement) (Statement, error) {
@@ -115,6 +182,8 @@ type RingEntry struct {
 	token          Token
 	localReplicas  []*Node
 	remoteReplicas []*Node
+	// localRackCnt is the number of local rack replicas at the beginning of localReplicas.
+	localRackCnt int
 }
 
 func (r RingEntry) Less(i RingEntry) bool {
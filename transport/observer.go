@@ -20,6 +20,16 @@ func (ev ConnEvent) String() string {
 	return fmt.Sprintf("[addr=%s shard=%d]", ev.Addr, ev.Shard)
 }
 
+// logAttrs returns attributes identifying connection followed by attrs.
+func (ev ConnEvent) logAttrs(attrs ...log.Attr) []log.Attr {
+	res := make([]log.Attr, 0, len(attrs)+2)
+	res = append(res, log.Node(ev.Addr))
+	if ev.Shard != UnknownShard {
+		res = append(res, log.Shard(int(ev.Shard)))
+	}
+	return append(res, attrs...)
+}
+
 type span struct {
 	Start time.Time
 	End   time.Time
@@ -47,25 +57,50 @@ type ConnectEvent struct {
 	Err error
 }
 
+// HeartbeatEvent describes heartbeat sent on idle connection, its duration is the heartbeat latency.
+type HeartbeatEvent struct {
+	ConnEvent
+	span
+
+	// Err is the heartbeat error (if any), connection is closed after a failed heartbeat.
+	Err error
+}
+
 type ConnObserver interface {
 	OnConnect(ev ConnectEvent)
 	OnPickReplacedWithLessBusyConn(ev ConnEvent)
 }
 
+// HeartbeatObserver may be implemented by ConnObserver to be notified about heartbeats.
+type HeartbeatObserver interface {
+	OnHeartbeat(ev HeartbeatEvent)
+}
+
 type LoggingConnObserver struct {
 	log log.Logger
 }
 
-var _ ConnObserver = LoggingConnObserver{}
+var (
+	_ ConnObserver      = LoggingConnObserver{}
+	_ HeartbeatObserver = LoggingConnObserver{}
+)
 
 func (o LoggingConnObserver) OnConnect(ev ConnectEvent) {
 	if ev.Err != nil {
-		o.log.Infof("%s failed to open connection after %s: %s", ev, ev.Duration(), ev.Err)
+		o.log.Info("failed to open connection", ev.logAttrs(log.Duration("duration", ev.Duration()), log.Err(ev.Err))...)
 	} else {
-		o.log.Infof("%s connected in %s", ev, ev.Duration())
+		o.log.Info("connected", ev.logAttrs(log.Duration("duration", ev.Duration()))...)
 	}
 }
 
 func (o LoggingConnObserver) OnPickReplacedWithLessBusyConn(ev ConnEvent) {
-	o.log.Infof("%s pick replaced with less busy conn", ev)
+	o.log.Info("pick replaced with less busy conn", ev.logAttrs()...)
+}
+
+func (o LoggingConnObserver) OnHeartbeat(ev HeartbeatEvent) {
+	if ev.Err != nil {
+		o.log.Info("heartbeat failed", ev.logAttrs(log.Duration("duration", ev.Duration()), log.Err(ev.Err))...)
+	} else {
+		o.log.Debug("heartbeat", ev.logAttrs(log.Duration("duration", ev.Duration()))...)
+	}
 }
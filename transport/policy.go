@@ -2,6 +2,7 @@ package transport
 
 import (
 	"sort"
+	"sync"
 
 	"github.com/scylladb/scylla-go-driver/log"
 )
@@ -13,18 +14,39 @@ type HostSelectionPolicy interface {
 }
 
 type TokenAwarePolicy struct {
-	localDC string
+	localDC   string
+	localRack string
+	// powerOfTwoChoices enables picking the less loaded of two sampled nodes.
+	powerOfTwoChoices bool
 }
 
 func NewTokenAwarePolicy(localDC string) *TokenAwarePolicy {
 	return &TokenAwarePolicy{localDC: localDC}
 }
 
+// NewTokenAwareRackAwarePolicy returns policy that prefers replicas from the local rack,
+// then the rest of the local datacenter and then remote datacenters.
+// Queries that are not token aware are routed in a rack aware round robin fashion.
+func NewTokenAwareRackAwarePolicy(localDC, localRack string) *TokenAwarePolicy {
+	return &TokenAwarePolicy{localDC: localDC, localRack: localRack}
+}
+
+// SetPowerOfTwoChoices enables load aware choice of the first node, instead of taking the next node
+// of the most preferred nodes (local rack, local datacenter or replicas) in a round robin fashion,
+// two of them are sampled and the one with fewer requests waiting on the target connection is picked.
+// The load is checked once per query, so the plan doesn't change between retries.
+// It must be set before the policy is used.
+func (p *TokenAwarePolicy) SetPowerOfTwoChoices(v bool) {
+	p.powerOfTwoChoices = v
+}
+
 func (p *TokenAwarePolicy) Node(qi QueryInfo, offset int) *Node {
 	if p.localDC == "" {
 		var replicas []*Node
-		pi := qi.topology.policyInfo
-		if qi.tokenAware {
+		pi := qi.policyInfo()
+		if qi.tablet != nil {
+			replicas = qi.tablet.local
+		} else if qi.tokenAware {
 			pos := pi.ring.tokenLowerBound(qi.token)
 			replicas = pi.ring[pos].localReplicas
 		} else {
@@ -36,38 +58,125 @@ func (p *TokenAwarePolicy) Node(qi QueryInfo, offset int) *Node {
 			return nil
 		}
 
-		idx := (qi.offset + uint64(offset)) % uint64(len(replicas))
-		return replicas[idx]
+		return p.pick(qi, replicas, offset, true)
 	}
 
 	var local, remote []*Node
-	pi := qi.topology.policyInfo
-	if qi.tokenAware {
+	var rackCnt int
+	pi := qi.policyInfo()
+	if qi.tablet != nil {
+		local, remote, rackCnt = qi.tablet.local, qi.tablet.remote, qi.tablet.localRackCnt
+	} else if qi.tokenAware {
 		pos := pi.ring.tokenLowerBound(qi.token)
 		local = pi.ring[pos].localReplicas
 		remote = pi.ring[pos].remoteReplicas
+		rackCnt = pi.ring[pos].localRackCnt
 	} else {
 		// Fallback to DC aware round robin on all nodes.
 		local = pi.localNodes
 		remote = pi.remoteNodes
+		rackCnt = pi.localRackCnt
+	}
+	// Local rack nodes are at the beginning of local nodes.
+	if p.localRack == "" {
+		rackCnt = 0
 	}
 
-	if offset < len(local) {
-		idx := (qi.offset + uint64(offset)) % uint64(len(local))
-		return local[idx]
+	if offset < rackCnt {
+		return p.pick(qi, local[:rackCnt], offset, true)
+	} else if offset < len(local) {
+		return p.pick(qi, local[rackCnt:], offset-rackCnt, rackCnt == 0)
 	} else if offset < len(local)+len(remote) {
-		idx := (qi.offset + uint64(offset) - uint64(len(local))) % uint64(len(remote))
-		return remote[idx]
+		return p.pick(qi, remote, offset-len(local), len(local) == 0)
 	}
 
 	return nil
 }
 
+// pick returns i-th node of nodes rotated by query offset. If preferred is set and power of two choices
+// is enabled, the less loaded of the first node and a node sampled based on query offset is moved to the front.
+func (p *TokenAwarePolicy) pick(qi QueryInfo, nodes []*Node, i int, preferred bool) *Node {
+	n := uint64(len(nodes))
+	pos := uint64(i)
+	if preferred && p.powerOfTwoChoices && n > 1 {
+		// Query offsets are consecutive, scramble them to sample a node at a varying distance.
+		d := 1 + (qi.offset*0x9e3779b97f4a7c15>>32)%(n-1)
+		swap := qi.plan.powerOfTwoChoices(func() bool {
+			return nodeLoad(nodes[(qi.offset+d)%n], qi) < nodeLoad(nodes[qi.offset%n], qi)
+		})
+		if swap {
+			switch {
+			case pos == 0:
+				pos = d
+			case pos <= d:
+				pos--
+			}
+		}
+	}
+	return nodes[(qi.offset+pos)%n]
+}
+
+// nodeLoad returns the number of requests waiting on the connection that would be used to execute the query.
+func nodeLoad(n *Node, qi QueryInfo) int {
+	if !n.IsUp() || n.breaker.open(Now()) {
+		return maxStreamID + 2
+	}
+	return n.load(qi)
+}
+
+// queryPlan holds decisions made when query plan is iterated for the first time.
+type queryPlan struct {
+	p2cOnce sync.Once
+	p2cSwap bool
+
+	latencyOnce  sync.Once
+	latencyNodes []*Node
+}
+
+// powerOfTwoChoices returns result of swap, it's called once per plan.
+func (p *queryPlan) powerOfTwoChoices(swap func() bool) bool {
+	if p == nil {
+		return swap()
+	}
+	p.p2cOnce.Do(func() {
+		p.p2cSwap = swap()
+	})
+	return p.p2cSwap
+}
+
+// latencyAware returns result of plan, it's called once per plan.
+func (p *queryPlan) latencyAware(plan func() []*Node) []*Node {
+	if p == nil {
+		return plan()
+	}
+	p.latencyOnce.Do(func() {
+		p.latencyNodes = plan()
+	})
+	return p.latencyNodes
+}
+
+// tokenAwarePolicy returns p or the TokenAwarePolicy wrapped by it,
+// wrapping policies such as LatencyAwarePolicy implement Unwrap.
+func tokenAwarePolicy(p HostSelectionPolicy) (*TokenAwarePolicy, bool) {
+	for {
+		switch v := p.(type) {
+		case *TokenAwarePolicy:
+			return v, true
+		case interface{ Unwrap() HostSelectionPolicy }:
+			p = v.Unwrap()
+		default:
+			return nil, false
+		}
+	}
+}
+
 type policyInfo struct {
 	ring Ring
 
 	localNodes  []*Node
 	remoteNodes []*Node
+	// localRackCnt is the number of local rack nodes at the beginning of localNodes.
+	localRackCnt int
 }
 
 func (pi *policyInfo) Preprocess(t *topology, ks keyspace, logger log.Logger) {
@@ -76,8 +185,9 @@ func (pi *policyInfo) Preprocess(t *topology, ks keyspace, logger log.Logger) {
 		pi.preprocessSimpleStrategy(t, ks.strategy)
 	case networkTopologyStrategy:
 		pi.preprocessNetworkTopologyStrategy(t, ks.strategy)
+		pi.preprocessDCAwareRoundRobinStrategy(t)
 	default:
-		logger.Warnf("policyInfo: keyspace %v has unknown strategy, defaulting to round robin")
+		logger.Warn("policyInfo: keyspace has unknown strategy, defaulting to round robin", log.String("strategy", string(ks.strategy.class)))
 		if t.localDC == "" {
 			pi.preprocessRoundRobinStrategy(t)
 		} else {
@@ -87,7 +197,7 @@ func (pi *policyInfo) Preprocess(t *topology, ks keyspace, logger log.Logger) {
 }
 
 func (pi *policyInfo) preprocessSimpleStrategy(t *topology, stg strategy) {
-	pi.localNodes = t.Nodes
+	pi.localNodes, pi.localRackCnt = t.localRackFirst(unfiltered(t.Nodes))
 	sort.Sort(pi.ring)
 	trie := trieRoot()
 	for i := range pi.ring {
@@ -118,25 +228,30 @@ func (pi *policyInfo) preprocessSimpleStrategy(t *topology, stg strategy) {
 				cur = cur.Next(n)
 			}
 		}
-		pi.ring[i].localReplicas = cur.Path()
+		pi.ring[i].localReplicas, pi.ring[i].localRackCnt = t.localRackFirstPath(&trie, cur.Path())
 	}
 }
 
 func (pi *policyInfo) preprocessRoundRobinStrategy(t *topology) {
-	pi.localNodes = t.Nodes
+	pi.localNodes = unfiltered(t.Nodes)
 	pi.remoteNodes = nil
+	pi.localRackCnt = 0
 }
 
 func (pi *policyInfo) preprocessDCAwareRoundRobinStrategy(t *topology) {
 	pi.localNodes = make([]*Node, 0)
 	pi.remoteNodes = make([]*Node, 0)
 	for _, v := range t.Nodes {
+		if v.filtered {
+			continue
+		}
 		if v.datacenter == t.localDC {
 			pi.localNodes = append(pi.localNodes, v)
 		} else {
 			pi.remoteNodes = append(pi.remoteNodes, v)
 		}
 	}
+	pi.localNodes, pi.localRackCnt = t.localRackFirst(pi.localNodes)
 }
 
 func (pi *policyInfo) preprocessNetworkTopologyStrategy(t *topology, stg strategy) {
@@ -196,17 +311,79 @@ func (pi *policyInfo) preprocessNetworkTopologyStrategy(t *topology, stg strateg
 			}
 		}
 
-		local := &trie
+		var local []*Node
 		remote := &trie
 		for _, n := range plan {
+			if n.filtered {
+				continue
+			}
 			if n.datacenter == t.localDC {
-				local = local.Next(n)
+				local = append(local, n)
 			} else {
 				remote = remote.Next(n)
 			}
 		}
 
-		pi.ring[i].localReplicas = local.Path()
+		pi.ring[i].localReplicas, pi.ring[i].localRackCnt = t.localRackFirstPath(&trie, local)
 		pi.ring[i].remoteReplicas = remote.Path()
 	}
 }
+
+func (t *topology) inLocalRack(n *Node) bool {
+	return t.localRack != "" && n.datacenter == t.localDC && n.rack == t.localRack
+}
+
+// localRackFirst returns nodes with local rack nodes moved to the beginning and the number of local rack nodes.
+func (t *topology) localRackFirst(nodes []*Node) ([]*Node, int) {
+	if t.localRack == "" {
+		return nodes, 0
+	}
+	res := make([]*Node, 0, len(nodes))
+	for _, n := range nodes {
+		if t.inLocalRack(n) {
+			res = append(res, n)
+		}
+	}
+	cnt := len(res)
+	for _, n := range nodes {
+		if !t.inLocalRack(n) {
+			res = append(res, n)
+		}
+	}
+	return res, cnt
+}
+
+// localRackFirstPath is like localRackFirst, but it skips filtered nodes and the result is a trie path
+// so that it's shared between ring entries.
+func (t *topology) localRackFirstPath(root *trie, nodes []*Node) ([]*Node, int) {
+	cur := root
+	cnt := 0
+	for _, n := range nodes {
+		if !n.filtered && t.inLocalRack(n) {
+			cur = cur.Next(n)
+			cnt++
+		}
+	}
+	for _, n := range nodes {
+		if !n.filtered && !t.inLocalRack(n) {
+			cur = cur.Next(n)
+		}
+	}
+	return cur.Path(), cnt
+}
+
+// unfiltered returns nodes that are not filtered by HostFilter.
+func unfiltered(nodes []*Node) []*Node {
+	for i, n := range nodes {
+		if n.filtered {
+			res := append(make([]*Node, 0, len(nodes)-1), nodes[:i]...)
+			for _, v := range nodes[i+1:] {
+				if !v.filtered {
+					res = append(res, v)
+				}
+			}
+			return res
+		}
+	}
+	return nodes
+}
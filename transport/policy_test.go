@@ -5,6 +5,8 @@ import (
 
 	"github.com/scylladb/scylla-go-driver/frame"
 	"github.com/scylladb/scylla-go-driver/log"
+
+	"go.uber.org/atomic"
 )
 
 // Round-Robin tests can't be run in parallel because
@@ -26,7 +28,7 @@ func mockTopologyRoundRobin() *topology {
 }
 
 func mockCluster(t *topology, ks, localDC string) *Cluster {
-	c := Cluster{}
+	c := Cluster{tablets: newTabletCache()}
 	t.localDC = localDC
 
 	if k, ok := t.keyspaces[ks]; ok {
@@ -336,3 +338,231 @@ func TestTokenAwareNetworkStrategyPolicy(t *testing.T) { //nolint:paralleltest /
 		})
 	}
 }
+
+func TestTokenAwareRackAwarePolicy(t *testing.T) {
+	t.Parallel()
+	top := mockTopologyTokenAwareDCAwareStrategy()
+	top.localRack = "r2"
+	c := mockCluster(top, "waw/her", "waw")
+	policy := NewTokenAwareRackAwarePolicy("waw", "r2")
+
+	tokenAware, err := c.NewTokenAwareQueryInfo(0, "waw/her")
+	if err != nil {
+		t.Fatal(err)
+	}
+	roundRobin := c.NewQueryInfo()
+	roundRobin.offset = 1
+
+	testCases := []struct {
+		name     string
+		qi       QueryInfo
+		expected []string
+	}{
+		{
+			name:     "token aware",
+			qi:       tokenAware,
+			expected: []string{"4", "1", "5", "6", "8"},
+		},
+		{
+			name:     "rack aware round robin",
+			qi:       roundRobin,
+			expected: []string{"4", "3", "2", "1", "6", "7", "8", "5"},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			for offset, addr := range tc.expected {
+				if res := policy.Node(tc.qi, offset).addr; res != addr {
+					t.Fatalf("offset %d: got %q but expected %q", offset, res, addr)
+				}
+			}
+			if policy.Node(tc.qi, len(tc.expected)) != nil {
+				t.Fatalf("plan iter didn't return nil after making the whole cycle")
+			}
+		})
+	}
+}
+
+func TestPowerOfTwoChoicesPolicy(t *testing.T) {
+	t.Parallel()
+	top := mockTopologyRoundRobin()
+	// Node "2" is the busiest one in "eu".
+	load := map[string]uint32{"1": 10, "2": 100, "3": 20, "4": 0, "5": 0}
+	for _, n := range top.Nodes {
+		conn := &Conn{stats: new(stats)}
+		conn.stats.inFlight.Store(load[n.addr])
+		n.pool = &ConnPool{host: n.addr, conns: make([]atomic.Value, 1)}
+		n.pool.storeConnAt(0, conn)
+		n.setStatus(statusUP)
+	}
+	c := mockCluster(top, "", "eu")
+	policy := NewTokenAwarePolicy("eu")
+	policy.SetPowerOfTwoChoices(true)
+
+	for i := uint64(0); i < 10; i++ {
+		qi := c.NewQueryInfo()
+		qi.offset = i
+
+		var plan []string
+		seen := make(map[string]bool)
+		for offset := 0; ; offset++ {
+			n := policy.Node(qi, offset)
+			if n == nil {
+				break
+			}
+			if seen[n.addr] {
+				t.Fatalf("offset %d: node %s returned twice in plan %v", i, n.addr, plan)
+			}
+			seen[n.addr] = true
+			plan = append(plan, n.addr)
+		}
+		if len(plan) != len(top.Nodes) {
+			t.Fatalf("offset %d: expected all nodes in plan, got %v", i, plan)
+		}
+		if plan[0] == "2" {
+			t.Fatalf("offset %d: busiest node picked first in plan %v", i, plan)
+		}
+		if plan[3] != "4" && plan[3] != "5" {
+			t.Fatalf("offset %d: remote node before local nodes in plan %v", i, plan)
+		}
+	}
+}
+
+func TestPowerOfTwoChoicesPolicyLoadChange(t *testing.T) {
+	t.Parallel()
+	top := mockTopologyRoundRobin()
+	conns := make(map[string]*Conn)
+	for _, n := range top.Nodes {
+		conn := &Conn{stats: new(stats)}
+		conns[n.addr] = conn
+		n.pool = &ConnPool{host: n.addr, conns: make([]atomic.Value, 1)}
+		n.pool.storeConnAt(0, conn)
+		n.setStatus(statusUP)
+	}
+	c := mockCluster(top, "", "eu")
+	policy := NewTokenAwarePolicy("eu")
+	policy.SetPowerOfTwoChoices(true)
+
+	for i := uint64(0); i < 10; i++ {
+		qi := c.NewQueryInfo()
+		qi.offset = i
+		// The node at query offset is the most loaded one, so the sampled node is picked first.
+		for addr, conn := range conns {
+			if addr == top.Nodes[i%3].addr {
+				conn.stats.inFlight.Store(100)
+			} else {
+				conn.stats.inFlight.Store(0)
+			}
+		}
+
+		first := policy.Node(qi, 0)
+		plan := []string{first.addr}
+		seen := map[string]bool{first.addr: true}
+		for offset := 1; ; offset++ {
+			// Equal load would revert the swap.
+			for _, conn := range conns {
+				conn.stats.inFlight.Store(0)
+			}
+			n := policy.Node(qi, offset)
+			if n == nil {
+				break
+			}
+			if seen[n.addr] {
+				t.Fatalf("offset %d: node %s returned twice in plan %v", i, n.addr, plan)
+			}
+			seen[n.addr] = true
+			plan = append(plan, n.addr)
+		}
+		if len(plan) != len(top.Nodes) {
+			t.Fatalf("offset %d: expected all nodes in plan, got %v", i, plan)
+		}
+		if n := policy.Node(qi, 0); n != first {
+			t.Fatalf("offset %d: first node changed from %s to %s", i, first.addr, n.addr)
+		}
+	}
+}
+
+func TestTokenAwarePolicyPerKeyspace(t *testing.T) {
+	t.Parallel()
+	top := mockTopologyTokenAwareSimpleStrategy()
+	top.keyspaces["rf2copy"] = top.keyspaces["rf2"]
+	c := mockCluster(top, "", "")
+	policy := NewTokenAwarePolicy("")
+
+	testCases := []struct {
+		name     string
+		keyspace string
+		token    Token
+		expected []string
+	}{
+		{
+			name:     "rf2",
+			keyspace: "rf2",
+			token:    160,
+			expected: []string{"3", "1"},
+		},
+		{
+			name:     "rf3",
+			keyspace: "rf3",
+			token:    60,
+			expected: []string{"1", "2", "3"},
+		},
+		{
+			name:     "identical strategy",
+			keyspace: "rf2copy",
+			token:    160,
+			expected: []string{"3", "1"},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			qi, err := c.NewTokenAwareQueryInfo(tc.token, tc.keyspace)
+			if err != nil {
+				t.Fatal(err)
+			}
+			qi.offset = 0
+			for offset, addr := range tc.expected {
+				if res := policy.Node(qi, offset).addr; res != addr {
+					t.Fatalf("offset %d: got %q but expected %q", offset, res, addr)
+				}
+			}
+			if policy.Node(qi, len(tc.expected)) != nil {
+				t.Fatalf("plan iter didn't return nil after making the whole cycle")
+			}
+		})
+	}
+
+	rf2, _ := c.NewTokenAwareQueryInfo(0, "rf2")
+	rf2copy, _ := c.NewTokenAwareQueryInfo(0, "rf2copy")
+	if rf2.pi == nil || rf2.pi != rf2copy.pi {
+		t.Fatal("keyspaces with identical strategies should share policy info")
+	}
+}
+
+func TestTokenAwareQueryInfoUnknownKeyspace(t *testing.T) {
+	t.Parallel()
+	top := mockTopologyTokenAwareSimpleStrategy()
+	c := mockCluster(top, "rf2", "")
+	c.cfg.Logger = log.NewDebugLogger()
+	c.refreshChan = make(requestChan, 1)
+
+	qi, err := c.NewTabletAwareQueryInfo(160, "created_after_refresh", "t")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if qi.tokenAware {
+		t.Fatal("expected fallback to non-token aware query info")
+	}
+	if len(c.refreshChan) != 1 {
+		t.Fatal("expected topology refresh to be requested")
+	}
+	if n := NewTokenAwarePolicy("").Node(qi, 0); n == nil {
+		t.Fatal("expected node in plan")
+	}
+}
@@ -3,31 +3,46 @@ package transport
 import (
 	"context"
 	"fmt"
-	"log"
 	"math"
 	"net"
 	"time"
 
 	. "github.com/scylladb/scylla-go-driver/frame/response"
+	"github.com/scylladb/scylla-go-driver/log"
 
 	"go.uber.org/atomic"
 )
 
-const poolCloseShard = -1
+// connClosedChanSize is the capacity of pool close notification channel, notifications that don't fit
+// are dropped and closed connections are removed from the pool by the next fill.
+const connClosedChanSize = 64
 
 type ConnPool struct {
-	host         string
-	nrShards     int
-	msbIgnore    uint8
+	host          string
+	nrShards      int
+	msbIgnore     uint8
+	connsPerShard int
+	// conns of shard s are stored at indexes [s*connsPerShard, (s+1)*connsPerShard).
 	conns        []atomic.Value
-	connClosedCh chan int // notification channel for when connection is closed
+	connClosedCh chan *Conn // notification channel for when connection is closed, nil closes the pool
 	connObs      ConnObserver
+
+	// sharded is false for servers that do not report sharding information (e.g. Cassandra),
+	// conns are then not bound to shards and are picked in a round-robin fashion.
+	sharded   bool
+	rrCounter atomic.Uint64
+
+	// Reconnection state, updated by PoolRefiller.
+	reconnectAttempts atomic.Uint32
+	reconnectDelay    atomic.Duration
 }
 
 func NewConnPool(ctx context.Context, host string, cfg ConnConfig) (*ConnPool, error) {
 	r := PoolRefiller{
-		cfg: cfg,
+		cfg:          cfg,
+		connClosedCh: make(chan *Conn, connClosedChanSize),
 	}
+	r.cfg.onClose = r.onConnClose
 	if err := r.init(ctx, host); err != nil {
 		return nil, err
 	}
@@ -38,12 +53,28 @@ func NewConnPool(ctx context.Context, host string, cfg ConnConfig) (*ConnPool, e
 }
 
 func (p *ConnPool) String() string {
-	return fmt.Sprintf("pool %s [shards=%d]", p.host, p.nrShards)
+	if !p.sharded {
+		return fmt.Sprintf("pool %s [conns=%d]", p.host, len(p.conns))
+	}
+	return fmt.Sprintf("pool %s [shards=%d conns_per_shard=%d]", p.host, p.nrShards, p.connsPerShard)
 }
 
 func (p *ConnPool) Conn(token Token) (*Conn, error) {
-	idx := p.shardOf(token)
-	if conn := p.loadConn(idx); conn != nil {
+	if !p.sharded {
+		return p.roundRobinConn()
+	}
+	return p.ShardConn(p.shardOf(token))
+}
+
+// ShardConn returns connection to a given shard, if there is none the least busy connection is returned.
+func (p *ConnPool) ShardConn(shard int) (*Conn, error) {
+	if !p.sharded {
+		return p.roundRobinConn()
+	}
+	if shard < 0 || shard >= p.nrShards {
+		return p.LeastBusyConn()
+	}
+	if conn := p.leastBusyShardConn(shard); conn != nil {
 		if isHeavyLoaded(conn) {
 			return p.maybeReplaceWithLessBusyConn(conn), nil
 		}
@@ -87,6 +118,51 @@ func (p *ConnPool) LeastBusyConn() (*Conn, error) {
 	return leastBusyConn, nil
 }
 
+// leastBusyShardConn returns the least busy connection to a given shard, nil if there are none.
+func (p *ConnPool) leastBusyShardConn(shard int) *Conn {
+	var (
+		leastBusyConn *Conn
+		minBusy       = maxStreamID + 2 // adding 2 more is required due to atomics
+	)
+
+	for i := shard * p.connsPerShard; i < (shard+1)*p.connsPerShard; i++ {
+		if conn := p.loadConn(i); conn != nil {
+			if waiting := conn.Waiting(); waiting < minBusy {
+				leastBusyConn = conn
+				minBusy = waiting
+			}
+		}
+	}
+
+	return leastBusyConn
+}
+
+// waiting returns the number of requests waiting on the least busy connection to shard, or on the least
+// busy connection of the pool if shard has no connections. Unlike ShardConn it doesn't replace heavily
+// loaded connections, so that load can be estimated without notifying ConnObserver.
+func (p *ConnPool) waiting(shard int) int {
+	if p.sharded && shard >= 0 && shard < p.nrShards {
+		if conn := p.leastBusyShardConn(shard); conn != nil {
+			return conn.Waiting()
+		}
+	}
+	if conn, err := p.LeastBusyConn(); err == nil {
+		return conn.Waiting()
+	}
+	return maxStreamID + 2
+}
+
+func (p *ConnPool) roundRobinConn() (*Conn, error) {
+	start := p.rrCounter.Inc()
+	for i := range p.conns {
+		idx := (start + uint64(i)) % uint64(len(p.conns))
+		if conn := p.loadConn(int(idx)); conn != nil {
+			return conn, nil
+		}
+	}
+	return nil, fmt.Errorf("no connections available for host %s", p.host)
+}
+
 func (p *ConnPool) shardOf(token Token) int {
 	shards := uint64(p.nrShards)
 	z := uint64(token+math.MinInt64) << p.msbIgnore
@@ -98,8 +174,33 @@ func (p *ConnPool) shardOf(token Token) int {
 	return int(sum >> 32)
 }
 
-func (p *ConnPool) storeConn(conn *Conn) {
-	p.conns[conn.Shard()].Store(conn)
+// storeConn stores conn in a free slot of its shard, returns false if all of them are taken.
+func (p *ConnPool) storeConn(conn *Conn) bool {
+	shard := conn.Shard()
+	if shard >= p.nrShards {
+		return false
+	}
+	for i := shard * p.connsPerShard; i < (shard+1)*p.connsPerShard; i++ {
+		if p.loadConn(i) == nil {
+			p.storeConnAt(i, conn)
+			return true
+		}
+	}
+	return false
+}
+
+func (p *ConnPool) storeConnAt(idx int, conn *Conn) {
+	p.conns[idx].Store(conn)
+}
+
+// slotOf returns index under which conn is stored, -1 if it's not in the pool.
+func (p *ConnPool) slotOf(conn *Conn) int {
+	for i := range p.conns {
+		if p.loadConn(i) == conn {
+			return i
+		}
+	}
+	return -1
 }
 
 func (p *ConnPool) loadConn(shard int) *Conn {
@@ -113,13 +214,13 @@ func (p *ConnPool) clearConn(shard int) bool {
 }
 
 func (p *ConnPool) Close() {
-	p.connClosedCh <- poolCloseShard
+	p.connClosedCh <- nil
 }
 
 // closeAll is called by PoolRefiller.
 func (p *ConnPool) closeAll() {
 	for i := range p.conns {
-		if conn, ok := p.conns[i].Swap((*Conn)(nil)).(*Conn); ok {
+		if conn, _ := p.conns[i].Swap((*Conn)(nil)).(*Conn); conn != nil {
 			conn.Close()
 		}
 	}
@@ -130,6 +231,15 @@ type PoolRefiller struct {
 	pool   ConnPool
 	cfg    ConnConfig
 	active int
+	// reportedActive is the pool size last reported to metrics.
+	reportedActive int
+	// connClosedCh is created before the first connection is opened and then shared with pool.
+	connClosedCh chan *Conn
+
+	// shardAware is false when node does not advertise shard aware port,
+	// in that case connections are opened to the regular port and kept
+	// on whatever shard the server assigned them to.
+	shardAware bool
 }
 
 func (r *PoolRefiller) init(ctx context.Context, host string) error {
@@ -154,30 +264,41 @@ func (r *PoolRefiller) init(ctx context.Context, host string) error {
 	}
 
 	ss := s.ScyllaSupported()
+	if ss.NrShards == 0 {
+		r.initNonSharded(host, conn, span)
+		return nil
+	}
+
+	portOption := ScyllaShardAwarePort
 	if r.cfg.TLSConfig != nil {
-		if v, ok := s.Options[ScyllaShardAwarePortSSL]; ok {
-			r.addr = net.JoinHostPort(host, v[0])
-		} else {
-			return fmt.Errorf("missing encrypted shard aware port information %v", s.Options)
-		}
+		portOption = ScyllaShardAwarePortSSL
+	}
+	if v, ok := s.Options[portOption]; ok {
+		r.addr = net.JoinHostPort(host, v[0])
+		r.shardAware = true
 	} else {
-		if v, ok := s.Options[ScyllaShardAwarePort]; ok {
-			r.addr = net.JoinHostPort(host, v[0])
-		} else {
-			return fmt.Errorf("missing shard aware port information %v", s.Options)
-		}
+		r.cfg.Logger.Info("missing shard aware port information, falling back to non shard aware connections",
+			log.Node(host), log.String("option", portOption))
+		r.addr = host
+		r.shardAware = false
 	}
 
+	connsPerShard := r.cfg.ConnsPerShard
+	if connsPerShard < 1 {
+		connsPerShard = 1
+	}
+	size := int(ss.NrShards) * connsPerShard
 	r.pool = ConnPool{
-		host:         host,
-		nrShards:     int(ss.NrShards),
-		msbIgnore:    ss.MsbIgnore,
-		conns:        make([]atomic.Value, int(ss.NrShards)),
-		connClosedCh: make(chan int, int(ss.NrShards)+1),
-		connObs:      r.cfg.ConnObserver,
+		host:          host,
+		nrShards:      int(ss.NrShards),
+		msbIgnore:     ss.MsbIgnore,
+		connsPerShard: connsPerShard,
+		conns:         make([]atomic.Value, size),
+		connClosedCh:  r.connClosedCh,
+		connObs:       r.cfg.ConnObserver,
+		sharded:       true,
 	}
 
-	conn.setOnClose(r.onConnClose)
 	r.pool.storeConn(conn)
 	r.active = 1
 	if r.pool.connObs != nil {
@@ -187,57 +308,143 @@ func (r *PoolRefiller) init(ctx context.Context, host string) error {
 	return nil
 }
 
+// initNonSharded sets up pool for servers without sharding information,
+// it keeps ConnsPerHost connections to the regular port.
+func (r *PoolRefiller) initNonSharded(host string, conn *Conn, span span) {
+	size := r.cfg.ConnsPerHost
+	if size < 1 {
+		size = 1
+	}
+	r.cfg.Logger.Info("no sharding information, using non sharded pool", log.Node(host), log.Int("size", size))
+
+	r.addr = host
+	r.pool = ConnPool{
+		host:         host,
+		conns:        make([]atomic.Value, size),
+		connClosedCh: r.connClosedCh,
+		connObs:      r.cfg.ConnObserver,
+	}
+
+	r.pool.storeConnAt(0, conn)
+	r.active = 1
+	if r.pool.connObs != nil {
+		r.pool.connObs.OnConnect(ConnectEvent{ConnEvent: conn.Event(), span: span})
+	}
+}
+
+// onConnClose is called by closed connections, it may be called before the connection is stored in the pool.
 func (r *PoolRefiller) onConnClose(conn *Conn) {
 	select {
-	case r.pool.connClosedCh <- conn.Shard():
+	case r.connClosedCh <- conn:
 	default:
-		r.cfg.Logger.Infof("conn pool: ignoring conn %s close", conn)
+		r.cfg.Logger.Info("conn pool: ignoring conn close", conn.Event().logAttrs()...)
 	}
 }
 
+// fillBackoff is the interval of checking if pool needs filling, when it's not reconnecting.
 const fillBackoff = time.Second
 
 func (r *PoolRefiller) loop(ctx context.Context) {
+	rs := newReconnectionSchedule(r.cfg.ReconnectionPolicy)
 	r.fill(ctx)
+	r.reportPoolSize()
 
-	ticker := time.NewTicker(fillBackoff)
-	defer ticker.Stop()
+	timer := time.NewTimer(r.nextFillDelay(rs))
+	defer timer.Stop()
 	for {
 		select {
 		case <-ctx.Done():
-			r.pool.closeAll()
+			r.closeAll()
 			return
-		case <-ticker.C:
+		case <-timer.C:
 			r.fill(ctx)
-		case shard := <-r.pool.connClosedCh:
-			if shard == poolCloseShard {
-				r.pool.closeAll()
+			r.reportPoolSize()
+			timer.Reset(r.nextFillDelay(rs))
+		case conn := <-r.connClosedCh:
+			if conn == nil {
+				r.closeAll()
 				return
 			}
-			if r.pool.clearConn(shard) {
+			if slot := r.pool.slotOf(conn); slot >= 0 && r.pool.clearConn(slot) {
 				r.active--
 			}
-			r.fill(ctx)
+			// When previous attempts failed we wait for the scheduled one
+			// instead of reconnecting right away.
+			if r.pool.reconnectAttempts.Load() == 0 {
+				r.fill(ctx)
+				if !timer.Stop() {
+					select {
+					case <-timer.C:
+					default:
+					}
+				}
+				timer.Reset(r.nextFillDelay(rs))
+			}
+			r.reportPoolSize()
 		}
 	}
 }
 
+func (r *PoolRefiller) closeAll() {
+	r.pool.closeAll()
+	r.active = 0
+	r.reportPoolSize()
+}
+
+// reportPoolSize passes pool size to metrics if it changed since the last call.
+func (r *PoolRefiller) reportPoolSize() {
+	if r.cfg.Metrics == nil || r.active == r.reportedActive {
+		return
+	}
+	r.reportedActive = r.active
+	r.cfg.Metrics.OnPoolSize(PoolSizeEvent{
+		Addr:     r.pool.host,
+		Size:     r.active,
+		Capacity: len(r.pool.conns),
+	})
+}
+
+// nextFillDelay updates reconnection state of the pool and returns delay before the next fill.
+func (r *PoolRefiller) nextFillDelay(rs ReconnectionSchedule) time.Duration {
+	if !r.needsFilling() {
+		rs.Reset()
+		r.pool.reconnectAttempts.Store(0)
+		r.pool.reconnectDelay.Store(0)
+		return fillBackoff
+	}
+
+	d := rs.NextDelay()
+	r.pool.reconnectAttempts.Inc()
+	r.pool.reconnectDelay.Store(d)
+	return d
+}
+
 func (r *PoolRefiller) fill(ctx context.Context) {
+	r.removeClosed()
 	if !r.needsFilling() {
 		return
 	}
 
+	if !r.pool.sharded {
+		r.fillNonSharded(ctx)
+		return
+	}
+	if !r.shardAware {
+		r.fillNonShardAware(ctx)
+		return
+	}
+
 	si := ShardInfo{
 		NrShards:  uint16(r.pool.nrShards),
 		MsbIgnore: r.pool.msbIgnore,
 	}
 
-	for i := 0; i < r.pool.nrShards; i++ {
+	for i := range r.pool.conns {
 		if r.pool.loadConn(i) != nil {
 			continue
 		}
 
-		si.Shard = uint16(i)
+		si.Shard = uint16(i / r.pool.connsPerShard)
 		span := startSpan()
 		conn, err := OpenShardConn(ctx, r.addr, si, r.cfg)
 		span.stop()
@@ -254,11 +461,19 @@ func (r *PoolRefiller) fill(ctx context.Context) {
 			r.pool.connObs.OnConnect(ConnectEvent{ConnEvent: conn.Event(), span: span})
 		}
 
-		if conn.Shard() != i {
-			log.Fatalf("opened conn to wrong shard: expected %d got %d", i, conn.Shard())
+		// Dialer may not be able to control local port, e.g. when connecting through a proxy.
+		if conn.Shard() != int(si.Shard) {
+			r.cfg.Logger.Warn("opened conn to wrong shard, falling back to non shard aware connections",
+				log.Node(r.pool.host), log.Int("expected_shard", int(si.Shard)), log.Shard(conn.Shard()))
+			r.shardAware = false
+			if r.pool.storeConn(conn) {
+				r.active++
+			} else {
+				conn.Close()
+			}
+			return
 		}
-		conn.setOnClose(r.onConnClose)
-		r.pool.storeConn(conn)
+		r.pool.storeConnAt(i, conn)
 		r.active++
 
 		if !r.needsFilling() {
@@ -267,6 +482,80 @@ func (r *PoolRefiller) fill(ctx context.Context) {
 	}
 }
 
+func (r *PoolRefiller) fillNonSharded(ctx context.Context) {
+	for i := range r.pool.conns {
+		if r.pool.loadConn(i) != nil {
+			continue
+		}
+
+		span := startSpan()
+		conn, err := OpenConn(ctx, r.addr, nil, r.cfg)
+		span.stop()
+		if err != nil {
+			if r.pool.connObs != nil {
+				r.pool.connObs.OnConnect(ConnectEvent{ConnEvent: ConnEvent{Addr: r.addr, Shard: UnknownShard}, span: span, Err: err})
+			}
+			if conn != nil {
+				conn.Close()
+			}
+			continue
+		}
+		if r.pool.connObs != nil {
+			r.pool.connObs.OnConnect(ConnectEvent{ConnEvent: conn.Event(), span: span})
+		}
+
+		r.pool.storeConnAt(i, conn)
+		r.active++
+	}
+}
+
+// fillAttemptsPerShard bounds the number of connections opened during
+// a single non shard aware fill, relative to the number of shards.
+const fillAttemptsPerShard = 3
+
+// fillNonShardAware opens connections to the regular port until every shard is covered,
+// server picks the shard, so connections landing on already covered shards are closed.
+func (r *PoolRefiller) fillNonShardAware(ctx context.Context) {
+	maxTries := fillAttemptsPerShard * len(r.pool.conns)
+	for i := 0; i < maxTries && r.needsFilling(); i++ {
+		span := startSpan()
+		conn, err := OpenConn(ctx, r.addr, nil, r.cfg)
+		span.stop()
+		if err != nil {
+			if r.pool.connObs != nil {
+				r.pool.connObs.OnConnect(ConnectEvent{ConnEvent: ConnEvent{Addr: r.addr, Shard: UnknownShard}, span: span, Err: err})
+			}
+			if conn != nil {
+				conn.Close()
+			}
+			continue
+		}
+		if r.pool.connObs != nil {
+			r.pool.connObs.OnConnect(ConnectEvent{ConnEvent: conn.Event(), span: span})
+		}
+
+		if !r.pool.storeConn(conn) {
+			conn.Close()
+			continue
+		}
+		r.active++
+	}
+
+	if r.needsFilling() {
+		r.cfg.Logger.Info("pool not filled", log.Node(r.pool.host), log.Int("active", r.active),
+			log.Int("size", len(r.pool.conns)), log.Int("attempts", maxTries))
+	}
+}
+
+// removeClosed removes closed connections whose close notifications were dropped.
+func (r *PoolRefiller) removeClosed() {
+	for i := range r.pool.conns {
+		if conn := r.pool.loadConn(i); conn != nil && conn.isClosed() && r.pool.clearConn(i) {
+			r.active--
+		}
+	}
+}
+
 func (r *PoolRefiller) needsFilling() bool {
-	return r.active < r.pool.nrShards
+	return r.active < len(r.pool.conns)
 }
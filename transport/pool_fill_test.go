@@ -0,0 +1,100 @@
+package transport_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/scylladb/scylla-go-driver/frame"
+	. "github.com/scylladb/scylla-go-driver/frame/response"
+	"github.com/scylladb/scylla-go-driver/scyllatest"
+	"github.com/scylladb/scylla-go-driver/transport"
+)
+
+func TestPoolNonShardAware(t *testing.T) {
+	t.Parallel()
+	const nrShards = 3
+
+	testCases := []struct {
+		name        string
+		unreachable []int
+		// dials is the expected number of dials of pool init and the first fill.
+		dials int
+	}{
+		{
+			name:  "every shard is covered",
+			dials: nrShards,
+		},
+		{
+			name:        "fill attempts are bounded",
+			unreachable: []int{1},
+			dials:       1 + 3*nrShards,
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			scfg := scyllatest.DefaultConfig(1)
+			scfg.NrShards = nrShards
+			scfg.ShardAwarePort = 0
+			srv := scyllatest.NewServer(scfg)
+			defer srv.Close()
+
+			fi := scyllatest.NewFaultInjector(srv, 0)
+			if tc.unreachable != nil {
+				fi.Add(scyllatest.Rule{
+					Shards:  tc.unreachable,
+					OpCodes: []frame.OpCode{frame.OpStartup},
+					Err:     ScyllaError{Code: frame.ErrCodeServer, Message: "unreachable"},
+				})
+			}
+			d := newDialRecorder(fi)
+			cfg := srv.ConnConfig()
+			cfg.Dialer = d
+			// Failed fill is not repeated during the test.
+			cfg.ReconnectionPolicy = transport.NewExponentialReconnectionPolicy(time.Minute, time.Minute)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			pool, err := transport.NewConnPool(ctx, scfg.Nodes[0].Addr, cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer pool.Close()
+
+			covered := func() map[int]bool {
+				m := make(map[int]bool)
+				for s := 0; s < nrShards; s++ {
+					if conn, err := pool.ShardConn(s); err == nil && conn.Shard() == s {
+						m[s] = true
+					}
+				}
+				return m
+			}
+			expected := nrShards - len(tc.unreachable)
+			deadline := time.Now().Add(5 * time.Second)
+			for len(covered()) < expected || d.total() < tc.dials {
+				if time.Now().After(deadline) {
+					t.Fatalf("pool not filled, covered shards %v, dials %d", covered(), d.total())
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+
+			// Give the refiller time to make unexpected attempts.
+			time.Sleep(100 * time.Millisecond)
+			if m := covered(); len(m) != expected {
+				t.Fatalf("expected %d covered shards, got %v", expected, m)
+			}
+			for _, s := range tc.unreachable {
+				if covered()[s] {
+					t.Fatalf("unreachable shard %d is covered", s)
+				}
+			}
+			if v := d.total(); v != tc.dials {
+				t.Fatalf("expected %d dials, got %d", tc.dials, v)
+			}
+		})
+	}
+}
@@ -0,0 +1,174 @@
+package transport
+
+import (
+	"math"
+	"testing"
+
+	"go.uber.org/atomic"
+)
+
+func TestConnPoolNonShardedRoundRobin(t *testing.T) {
+	t.Parallel()
+
+	conns := []*Conn{{}, {}, {}}
+	p := ConnPool{
+		host:  "test",
+		conns: make([]atomic.Value, len(conns)+1),
+	}
+	for i, c := range conns {
+		p.storeConnAt(i, c)
+	}
+
+	// Empty slot must be skipped and every stored connection must be picked.
+	picked := make(map[*Conn]int)
+	for i := 0; i < 3*len(conns); i++ {
+		conn, err := p.Conn(0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		picked[conn]++
+	}
+	for i, c := range conns {
+		if picked[c] == 0 {
+			t.Fatalf("conn %d was never picked: %v", i, picked)
+		}
+	}
+
+	if idx := p.slotOf(conns[1]); idx != 1 {
+		t.Fatalf("slotOf returned %d, expected 1", idx)
+	}
+	if idx := p.slotOf(&Conn{}); idx != -1 {
+		t.Fatalf("slotOf returned %d for unknown conn, expected -1", idx)
+	}
+
+	for i := range conns {
+		p.clearConn(i)
+	}
+	if _, err := p.Conn(0); err == nil {
+		t.Fatal("expected error for empty pool")
+	}
+}
+
+func TestConnPoolConnsPerShard(t *testing.T) {
+	t.Parallel()
+
+	newConn := func(shard uint16, waiting uint32) *Conn {
+		c := &Conn{stats: new(stats)}
+		c.shard.Store(uint32(shard))
+		c.stats.inFlight.Store(waiting)
+		return c
+	}
+
+	p := ConnPool{
+		host:          "test",
+		nrShards:      1,
+		connsPerShard: 3,
+		conns:         make([]atomic.Value, 3),
+		sharded:       true,
+	}
+
+	busy := newConn(0, 100)
+	idle := newConn(0, 10)
+	for _, c := range []*Conn{busy, idle} {
+		if !p.storeConn(c) {
+			t.Fatalf("failed to store conn %v", c)
+		}
+	}
+
+	if conn, err := p.Conn(0); err != nil || conn != idle {
+		t.Fatalf("expected least busy conn of the shard, got %v, %v", conn, err)
+	}
+
+	if !p.storeConn(newConn(0, 0)) {
+		t.Fatal("failed to store conn in the last free slot")
+	}
+	if p.storeConn(newConn(0, 0)) {
+		t.Fatal("stored conn in a full shard")
+	}
+	if p.storeConn(newConn(1, 0)) {
+		t.Fatal("stored conn to a shard out of range")
+	}
+}
+
+func TestPoolRefillerRemoveClosed(t *testing.T) {
+	t.Parallel()
+
+	open, closed := &Conn{closed: make(chan struct{})}, &Conn{closed: make(chan struct{})}
+	close(closed.closed)
+	r := PoolRefiller{
+		pool:   ConnPool{conns: make([]atomic.Value, 3)},
+		active: 2,
+	}
+	r.pool.storeConnAt(0, open)
+	r.pool.storeConnAt(2, closed)
+
+	// Connection closed before being stored in the pool has no slot when its notification is handled,
+	// it must be removed by the next fill.
+	r.removeClosed()
+	if r.active != 1 || r.pool.loadConn(0) != open || r.pool.loadConn(2) != nil {
+		t.Fatalf("closed connection not removed, active %d", r.active)
+	}
+}
+
+type pickRecorder struct {
+	replaced int
+}
+
+func (r *pickRecorder) OnConnect(ConnectEvent) {}
+
+func (r *pickRecorder) OnPickReplacedWithLessBusyConn(ConnEvent) {
+	r.replaced++
+}
+
+func TestConnPoolWaiting(t *testing.T) {
+	t.Parallel()
+
+	newConn := func(shard uint16, waiting uint32) *Conn {
+		c := &Conn{stats: new(stats)}
+		c.shard.Store(uint32(shard))
+		c.stats.inFlight.Store(waiting)
+		return c
+	}
+
+	obs := &pickRecorder{}
+	p := ConnPool{
+		host:          "test",
+		nrShards:      3,
+		connsPerShard: 1,
+		conns:         make([]atomic.Value, 3),
+		sharded:       true,
+		connObs:       obs,
+	}
+	for _, c := range []*Conn{newConn(0, maxStreamID), newConn(1, 10)} {
+		if !p.storeConn(c) {
+			t.Fatalf("failed to store conn %v", c)
+		}
+	}
+
+	for _, tc := range []struct {
+		shard    int
+		expected int
+	}{
+		{shard: 0, expected: maxStreamID},
+		{shard: 1, expected: 10},
+		// Shards without connections and unknown shards use the least busy connection.
+		{shard: 2, expected: 10},
+		{shard: -1, expected: 10},
+	} {
+		if w := p.waiting(tc.shard); w != tc.expected {
+			t.Fatalf("waiting(%d) = %d, expected %d", tc.shard, w, tc.expected)
+		}
+	}
+
+	// Heavily loaded connection is not replaced, observer is not notified.
+	if obs.replaced != 0 {
+		t.Fatalf("OnPickReplacedWithLessBusyConn called %d times", obs.replaced)
+	}
+
+	n := &Node{pool: &p}
+	n.setStatus(statusUP)
+	// Token math.MinInt64 belongs to shard 0 with the heavily loaded connection.
+	if l := nodeLoad(n, QueryInfo{tokenAware: true, token: math.MinInt64}); l != maxStreamID || obs.replaced != 0 {
+		t.Fatalf("nodeLoad = %d, replaced = %d", l, obs.replaced)
+	}
+}
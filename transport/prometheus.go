@@ -0,0 +1,171 @@
+package transport
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/scylladb/scylla-go-driver/frame"
+)
+
+const prometheusPrefix = "scylla_driver_"
+
+// NewPrometheusHandler returns http.Handler serving metrics collected by m
+// in the Prometheus text exposition format.
+func NewPrometheusHandler(m *MemoryMetrics) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		WritePrometheus(w, m.Snapshot())
+	})
+}
+
+// WritePrometheus writes snapshot in the Prometheus text exposition format.
+func WritePrometheus(w io.Writer, s MetricsSnapshot) error {
+	p := promWriter{w: bufio.NewWriter(w)}
+
+	shards := make([]NodeShard, 0, len(s.Shards))
+	for k := range s.Shards {
+		shards = append(shards, k)
+	}
+	sort.Slice(shards, func(i, j int) bool {
+		if shards[i].Addr != shards[j].Addr {
+			return shards[i].Addr < shards[j].Addr
+		}
+		return shards[i].Shard < shards[j].Shard
+	})
+
+	p.help("request_latency_seconds", "histogram", "Latency of requests.")
+	for _, k := range shards {
+		p.histogram("request_latency_seconds", shardLabels(k), s.Shards[k].Latency)
+	}
+	p.help("requests_total", "counter", "Number of requests that got a response.")
+	for _, k := range shards {
+		p.sample("requests_total", shardLabels(k), float64(s.Shards[k].Requests))
+	}
+	p.help("shard_errors_total", "counter", "Number of requests that failed.")
+	for _, k := range shards {
+		p.sample("shard_errors_total", shardLabels(k), float64(s.Shards[k].Errors))
+	}
+	p.help("shard_timeouts_total", "counter", "Number of requests abandoned due to timeout.")
+	for _, k := range shards {
+		p.sample("shard_timeouts_total", shardLabels(k), float64(s.Shards[k].Timeouts))
+	}
+	p.help("streams_in_use", "gauge", "Number of requests in flight.")
+	for _, k := range shards {
+		p.sample("streams_in_use", shardLabels(k), float64(s.Shards[k].StreamsInUse))
+	}
+
+	codes := make([]frame.ErrorCode, 0, len(s.Errors))
+	for k := range s.Errors {
+		codes = append(codes, k)
+	}
+	sort.Slice(codes, func(i, j int) bool { return codes[i] < codes[j] })
+	p.help("request_errors_total", "counter", "Number of failed requests by error code.")
+	for _, k := range codes {
+		code := "other"
+		if k != ErrCodeOther {
+			code = fmt.Sprintf("0x%04x", int(k))
+		}
+		p.sample("request_errors_total", []string{"code", code}, float64(s.Errors[k]))
+	}
+
+	p.help("timeouts_total", "counter", "Number of requests abandoned due to timeout.")
+	p.sample("timeouts_total", nil, float64(s.Timeouts))
+	p.help("retries_total", "counter", "Number of retried requests.")
+	p.sample("retries_total", nil, float64(s.Retries))
+
+	pools := make([]string, 0, len(s.Pools))
+	for k := range s.Pools {
+		pools = append(pools, k)
+	}
+	sort.Strings(pools)
+	p.help("pool_connections", "gauge", "Number of open connections.")
+	for _, k := range pools {
+		p.sample("pool_connections", []string{"node", k}, float64(s.Pools[k].Size))
+	}
+	p.help("pool_capacity", "gauge", "Number of connections the pool keeps open.")
+	for _, k := range pools {
+		p.sample("pool_capacity", []string{"node", k}, float64(s.Pools[k].Capacity))
+	}
+
+	p.help("bytes_sent_total", "counter", "Number of bytes sent.")
+	p.sample("bytes_sent_total", nil, float64(s.BytesSent))
+	p.help("bytes_received_total", "counter", "Number of bytes received.")
+	p.sample("bytes_received_total", nil, float64(s.BytesReceived))
+	p.help("uncompressed_bytes_sent_total", "counter", "Number of bytes sent before compression.")
+	p.sample("uncompressed_bytes_sent_total", nil, float64(s.UncompressedBytesSent))
+	p.help("uncompressed_bytes_received_total", "counter", "Number of bytes received after decompression.")
+	p.sample("uncompressed_bytes_received_total", nil, float64(s.UncompressedBytesReceived))
+	p.help("compression_ratio", "gauge", "Ratio of uncompressed to transferred bytes.")
+	p.sample("compression_ratio", nil, s.CompressionRatio())
+
+	p.help("topology_refreshes_total", "counter", "Number of topology refreshes.")
+	p.sample("topology_refreshes_total", nil, float64(s.TopologyRefreshes))
+	p.help("topology_refresh_errors_total", "counter", "Number of failed topology refreshes.")
+	p.sample("topology_refresh_errors_total", nil, float64(s.TopologyRefreshErrors))
+	p.help("topology_refresh_latency_seconds", "histogram", "Duration of topology refreshes.")
+	p.histogram("topology_refresh_latency_seconds", nil, s.TopologyRefreshLatency)
+
+	return p.w.Flush()
+}
+
+func shardLabels(k NodeShard) []string {
+	shard := "unknown"
+	if k.Shard != UnknownShard {
+		shard = strconv.Itoa(int(k.Shard))
+	}
+	return []string{"node", k.Addr, "shard", shard}
+}
+
+// labelValueEscaper escapes label values as required by the text exposition format.
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+type promWriter struct {
+	w *bufio.Writer
+}
+
+func (p promWriter) help(name, typ, help string) {
+	fmt.Fprintf(p.w, "# HELP %s%s %s\n# TYPE %s%s %s\n", prometheusPrefix, name, help, prometheusPrefix, name, typ)
+}
+
+// sample writes a single sample, labels are name value pairs.
+func (p promWriter) sample(name string, labels []string, v float64) {
+	p.w.WriteString(prometheusPrefix)
+	p.w.WriteString(name)
+	if len(labels) > 0 {
+		p.w.WriteByte('{')
+		for i := 0; i < len(labels); i += 2 {
+			if i > 0 {
+				p.w.WriteByte(',')
+			}
+			p.w.WriteString(labels[i])
+			p.w.WriteString("=\"")
+			labelValueEscaper.WriteString(p.w, labels[i+1])
+			p.w.WriteByte('"')
+		}
+		p.w.WriteByte('}')
+	}
+	p.w.WriteByte(' ')
+	p.w.WriteString(strconv.FormatFloat(v, 'g', -1, 64))
+	p.w.WriteByte('\n')
+}
+
+func (p promWriter) histogram(name string, labels []string, h Histogram) {
+	var cum uint64
+	for i, b := range h.Buckets {
+		cum += h.Counts[i]
+		p.sample(name+"_bucket", append(labels[:len(labels):len(labels)], "le", seconds(b)), float64(cum))
+	}
+	p.sample(name+"_bucket", append(labels[:len(labels):len(labels)], "le", "+Inf"), float64(h.Count))
+	p.sample(name+"_sum", labels, h.Sum.Seconds())
+	p.sample(name+"_count", labels, float64(h.Count))
+}
+
+func seconds(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds(), 'g', -1, 64)
+}
@@ -19,6 +19,14 @@ type Statement struct {
 	Compression       bool
 	Idempotent        bool
 	Metadata          *frame.ResultMetadata
+	// BindMetadata describes bound values of prepared statement.
+	BindMetadata *frame.PreparedMetadata
+	// Keyspace is the keyspace of prepared statement, it's used for token aware routing.
+	Keyspace string
+	// Table is the table of prepared statement, it's used for tablet aware routing.
+	Table string
+	// CustomPayload is sent with the request, server ignores unknown keys.
+	CustomPayload frame.BytesMap
 }
 
 // Clone makes new Values to avoid data overwrite in binding.
@@ -31,6 +39,12 @@ func (s Statement) Clone() Statement {
 			c.Values[i] = s.Values[i].Clone()
 		}
 	}
+	if s.CustomPayload != nil {
+		c.CustomPayload = make(frame.BytesMap, len(s.CustomPayload))
+		for k, v := range s.CustomPayload {
+			c.CustomPayload[k] = v
+		}
+	}
 	return c
 }
 
@@ -76,6 +90,13 @@ type QueryResult struct {
 	PagingState  frame.Bytes
 	ColSpec      []frame.ColumnSpec
 	SchemaChange *SchemaChange
+	// Downgraded is set if retry policy lowered consistency of the query,
+	// Consistency is then the consistency of the successful attempt.
+	Downgraded  bool
+	Consistency frame.Consistency
+	// Tablet is the tablet routing information sent by Scylla if the request was sent
+	// to a node or shard that doesn't own the data, it should be passed to Cluster.AddTablet.
+	Tablet *Tablet
 }
 
 func MakeQueryResult(res frame.Response, meta *frame.ResultMetadata) (QueryResult, error) {
@@ -103,3 +124,20 @@ func MakeQueryResult(res frame.Response, meta *frame.ResultMetadata) (QueryResul
 		return QueryResult{}, responseAsError(res)
 	}
 }
+
+// makeQueryResult is like MakeQueryResult but it also sets tracing ID and warnings sent with the response.
+func makeQueryResult(res response, meta *frame.ResultMetadata) (QueryResult, error) {
+	ret, err := MakeQueryResult(res.Response, meta)
+	if err != nil {
+		return QueryResult{}, err
+	}
+	ret.TracingID = res.TracingID
+	ret.Warnings = res.Warnings
+	if v, ok := res.CustomPayload[tabletsRoutingKey]; ok {
+		// Malformed routing information is ignored, it only affects performance.
+		if t, err := ParseTablet(v); err == nil {
+			ret.Tablet = &t
+		}
+	}
+	return ret, nil
+}
@@ -0,0 +1,101 @@
+package transport
+
+import (
+	"math/rand"
+	"time"
+)
+
+// ReconnectionPolicy decides how long to wait between consecutive attempts
+// to reconnect to a node or to reopen control connection.
+type ReconnectionPolicy interface {
+	NewSchedule() ReconnectionSchedule
+}
+
+// ReconnectionSchedule should be used for just one reconnecting entity (connection pool, control connection).
+// It should be reset after successful reconnection.
+type ReconnectionSchedule interface {
+	// NextDelay returns the delay before the next reconnection attempt.
+	NextDelay() time.Duration
+	Reset()
+}
+
+type ConstantReconnectionPolicy struct {
+	Interval time.Duration
+}
+
+func NewConstantReconnectionPolicy(interval time.Duration) ReconnectionPolicy {
+	return &ConstantReconnectionPolicy{Interval: interval}
+}
+
+func (p *ConstantReconnectionPolicy) NewSchedule() ReconnectionSchedule {
+	return constantReconnectionSchedule{interval: p.Interval}
+}
+
+type constantReconnectionSchedule struct {
+	interval time.Duration
+}
+
+func (s constantReconnectionSchedule) NextDelay() time.Duration {
+	return s.interval
+}
+
+func (constantReconnectionSchedule) Reset() {}
+
+// ExponentialReconnectionPolicy doubles the delay after every failed attempt starting from Base,
+// up to Max. Delays are randomized to the range [delay/2, delay] to spread reconnections of
+// multiple clients in time.
+type ExponentialReconnectionPolicy struct {
+	Base time.Duration
+	Max  time.Duration
+}
+
+func NewExponentialReconnectionPolicy(base, max time.Duration) ReconnectionPolicy {
+	return &ExponentialReconnectionPolicy{Base: base, Max: max}
+}
+
+func (p *ExponentialReconnectionPolicy) NewSchedule() ReconnectionSchedule {
+	return &exponentialReconnectionSchedule{
+		base: p.Base,
+		max:  p.Max,
+	}
+}
+
+type exponentialReconnectionSchedule struct {
+	base    time.Duration
+	max     time.Duration
+	attempt int
+}
+
+func (s *exponentialReconnectionSchedule) NextDelay() time.Duration {
+	d := s.base
+	for i := 0; i < s.attempt && d < s.max; i++ {
+		d <<= 1
+	}
+	if d > s.max {
+		d = s.max
+	}
+	s.attempt++
+
+	if half := int64(d / 2); half > 0 {
+		d = time.Duration(half + rand.Int63n(half+1))
+	}
+	return d
+}
+
+func (s *exponentialReconnectionSchedule) Reset() {
+	s.attempt = 0
+}
+
+const (
+	defaultReconnectionBase = time.Second
+	defaultReconnectionMax  = time.Minute
+)
+
+// newReconnectionSchedule returns schedule of p, or constant schedule
+// with fillBackoff interval if p is not set.
+func newReconnectionSchedule(p ReconnectionPolicy) ReconnectionSchedule {
+	if p == nil {
+		return constantReconnectionSchedule{interval: fillBackoff}
+	}
+	return p.NewSchedule()
+}
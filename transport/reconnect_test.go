@@ -0,0 +1,44 @@
+package transport
+
+import (
+	"testing"
+	"time"
+)
+
+func TestConstantReconnectionPolicy(t *testing.T) {
+	t.Parallel()
+
+	s := NewConstantReconnectionPolicy(time.Second).NewSchedule()
+	for i := 0; i < 5; i++ {
+		if d := s.NextDelay(); d != time.Second {
+			t.Fatalf("attempt %d: got delay %s, expected %s", i, d, time.Second)
+		}
+	}
+}
+
+func TestExponentialReconnectionPolicy(t *testing.T) {
+	t.Parallel()
+
+	const (
+		base = 100 * time.Millisecond
+		max  = time.Second
+	)
+	s := NewExponentialReconnectionPolicy(base, max).NewSchedule()
+
+	// Upper bounds of consecutive delays.
+	expected := []time.Duration{base, 2 * base, 4 * base, 8 * base, max, max}
+
+	check := func(t *testing.T, d, max time.Duration) {
+		t.Helper()
+		if d < max/2 || d > max {
+			t.Fatalf("got delay %s, expected delay in range [%s, %s]", d, max/2, max)
+		}
+	}
+
+	for _, v := range expected {
+		check(t, s.NextDelay(), v)
+	}
+
+	s.Reset()
+	check(t, s.NextDelay(), base)
+}
@@ -0,0 +1,320 @@
+package transport
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/scylladb/scylla-go-driver/frame"
+
+	"go.uber.org/atomic"
+)
+
+// FrameDirection tells if frame was sent or received by the driver.
+type FrameDirection byte
+
+const (
+	FrameSent FrameDirection = iota + 1
+	FrameReceived
+)
+
+func (d FrameDirection) String() string {
+	switch d {
+	case FrameSent:
+		return "sent"
+	case FrameReceived:
+		return "received"
+	default:
+		return fmt.Sprintf("FrameDirection(%d)", byte(d))
+	}
+}
+
+// FrameRecord is a single frame sent or received on a connection.
+// Body is always uncompressed, Header.Flags doesn't have frame.Compress set
+// and Header.Length is the length of the uncompressed body.
+// Body of AUTH_RESPONSE frames is empty, so that credentials are not recorded.
+type FrameRecord struct {
+	// ConnID identifies connection within process.
+	ConnID    uint64
+	Addr      string
+	Direction FrameDirection
+	Time      time.Time
+	Header    frame.Header
+	Body      []byte
+}
+
+// FrameRecorder records frames sent and received by connections, it is called from
+// connection reader and writer goroutines and must be safe for concurrent use.
+// Record must not block for long as it delays all requests on the connection.
+type FrameRecorder interface {
+	RecordFrame(r FrameRecord)
+}
+
+var connIDGen atomic.Uint64
+
+// recordFrame passes frame to recorder, body is copied.
+func (c *Conn) recordFrame(dir FrameDirection, h frame.Header, body []byte) {
+	if h.OpCode == frame.OpAuthResponse {
+		body = nil
+	}
+	h.Flags &^= frame.Compress
+	h.Length = frame.Int(len(body))
+	c.cfg.FrameRecorder.RecordFrame(FrameRecord{
+		ConnID:    c.id,
+		Addr:      c.addr,
+		Direction: dir,
+		Time:      time.Now(),
+		Header:    h,
+		Body:      append([]byte(nil), body...),
+	})
+}
+
+// Recording format:
+//
+//	recording: magic version record*
+//	record:    kind(byte) payload
+//	conn:      id(uvarint) addr_len(uvarint) addr  // kind 0, precedes frames of the connection
+//	frame:     conn_id(uvarint) time(varint) header(9 bytes) body  // kind FrameDirection
+//
+// Time of a frame is encoded as a difference in nanoseconds from the previous frame,
+// the first frame has Unix time.
+const (
+	recordingMagic   = "SGFR"
+	recordingVersion = 1
+	recordKindConn   = 0
+)
+
+var errBadRecording = errors.New("invalid recording")
+
+// FileRecorder is a FrameRecorder writing frames in a compact binary format,
+// recordings can be read with RecordReader.
+type FileRecorder struct {
+	mu    sync.Mutex
+	w     *bufio.Writer
+	c     io.Closer
+	conns map[uint64]struct{}
+	last  int64
+	tmp   []byte
+	err   error
+}
+
+var _ FrameRecorder = (*FileRecorder)(nil)
+
+// NewFileRecorder returns recorder writing to w, if w is an io.Closer it's closed by Close.
+func NewFileRecorder(w io.Writer) *FileRecorder {
+	r := &FileRecorder{
+		w:     bufio.NewWriter(w),
+		conns: make(map[uint64]struct{}),
+	}
+	if c, ok := w.(io.Closer); ok {
+		r.c = c
+	}
+	r.w.WriteString(recordingMagic)
+	r.w.WriteByte(recordingVersion)
+	return r
+}
+
+func (r *FileRecorder) RecordFrame(f FrameRecord) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return
+	}
+
+	b := r.tmp[:0]
+	if _, ok := r.conns[f.ConnID]; !ok {
+		r.conns[f.ConnID] = struct{}{}
+		b = append(b, recordKindConn)
+		b = appendUvarint(b, f.ConnID)
+		b = appendUvarint(b, uint64(len(f.Addr)))
+		b = append(b, f.Addr...)
+	}
+
+	t := f.Time.UnixNano()
+	b = append(b, byte(f.Direction))
+	b = appendUvarint(b, f.ConnID)
+	b = appendVarint(b, t-r.last)
+	r.last = t
+
+	var hb frame.Buffer
+	f.Header.WriteTo(&hb)
+	b = append(b, hb.Bytes()...)
+	b = append(b, f.Body...)
+	r.tmp = b
+
+	if _, err := r.w.Write(b); err != nil {
+		r.err = err
+	}
+}
+
+// Flush writes buffered records to the underlying writer.
+func (r *FileRecorder) Flush() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return r.err
+	}
+	r.err = r.w.Flush()
+	return r.err
+}
+
+// Close flushes recorder and closes the underlying writer, frames recorded after Close are dropped.
+func (r *FileRecorder) Close() error {
+	err := r.Flush()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err == nil {
+		r.err = io.ErrClosedPipe
+	}
+	if r.c != nil {
+		if cerr := r.c.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+func appendUvarint(b []byte, v uint64) []byte {
+	var tmp [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(tmp[:], v)
+	return append(b, tmp[:n]...)
+}
+
+func appendVarint(b []byte, v int64) []byte {
+	var tmp [binary.MaxVarintLen64]byte
+	n := binary.PutVarint(tmp[:], v)
+	return append(b, tmp[:n]...)
+}
+
+// RecordReader reads recordings written by FileRecorder.
+type RecordReader struct {
+	r     *bufio.Reader
+	addrs map[uint64]string
+	last  int64
+}
+
+func NewRecordReader(r io.Reader) (*RecordReader, error) {
+	rr := &RecordReader{
+		r:     bufio.NewReader(r),
+		addrs: make(map[uint64]string),
+	}
+
+	head := make([]byte, len(recordingMagic)+1)
+	if _, err := io.ReadFull(rr.r, head); err != nil {
+		return nil, fmt.Errorf("read recording header: %w", err)
+	}
+	if string(head[:len(recordingMagic)]) != recordingMagic {
+		return nil, fmt.Errorf("%w: bad magic", errBadRecording)
+	}
+	if v := head[len(recordingMagic)]; v != recordingVersion {
+		return nil, fmt.Errorf("%w: unsupported version %d", errBadRecording, v)
+	}
+	return rr, nil
+}
+
+// Next returns the next frame, it returns io.EOF at the end of recording.
+func (r *RecordReader) Next() (FrameRecord, error) {
+	for {
+		kind, err := r.r.ReadByte()
+		if err != nil {
+			return FrameRecord{}, err
+		}
+		if kind == recordKindConn {
+			if err := r.readConn(); err != nil {
+				return FrameRecord{}, r.unexpectedEOF(err)
+			}
+			continue
+		}
+		if kind != byte(FrameSent) && kind != byte(FrameReceived) {
+			return FrameRecord{}, fmt.Errorf("%w: unknown record kind %d", errBadRecording, kind)
+		}
+
+		f, err := r.readFrame(FrameDirection(kind))
+		return f, r.unexpectedEOF(err)
+	}
+}
+
+func (r *RecordReader) readConn() error {
+	id, err := binary.ReadUvarint(r.r)
+	if err != nil {
+		return err
+	}
+	n, err := binary.ReadUvarint(r.r)
+	if err != nil {
+		return err
+	}
+	addr := make([]byte, n)
+	if _, err := io.ReadFull(r.r, addr); err != nil {
+		return err
+	}
+	r.addrs[id] = string(addr)
+	return nil
+}
+
+func (r *RecordReader) readFrame(dir FrameDirection) (FrameRecord, error) {
+	f := FrameRecord{Direction: dir}
+
+	id, err := binary.ReadUvarint(r.r)
+	if err != nil {
+		return f, err
+	}
+	addr, ok := r.addrs[id]
+	if !ok {
+		return f, fmt.Errorf("%w: unknown connection %d", errBadRecording, id)
+	}
+	f.ConnID, f.Addr = id, addr
+
+	d, err := binary.ReadVarint(r.r)
+	if err != nil {
+		return f, err
+	}
+	r.last += d
+	f.Time = time.Unix(0, r.last)
+
+	head := make([]byte, frame.HeaderSize)
+	if _, err := io.ReadFull(r.r, head); err != nil {
+		return f, err
+	}
+	var hb frame.Buffer
+	hb.Write(head)
+	f.Header = frame.ParseHeader(&hb)
+	if f.Header.Length < 0 {
+		return f, fmt.Errorf("%w: negative frame length", errBadRecording)
+	}
+
+	f.Body = make([]byte, f.Header.Length)
+	if _, err := io.ReadFull(r.r, f.Body); err != nil {
+		return f, err
+	}
+	return f, nil
+}
+
+func (r *RecordReader) unexpectedEOF(err error) error {
+	if errors.Is(err, io.EOF) {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// ReadRecording reads all frames from the recording.
+func ReadRecording(r io.Reader) ([]FrameRecord, error) {
+	rr, err := NewRecordReader(r)
+	if err != nil {
+		return nil, err
+	}
+	var res []FrameRecord
+	for {
+		f, err := rr.Next()
+		if errors.Is(err, io.EOF) {
+			return res, nil
+		}
+		if err != nil {
+			return res, err
+		}
+		res = append(res, f)
+	}
+}
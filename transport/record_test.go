@@ -0,0 +1,97 @@
+package transport
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/scylladb/scylla-go-driver/frame"
+	. "github.com/scylladb/scylla-go-driver/frame/request"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestFileRecorderRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	start := time.Unix(1700000000, 0)
+	records := []FrameRecord{
+		{
+			ConnID:    1,
+			Addr:      "127.0.0.1:9042",
+			Direction: FrameSent,
+			Time:      start,
+			Header:    frame.Header{Version: frame.CQLv4, StreamID: 1, OpCode: frame.OpOptions},
+			Body:      []byte{},
+		},
+		{
+			ConnID:    2,
+			Addr:      "127.0.0.2:19042",
+			Direction: FrameSent,
+			Time:      start.Add(time.Millisecond),
+			Header:    frame.Header{Version: frame.CQLv4, StreamID: 5, OpCode: frame.OpQuery, Length: 3},
+			Body:      []byte{1, 2, 3},
+		},
+		{
+			ConnID:    1,
+			Addr:      "127.0.0.1:9042",
+			Direction: FrameReceived,
+			// Frames may be recorded out of order.
+			Time:   start.Add(time.Microsecond),
+			Header: frame.Header{Version: 0x84, StreamID: 1, OpCode: frame.OpSupported, Length: 2},
+			Body:   []byte{0, 0},
+		},
+	}
+
+	var buf bytes.Buffer
+	r := NewFileRecorder(&buf)
+	for _, f := range records {
+		r.RecordFrame(f)
+	}
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := ReadRecording(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(records, out); diff != "" {
+		t.Fatal(diff)
+	}
+
+	// Truncated recording.
+	_, err = ReadRecording(bytes.NewReader(buf.Bytes()[:buf.Len()-1]))
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("expected unexpected EOF, got %v", err)
+	}
+}
+
+func TestRecordFrameAuthResponse(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	rec := NewFileRecorder(&buf)
+	c := &Conn{cfg: ConnConfig{Username: "cassandra", Password: "s3cr3t-pa55", FrameRecorder: rec}}
+
+	var b frame.Buffer
+	req := AuthResponse{Username: c.cfg.Username, Password: c.cfg.Password}
+	req.WriteTo(&b)
+	c.recordFrame(FrameSent, frame.Header{Version: frame.CQLv4, OpCode: frame.OpAuthResponse, Length: frame.Int(len(b.Bytes()))}, b.Bytes())
+	if err := rec.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if bytes.Contains(buf.Bytes(), []byte(c.cfg.Password)) {
+		t.Fatal("recording contains password")
+	}
+	out, err := ReadRecording(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != 1 || out[0].Header.OpCode != frame.OpAuthResponse || out[0].Header.Length != 0 || len(out[0].Body) != 0 {
+		t.Fatalf("expected AUTH_RESPONSE with empty body, got %+v", out)
+	}
+}
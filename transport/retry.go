@@ -1,6 +1,9 @@
 package transport
 
 import (
+	"math/rand"
+	"time"
+
 	"github.com/scylladb/scylla-go-driver/frame"
 	. "github.com/scylladb/scylla-go-driver/frame/response"
 )
@@ -30,6 +33,21 @@ type RetryDecider interface {
 	Reset()
 }
 
+// RetryDelayer is implemented by RetryDeciders that delay retries, session waits for the delay
+// before executing RetrySameNode and RetryNextNode decisions or until query context is done.
+type RetryDelayer interface {
+	// RetryDelay returns delay before the retry decided by the last call to Decide.
+	RetryDelay() time.Duration
+}
+
+// ConsistencyDowngrader is implemented by RetryDeciders that lower consistency of retries,
+// session executes RetrySameNode and RetryNextNode decisions at the returned consistency.
+type ConsistencyDowngrader interface {
+	// RetryConsistency returns consistency of the retry decided by the last call to Decide,
+	// ok is false if consistency is not changed.
+	RetryConsistency() (c frame.Consistency, ok bool)
+}
+
 type FallthroughRetryPolicy struct{}
 
 func (*FallthroughRetryPolicy) NewRetryDecider() RetryDecider {
@@ -138,3 +156,211 @@ func (d *DefaultRetryDecider) Reset() {
 	d.wasReadTimeout = false
 	d.wasWriteTimeout = false
 }
+
+// ExponentialBackoffRetryPolicy delays retries decided by Child, the delay doubles after every retry
+// starting from Base, up to Max. Delays are randomized by Jitter fraction of the delay, to the range
+// [delay*(1-Jitter), delay], so that retries of concurrent queries to an overloaded node are spread in time.
+// Queries are not retried after MaxAttempts attempts, including the first one.
+type ExponentialBackoffRetryPolicy struct {
+	// Child decides whether and where to retry, nil means DefaultRetryPolicy.
+	Child RetryPolicy
+	// MaxAttempts is the maximal number of attempts to execute a query, 0 means no limit.
+	MaxAttempts int
+	Base        time.Duration
+	Max         time.Duration
+	// Jitter must be in range [0, 1].
+	Jitter float64
+}
+
+func NewExponentialBackoffRetryPolicy(maxAttempts int, base, max time.Duration, jitter float64) RetryPolicy {
+	return &ExponentialBackoffRetryPolicy{
+		MaxAttempts: maxAttempts,
+		Base:        base,
+		Max:         max,
+		Jitter:      jitter,
+	}
+}
+
+func (p *ExponentialBackoffRetryPolicy) NewRetryDecider() RetryDecider {
+	child := p.Child
+	if child == nil {
+		child = NewDefaultRetryPolicy()
+	}
+	return &exponentialBackoffRetryDecider{
+		child:  child.NewRetryDecider(),
+		policy: p,
+	}
+}
+
+type exponentialBackoffRetryDecider struct {
+	child   RetryDecider
+	policy  *ExponentialBackoffRetryPolicy
+	retries int
+	delay   time.Duration
+}
+
+var (
+	_ RetryDelayer          = (*exponentialBackoffRetryDecider)(nil)
+	_ ConsistencyDowngrader = (*exponentialBackoffRetryDecider)(nil)
+)
+
+func (d *exponentialBackoffRetryDecider) Decide(ri RetryInfo) RetryDecision {
+	d.delay = 0
+	if max := d.policy.MaxAttempts; max > 0 && d.retries+1 >= max {
+		return DontRetry
+	}
+	v := d.child.Decide(ri)
+	if v == DontRetry {
+		return DontRetry
+	}
+	d.delay = d.policy.delay(d.retries)
+	d.retries++
+	return v
+}
+
+func (d *exponentialBackoffRetryDecider) RetryDelay() time.Duration {
+	return d.delay
+}
+
+func (d *exponentialBackoffRetryDecider) RetryConsistency() (frame.Consistency, bool) {
+	if v, ok := d.child.(ConsistencyDowngrader); ok {
+		return v.RetryConsistency()
+	}
+	return 0, false
+}
+
+func (d *exponentialBackoffRetryDecider) Reset() {
+	d.child.Reset()
+	d.retries = 0
+	d.delay = 0
+}
+
+// delay returns delay before retry number n starting with 0.
+func (p *ExponentialBackoffRetryPolicy) delay(n int) time.Duration {
+	d := p.Base
+	for i := 0; i < n && d < p.Max; i++ {
+		d <<= 1
+	}
+	if d > p.Max {
+		d = p.Max
+	}
+	if j := int64(float64(d) * p.Jitter); j > 0 {
+		d -= time.Duration(rand.Int63n(j + 1))
+	}
+	return d
+}
+
+// DowngradingConsistencyRetryPolicy retries queries that failed because not enough replicas
+// were alive or responded in time at the highest consistency that is likely to succeed,
+// based on the number of replicas that responded. Every query is downgraded at most once.
+// Other errors are handled like in DefaultRetryPolicy.
+//
+// It may break consistency guarantees of the application, use it only if a degraded answer
+// is preferred to an error. QueryResult reports if consistency of the query was downgraded.
+type DowngradingConsistencyRetryPolicy struct{}
+
+func NewDowngradingConsistencyRetryPolicy() RetryPolicy {
+	return &DowngradingConsistencyRetryPolicy{}
+}
+
+func (*DowngradingConsistencyRetryPolicy) NewRetryDecider() RetryDecider {
+	return &DowngradingConsistencyRetryDecider{}
+}
+
+type DowngradingConsistencyRetryDecider struct {
+	DefaultRetryDecider
+
+	// retried is set after the first retry of unavailable or timeout error.
+	retried     bool
+	consistency frame.Consistency
+	downgrade   bool
+}
+
+var _ ConsistencyDowngrader = (*DowngradingConsistencyRetryDecider)(nil)
+
+func (d *DowngradingConsistencyRetryDecider) Decide(ri RetryInfo) RetryDecision {
+	d.downgrade = false
+	v, ok := ri.Error.(CodedError)
+	if !ok {
+		return d.DefaultRetryDecider.Decide(ri)
+	}
+
+	switch v.ErrorCode() {
+	// Unavailable - retry on a different node at the consistency that alive replicas can satisfy.
+	case frame.ErrCodeUnavailable:
+		err := v.(UnavailableError)
+		if d.retried || isSerial(err.Consistency) {
+			return DontRetry
+		}
+		d.retried = true
+		return d.downgradeTo(ri.Consistency, int(err.Alive), RetryNextNode)
+	// Read Timeout - if not enough replicas responded, retry at the consistency they can satisfy.
+	// If enough replicas responded, but the data was not retrieved, retry at the same consistency.
+	case frame.ErrCodeReadTimeout:
+		err := v.(ReadTimeoutError)
+		if d.retried || isSerial(err.Consistency) {
+			return DontRetry
+		}
+		d.retried = true
+		if err.Received < err.BlockFor {
+			return d.downgradeTo(ri.Consistency, int(err.Received), RetrySameNode)
+		}
+		if !err.DataPresent {
+			return RetrySameNode
+		}
+		return DontRetry
+	// Write Timeout - only idempotent writes are retried, unlogged batches at the consistency
+	// replicas that responded can satisfy and BatchLog writes at the same consistency.
+	case frame.ErrCodeWriteTimeout:
+		err := v.(WriteTimeoutError)
+		if d.retried || !ri.Idempotent || isSerial(err.Consistency) {
+			return DontRetry
+		}
+		d.retried = true
+		switch err.WriteType {
+		case frame.UnloggedBatch:
+			return d.downgradeTo(ri.Consistency, int(err.Received), RetrySameNode)
+		case frame.BatchLog:
+			return RetrySameNode
+		default:
+			return DontRetry
+		}
+	default:
+		return d.DefaultRetryDecider.Decide(ri)
+	}
+}
+
+// downgradeTo returns decision to retry at the highest consistency that replicas can satisfy,
+// DontRetry if there are no replicas. Local consistencies are downgraded to LOCAL_ONE,
+// so that the query doesn't leave the datacenter.
+func (d *DowngradingConsistencyRetryDecider) downgradeTo(current frame.Consistency, replicas int, decision RetryDecision) RetryDecision {
+	var c frame.Consistency
+	switch {
+	case replicas <= 0:
+		return DontRetry
+	case current == frame.LOCALQUORUM || current == frame.LOCALONE:
+		c = frame.LOCALONE
+	case replicas >= 3:
+		c = frame.THREE
+	case replicas == 2:
+		c = frame.TWO
+	default:
+		c = frame.ONE
+	}
+	d.consistency, d.downgrade = c, true
+	return decision
+}
+
+func (d *DowngradingConsistencyRetryDecider) RetryConsistency() (frame.Consistency, bool) {
+	return d.consistency, d.downgrade
+}
+
+func (d *DowngradingConsistencyRetryDecider) Reset() {
+	d.DefaultRetryDecider.Reset()
+	d.retried = false
+	d.downgrade = false
+}
+
+func isSerial(c frame.Consistency) bool {
+	return c == frame.SERIAL || c == frame.LOCALSERIAL
+}
@@ -3,6 +3,7 @@ package transport
 import (
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/scylladb/scylla-go-driver/frame"
 	. "github.com/scylladb/scylla-go-driver/frame/response"
@@ -270,3 +271,218 @@ func TestDefaultRetryPolicy(t *testing.T) {
 		})
 	}
 }
+
+func TestExponentialBackoffRetryPolicy(t *testing.T) {
+	t.Parallel()
+	overloaded := ScyllaError{Code: frame.ErrCodeOverloaded}
+	testCases := []struct {
+		name      string
+		policy    ExponentialBackoffRetryPolicy
+		ri        RetryInfo
+		decisions []RetryDecision
+		delays    []time.Duration
+	}{
+		{
+			name:      "delay doubles up to max",
+			policy:    ExponentialBackoffRetryPolicy{Base: 10 * time.Millisecond, Max: 50 * time.Millisecond},
+			ri:        RetryInfo{Error: overloaded, Idempotent: true},
+			decisions: []RetryDecision{RetryNextNode, RetryNextNode, RetryNextNode, RetryNextNode},
+			delays:    []time.Duration{10 * time.Millisecond, 20 * time.Millisecond, 40 * time.Millisecond, 50 * time.Millisecond},
+		},
+		{
+			name:      "max attempts",
+			policy:    ExponentialBackoffRetryPolicy{MaxAttempts: 3, Base: time.Millisecond, Max: time.Second},
+			ri:        RetryInfo{Error: overloaded, Idempotent: true},
+			decisions: []RetryDecision{RetryNextNode, RetryNextNode, DontRetry},
+			delays:    []time.Duration{time.Millisecond, 2 * time.Millisecond, 0},
+		},
+		{
+			name:      "child decides",
+			policy:    ExponentialBackoffRetryPolicy{Base: time.Millisecond, Max: time.Second},
+			ri:        RetryInfo{Error: overloaded, Idempotent: false},
+			decisions: []RetryDecision{DontRetry},
+			delays:    []time.Duration{0},
+		},
+		{
+			name:      "custom child",
+			policy:    ExponentialBackoffRetryPolicy{Child: NewFallthroughRetryPolicy(), Base: time.Millisecond, Max: time.Second},
+			ri:        RetryInfo{Error: overloaded, Idempotent: true},
+			decisions: []RetryDecision{DontRetry},
+			delays:    []time.Duration{0},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			rd := tc.policy.NewRetryDecider()
+			for j := range tc.decisions {
+				if d := rd.Decide(tc.ri); d != tc.decisions[j] {
+					t.Fatalf("decision %d = %v, expected %v", j, d, tc.decisions[j])
+				}
+				if d := rd.(RetryDelayer).RetryDelay(); d != tc.delays[j] {
+					t.Fatalf("delay %d = %v, expected %v", j, d, tc.delays[j])
+				}
+			}
+
+			rd.Reset()
+			if d := rd.Decide(tc.ri); d != tc.decisions[0] {
+				t.Fatalf("decision after reset = %v, expected %v", d, tc.decisions[0])
+			}
+		})
+	}
+}
+
+func TestExponentialBackoffRetryPolicyJitter(t *testing.T) {
+	t.Parallel()
+	p := NewExponentialBackoffRetryPolicy(0, 100*time.Millisecond, time.Second, 0.5).(*ExponentialBackoffRetryPolicy)
+	for i := 0; i < 100; i++ {
+		if d := p.delay(1); d < 100*time.Millisecond || d > 200*time.Millisecond {
+			t.Fatalf("delay %v out of range [100ms, 200ms]", d)
+		}
+	}
+}
+
+func TestDowngradingConsistencyRetryPolicy(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		name        string
+		ri          RetryInfo
+		decision    RetryDecision
+		consistency frame.Consistency
+		downgrade   bool
+	}{
+		{
+			name: "Unavailable",
+			ri: RetryInfo{
+				Error:       UnavailableError{ScyllaError: ScyllaError{Code: frame.ErrCodeUnavailable}, Consistency: frame.QUORUM, Required: 3, Alive: 2},
+				Consistency: frame.QUORUM,
+			},
+			decision:    RetryNextNode,
+			consistency: frame.TWO,
+			downgrade:   true,
+		},
+		{
+			name: "Unavailable no replicas alive",
+			ri: RetryInfo{
+				Error:       UnavailableError{ScyllaError: ScyllaError{Code: frame.ErrCodeUnavailable}, Consistency: frame.QUORUM, Required: 3},
+				Consistency: frame.QUORUM,
+			},
+			decision: DontRetry,
+		},
+		{
+			name: "Unavailable serial",
+			ri: RetryInfo{
+				Error:       UnavailableError{ScyllaError: ScyllaError{Code: frame.ErrCodeUnavailable}, Consistency: frame.SERIAL, Required: 3, Alive: 2},
+				Consistency: frame.QUORUM,
+			},
+			decision: DontRetry,
+		},
+		{
+			name: "ReadTimeout not enough responses",
+			ri: RetryInfo{
+				Error:       ReadTimeoutError{ScyllaError: ScyllaError{Code: frame.ErrCodeReadTimeout}, Consistency: frame.LOCALQUORUM, Received: 1, BlockFor: 2},
+				Consistency: frame.LOCALQUORUM,
+			},
+			decision:    RetrySameNode,
+			consistency: frame.LOCALONE,
+			downgrade:   true,
+		},
+		{
+			name: "Unavailable local quorum 2 replicas alive",
+			ri: RetryInfo{
+				Error:       UnavailableError{ScyllaError: ScyllaError{Code: frame.ErrCodeUnavailable}, Consistency: frame.LOCALQUORUM, Required: 3, Alive: 2},
+				Consistency: frame.LOCALQUORUM,
+			},
+			decision:    RetryNextNode,
+			consistency: frame.LOCALONE,
+			downgrade:   true,
+		},
+		{
+			name: "ReadTimeout local quorum 3 responses",
+			ri: RetryInfo{
+				Error:       ReadTimeoutError{ScyllaError: ScyllaError{Code: frame.ErrCodeReadTimeout}, Consistency: frame.LOCALQUORUM, Received: 3, BlockFor: 4},
+				Consistency: frame.LOCALQUORUM,
+			},
+			decision:    RetrySameNode,
+			consistency: frame.LOCALONE,
+			downgrade:   true,
+		},
+		{
+			name: "ReadTimeout enough responses, data == false",
+			ri: RetryInfo{
+				Error:       ReadTimeoutError{ScyllaError: ScyllaError{Code: frame.ErrCodeReadTimeout}, Consistency: frame.ALL, Received: 3, BlockFor: 3},
+				Consistency: frame.ALL,
+			},
+			decision: RetrySameNode,
+		},
+		{
+			name: "ReadTimeout enough responses, data == true",
+			ri: RetryInfo{
+				Error:       ReadTimeoutError{ScyllaError: ScyllaError{Code: frame.ErrCodeReadTimeout}, Consistency: frame.ALL, Received: 3, BlockFor: 3, DataPresent: true},
+				Consistency: frame.ALL,
+			},
+			decision: DontRetry,
+		},
+		{
+			name: "WriteTimeout unlogged batch",
+			ri: RetryInfo{
+				Error:       WriteTimeoutError{ScyllaError: ScyllaError{Code: frame.ErrCodeWriteTimeout}, Consistency: frame.ALL, Received: 4, BlockFor: 5, WriteType: frame.UnloggedBatch},
+				Idempotent:  true,
+				Consistency: frame.ALL,
+			},
+			decision:    RetrySameNode,
+			consistency: frame.THREE,
+			downgrade:   true,
+		},
+		{
+			name: "WriteTimeout not idempotent",
+			ri: RetryInfo{
+				Error:       WriteTimeoutError{ScyllaError: ScyllaError{Code: frame.ErrCodeWriteTimeout}, Consistency: frame.ALL, Received: 4, BlockFor: 5, WriteType: frame.UnloggedBatch},
+				Consistency: frame.ALL,
+			},
+			decision: DontRetry,
+		},
+		{
+			name: "WriteTimeout simple",
+			ri: RetryInfo{
+				Error:       WriteTimeoutError{ScyllaError: ScyllaError{Code: frame.ErrCodeWriteTimeout}, Consistency: frame.ALL, Received: 1, BlockFor: 2, WriteType: frame.Simple},
+				Idempotent:  true,
+				Consistency: frame.ALL,
+			},
+			decision: DontRetry,
+		},
+		{
+			name: "Overloaded",
+			ri: RetryInfo{
+				Error:       ScyllaError{Code: frame.ErrCodeOverloaded},
+				Idempotent:  true,
+				Consistency: frame.ALL,
+			},
+			decision: RetryNextNode,
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			rd := NewDowngradingConsistencyRetryPolicy().NewRetryDecider()
+			if d := rd.Decide(tc.ri); d != tc.decision {
+				t.Fatalf("decision = %v, expected %v", d, tc.decision)
+			}
+			c, ok := rd.(ConsistencyDowngrader).RetryConsistency()
+			if ok != tc.downgrade || c != tc.consistency {
+				t.Fatalf("consistency = %v, %v, expected %v, %v", c, ok, tc.consistency, tc.downgrade)
+			}
+
+			// Queries are downgraded at most once.
+			if tc.ri.Error.(CodedError).ErrorCode() != frame.ErrCodeOverloaded {
+				if d := rd.Decide(tc.ri); d != DontRetry {
+					t.Fatalf("second decision = %v, expected %v", d, DontRetry)
+				}
+			}
+		})
+	}
+}
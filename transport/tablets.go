@@ -0,0 +1,218 @@
+package transport
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/scylladb/scylla-go-driver/frame"
+)
+
+// tabletsRoutingKey is the custom payload key of tablet routing information, Scylla sends it
+// with responses to requests sent to a node or shard that is not a replica of the tablet.
+const tabletsRoutingKey = "tablets-routing-v1"
+
+// TabletReplica is a node and shard holding a tablet replica.
+type TabletReplica struct {
+	HostID frame.UUID
+	Shard  int
+}
+
+// Tablet describes replicas of the token range (FirstToken, LastToken] of a table.
+type Tablet struct {
+	FirstToken Token
+	LastToken  Token
+	Replicas   []TabletReplica
+}
+
+func (t Tablet) contains(token Token) bool {
+	return t.FirstToken < token && token <= t.LastToken
+}
+
+func (t Tablet) overlaps(o Tablet) bool {
+	return t.FirstToken < o.LastToken && o.FirstToken < t.LastToken
+}
+
+// ParseTablet parses tablet routing information from custom payload value,
+// it's a CQL tuple<bigint, bigint, list<tuple<uuid, int>>> of the first token, the last token and replicas.
+func ParseTablet(v []byte) (Tablet, error) {
+	var b frame.Buffer
+	b.Write(v)
+	first, last, replicas := b.ReadBytes(), b.ReadBytes(), b.ReadBytes()
+	if err := b.Error(); err != nil {
+		return Tablet{}, fmt.Errorf("tablet: %w", err)
+	}
+	if len(first) != 8 || len(last) != 8 {
+		return Tablet{}, fmt.Errorf("tablet: invalid token size")
+	}
+	t := Tablet{
+		FirstToken: Token(binary.BigEndian.Uint64(first)),
+		LastToken:  Token(binary.BigEndian.Uint64(last)),
+	}
+	if t.FirstToken >= t.LastToken {
+		return Tablet{}, fmt.Errorf("tablet: invalid token range (%d, %d]", t.FirstToken, t.LastToken)
+	}
+
+	var rb frame.Buffer
+	rb.Write(replicas)
+	n := rb.ReadInt()
+	if n < 0 || int(n) > len(replicas) {
+		return Tablet{}, fmt.Errorf("tablet: invalid replicas count %d", n)
+	}
+	t.Replicas = make([]TabletReplica, n)
+	for i := range t.Replicas {
+		var r frame.Buffer
+		r.Write(rb.ReadBytes())
+		hostID, shard := r.ReadBytes(), r.ReadBytes()
+		if err := rb.Error(); err != nil {
+			return Tablet{}, fmt.Errorf("tablet: replica %d: %w", i, err)
+		}
+		if err := r.Error(); err != nil {
+			return Tablet{}, fmt.Errorf("tablet: replica %d: %w", i, err)
+		}
+		if len(hostID) != 16 || len(shard) != 4 {
+			return Tablet{}, fmt.Errorf("tablet: replica %d: invalid size", i)
+		}
+		copy(t.Replicas[i].HostID[:], hostID)
+		t.Replicas[i].Shard = int(int32(binary.BigEndian.Uint32(shard)))
+	}
+	return t, nil
+}
+
+type tableKey struct {
+	keyspace string
+	table    string
+}
+
+// tabletCache holds tablets of tables learned from responses, tablets of a table are sorted by tokens
+// and don't overlap. It's kept by Cluster so that it's not lost on topology refresh, replicas are
+// identified by host ID and they are resolved to nodes of the current topology when a query is routed.
+type tabletCache struct {
+	mu     sync.RWMutex
+	tables map[tableKey][]Tablet
+}
+
+func newTabletCache() *tabletCache {
+	return &tabletCache{tables: make(map[tableKey][]Tablet)}
+}
+
+// add stores t replacing tablets overlapping it, as they were split, merged or moved.
+func (c *tabletCache) add(ks, table string, t Tablet) {
+	k := tableKey{keyspace: ks, table: table}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	old := c.tables[k]
+	res := make([]Tablet, 0, len(old)+1)
+	for _, v := range old {
+		if !v.overlaps(t) {
+			res = append(res, v)
+		}
+	}
+	i := sort.Search(len(res), func(i int) bool { return res[i].LastToken > t.LastToken })
+	res = append(res, Tablet{})
+	copy(res[i+1:], res[i:])
+	res[i] = t
+	c.tables[k] = res
+}
+
+// find returns tablet of table containing token.
+func (c *tabletCache) find(ks, table string, token Token) (Tablet, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	tablets := c.tables[tableKey{keyspace: ks, table: table}]
+	i := sort.Search(len(tablets), func(i int) bool { return tablets[i].LastToken >= token })
+	if i < len(tablets) && tablets[i].contains(token) {
+		return tablets[i], true
+	}
+	return Tablet{}, false
+}
+
+// retainKeyspaces drops tablets of keyspaces that are not present in ks.
+func (c *tabletCache) retainKeyspaces(ks ksMap) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for k := range c.tables {
+		if _, ok := ks[k.keyspace]; !ok {
+			delete(c.tables, k)
+		}
+	}
+}
+
+// tabletReplicas are replicas of a tablet resolved to nodes of topology in the order of policyInfo,
+// local datacenter replicas, with local rack ones first, followed by remote ones.
+type tabletReplicas struct {
+	local        []*Node
+	remote       []*Node
+	localRackCnt int
+
+	nodes  []*Node
+	shards []int
+}
+
+func (t *topology) tabletReplicas(tablet Tablet) *tabletReplicas {
+	r := &tabletReplicas{}
+	for _, v := range tablet.Replicas {
+		n := t.nodeByHostID(v.HostID)
+		if n == nil || n.filtered {
+			continue
+		}
+		r.nodes = append(r.nodes, n)
+		r.shards = append(r.shards, v.Shard)
+		if t.localDC == "" || n.datacenter == t.localDC {
+			r.local = append(r.local, n)
+		} else {
+			r.remote = append(r.remote, n)
+		}
+	}
+	if len(r.nodes) == 0 {
+		return nil
+	}
+	r.local, r.localRackCnt = t.localRackFirst(r.local)
+	return r
+}
+
+// shard returns shard holding tablet replica on node n.
+func (r *tabletReplicas) shard(n *Node) (int, bool) {
+	for i, v := range r.nodes {
+		if v == n {
+			return r.shards[i], true
+		}
+	}
+	return 0, false
+}
+
+func (t *topology) nodeByHostID(id frame.UUID) *Node {
+	for _, n := range t.Nodes {
+		if n.hostID == id {
+			return n
+		}
+	}
+	return nil
+}
+
+// AddTablet stores tablet routing information of table received in QueryResult,
+// following queries to the tablet are routed to its replicas.
+func (c *Cluster) AddTablet(ks, table string, t Tablet) {
+	if ks == "" {
+		ks = c.cfg.Keyspace
+	}
+	c.tablets.add(ks, table, t)
+}
+
+// NewTabletAwareQueryInfo is like NewTokenAwareQueryInfo, but if replicas of the tablet
+// of table containing token are known, query is routed to them and to their shards.
+func (c *Cluster) NewTabletAwareQueryInfo(t Token, ks, table string) (QueryInfo, error) {
+	qi, err := c.NewTokenAwareQueryInfo(t, ks)
+	if err != nil || !qi.tokenAware {
+		return qi, err
+	}
+	if ks == "" {
+		ks = c.cfg.Keyspace
+	}
+	if tablet, ok := c.tablets.find(ks, table, t); ok {
+		qi.tablet = qi.topology.tabletReplicas(tablet)
+	}
+	return qi, nil
+}
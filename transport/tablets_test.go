@@ -0,0 +1,187 @@
+package transport
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/scylladb/scylla-go-driver/frame"
+)
+
+func tabletPayload(first, last int64, replicas ...TabletReplica) []byte {
+	var list frame.Buffer
+	list.WriteInt(frame.Int(len(replicas)))
+	for _, r := range replicas {
+		var v frame.Buffer
+		v.WriteBytes(r.HostID[:])
+		v.WriteInt(4)
+		v.WriteInt(frame.Int(r.Shard))
+		list.WriteBytes(v.Bytes())
+	}
+
+	var b frame.Buffer
+	b.WriteInt(8)
+	b.WriteLong(frame.Long(first))
+	b.WriteInt(8)
+	b.WriteLong(frame.Long(last))
+	b.WriteBytes(list.Bytes())
+	return b.Bytes()
+}
+
+func TestParseTablet(t *testing.T) {
+	t.Parallel()
+	replicas := []TabletReplica{{HostID: frame.UUID{1}, Shard: 3}, {HostID: frame.UUID{2}, Shard: 0}}
+	valid := tabletPayload(-100, 100, replicas...)
+
+	testCases := []struct {
+		name     string
+		payload  []byte
+		expected Tablet
+		err      bool
+	}{
+		{
+			name:     "valid",
+			payload:  valid,
+			expected: Tablet{FirstToken: -100, LastToken: 100, Replicas: replicas},
+		},
+		{
+			name:     "no replicas",
+			payload:  tabletPayload(0, 1),
+			expected: Tablet{FirstToken: 0, LastToken: 1, Replicas: []TabletReplica{}},
+		},
+		{
+			name:    "truncated",
+			payload: valid[:len(valid)-1],
+			err:     true,
+		},
+		{
+			name:    "empty token range",
+			payload: tabletPayload(100, 100, replicas...),
+			err:     true,
+		},
+		{
+			name:    "empty",
+			payload: nil,
+			err:     true,
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			res, err := ParseTablet(tc.payload)
+			if tc.err {
+				if err == nil {
+					t.Fatalf("expected error, got %+v", res)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tc.expected, res); diff != "" {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
+
+func TestTabletCache(t *testing.T) {
+	t.Parallel()
+	tablet := func(first, last Token, host byte) Tablet {
+		return Tablet{FirstToken: first, LastToken: last, Replicas: []TabletReplica{{HostID: frame.UUID{host}}}}
+	}
+
+	c := newTabletCache()
+	c.add("ks", "t", tablet(0, 100, 1))
+	c.add("ks", "t", tablet(-100, 0, 2))
+	c.add("ks", "t", tablet(200, 300, 3))
+	c.add("ks", "other", tablet(-1000, 1000, 4))
+	// Tablet was split, the new tablet replaces the old one.
+	c.add("ks", "t", tablet(50, 100, 5))
+
+	testCases := []struct {
+		name  string
+		table string
+		token Token
+		host  byte
+	}{
+		{name: "first token is exclusive", table: "t", token: -100},
+		{name: "last token is inclusive", table: "t", token: 0, host: 2},
+		{name: "replaced", table: "t", token: 10},
+		{name: "split", table: "t", token: 100, host: 5},
+		{name: "gap", table: "t", token: 150},
+		{name: "last", table: "t", token: 250, host: 3},
+		{name: "after last", table: "t", token: 301},
+		{name: "other table", table: "other", token: 250, host: 4},
+		{name: "unknown table", table: "unknown", token: 0},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			res, ok := c.find("ks", tc.table, tc.token)
+			if tc.host == 0 {
+				if ok {
+					t.Fatalf("expected no tablet, got %+v", res)
+				}
+				return
+			}
+			if !ok {
+				t.Fatal("tablet not found")
+			}
+			if h := res.Replicas[0].HostID[0]; h != tc.host {
+				t.Fatalf("got tablet of host %d, expected %d", h, tc.host)
+			}
+		})
+	}
+}
+
+func TestTabletAwarePolicy(t *testing.T) {
+	t.Parallel()
+	top := mockTopologyTokenAwareDCAwareStrategy()
+	c := mockCluster(top, "waw/her", "waw")
+	policy := NewTokenAwarePolicy("waw")
+
+	// Replicas of the tablet differ from the ring ones, local datacenter replicas come first
+	// and replicas on unknown hosts are skipped.
+	c.AddTablet("waw/her", "t", Tablet{
+		FirstToken: 0,
+		LastToken:  1000,
+		Replicas: []TabletReplica{
+			{HostID: frame.UUID{8}, Shard: 1},
+			{HostID: frame.UUID{2}, Shard: 2},
+			{HostID: frame.UUID{99}, Shard: 3},
+		},
+	})
+
+	qi, err := c.NewTabletAwareQueryInfo(60, "waw/her", "t")
+	if err != nil {
+		t.Fatal(err)
+	}
+	qi.offset = 0
+	var plan []string
+	for i := 0; ; i++ {
+		n := policy.Node(qi, i)
+		if n == nil {
+			break
+		}
+		plan = append(plan, n.addr)
+	}
+	if diff := cmp.Diff([]string{"2", "8"}, plan); diff != "" {
+		t.Fatal(diff)
+	}
+	if s, ok := qi.tablet.shard(top.Nodes[1]); !ok || s != 2 {
+		t.Fatalf("shard = %d, %v, expected 2", s, ok)
+	}
+
+	// Queries to other tables are routed by the ring.
+	qi, err = c.NewTabletAwareQueryInfo(60, "waw/her", "other")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if qi.tablet != nil {
+		t.Fatalf("unexpected tablet replicas %+v", qi.tablet)
+	}
+}